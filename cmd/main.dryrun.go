@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/andomize/network-automation-executor/internal/adapters/inventory"
+	"github.com/andomize/network-automation-executor/internal/adapters/jsontask"
+	"github.com/andomize/network-automation-executor/internal/core/services/runner"
+)
+
+/*
+ * DryRun
+ *
+ * Вывести команды задания без подключения к хостам
+ * С инвентарём задание показывается для каждого выбранного хоста
+ * с переменными этого хоста. Если результат предыдущего запуска на хосте
+ * существует (<output>/<name>/<файл задания>), то показывается он, как
+ * при выполнении
+ */
+func DryRun(flags *Flags) error {
+
+	if len(flags.InventoryPath) <= 0 {
+		// Результат предыдущего запуска YAML задания или задания с include
+		// хранится отдельно от него
+		task, taskReadError := jsontask.Read(flags.TaskPath)
+		if taskReadError != nil {
+			return taskReadError
+		}
+
+		if resultPath := jsontask.ResultPath(flags.TaskPath, *task); resultPath != flags.TaskPath && fileExists(resultPath) {
+			if task, taskReadError = jsontask.Read(resultPath); taskReadError != nil {
+				return taskReadError
+			}
+		}
+
+		return runner.DryRun(*task, flags.TaskPath, nil, flags.Samples, os.Stdout)
+	}
+
+	inventoryData, inventoryError := inventory.Read(flags.InventoryPath)
+	if inventoryError != nil {
+		return inventoryError
+	}
+
+	targets, targetsError := inventory.Select(inventoryData, flags.Limit)
+	if targetsError != nil {
+		return targetsError
+	}
+
+	var failed int
+	for _, target := range targets {
+		// Каждый хост получает собственную копию задания
+		taskSource := flags.TaskPath
+		if _, resultPath := runner.TargetPaths(flags.TaskPath, flags.OutputDirectory, target); fileExists(resultPath) {
+			taskSource = resultPath
+		}
+
+		targetTask, targetReadError := jsontask.Read(taskSource)
+		if targetReadError != nil {
+			return targetReadError
+		}
+		targetTask.Host = target.Host
+
+		fmt.Printf("\n[%s]\n", target.Name)
+		if dryRunError := runner.DryRun(*targetTask, flags.TaskPath, target.Variables, flags.Samples, os.Stdout); dryRunError != nil {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%v of %v host(s) cannot be rendered", failed, len(targets))
+	}
+
+	return nil
+}
+
+func fileExists(path string) bool {
+	info, statError := os.Stat(path)
+	return statError == nil && info.Mode().IsRegular()
+}
@@ -1,315 +1,247 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"errors"
 	"flag"
 	"fmt"
+	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/andomize/network-automation-executor/internal/adapters/environment"
+	"github.com/andomize/network-automation-executor/internal/adapters/eventstream"
+	"github.com/andomize/network-automation-executor/internal/adapters/inventory"
+	"github.com/andomize/network-automation-executor/internal/adapters/jsontask"
 	"github.com/andomize/network-automation-executor/internal/adapters/logger"
+	"github.com/andomize/network-automation-executor/internal/adapters/metrics"
+	"github.com/andomize/network-automation-executor/internal/adapters/transferserver"
+	"github.com/andomize/network-automation-executor/internal/adapters/webhook"
 	"github.com/andomize/network-automation-executor/internal/core/domains"
 	"github.com/andomize/network-automation-executor/internal/core/ports"
-	"github.com/andomize/network-automation-executor/internal/core/services/controller"
+	"github.com/andomize/network-automation-executor/internal/core/services/runner"
+	"github.com/andomize/network-automation-executor/pkg/executor"
 )
 
 func main() {
 
-	taskPath, outputDirectory, flagsError := GetFlags()
-	logger.Must(flagsError, "Arguments is wrong")
+	// Директории библиотек заданий для подключений "include": "@<name>"
+	jsontask.SetLibraries(filepath.SplitList(environment.Get("TASK_LIBRARY", "", false)))
 
-	username := environment.Get("CLI_USERNAME", "", true)
-	password := environment.Get("CLI_PASSWORD", "", true)
+	// Режим сервера: приём заданий через HTTP API
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		logger.Must(Serve(os.Args[2:]), "Server stopped with error")
+		return
+	}
 
-	// Читаем содержимое файла задания и на основе него создаём контроллер
-	controller, controllerError := controller.NewController(
-		taskPath, outputDirectory, username, password)
-	logger.Must(controllerError, "Cannot create task controller")
-	defer controller.ExitSuccess()
+	// Проверка файлов заданий без подключения к хостам
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		logger.Must(Validate(os.Args[2:]), "Validation failed")
+		return
+	}
 
-	logger.INFO("Connection to host '" + controller.Task.Host + "' successful")
+	// Режим планировщика: запуск заданий по расписанию
+	if len(os.Args) > 1 && os.Args[1] == "schedule" {
+		logger.Must(Schedule(os.Args[2:]), "Scheduler stopped with error")
+		return
+	}
 
-	// Запускаем поочерёдное выполнение заданий
-	Run(controller, controller.Task.Tasks, controller.Variables, 0)
+	flags, flagsError := GetFlags()
+	logger.Must(flagsError, "Arguments is wrong")
 
-	// Если необходимо тестирование задания, то выполняем тестирование
-	if autotestsError := controller.Autotests(); autotestsError != nil {
-		controller.ExitError(autotestsError.Error())
+	// Просмотр команд задания без подключения к хостам
+	if flags.DryRun {
+		logger.Must(DryRun(flags), "Dry-run failed")
+		return
 	}
+
+	os.Exit(Run(flags))
 }
 
 /*
  * Run
  *
- * Передаём на исполнение все задания текущего уровня
- * Задания будут передататься рекурсивно до тех пор, пока не будет достугнут
- * конечный уровень вложенности
+ * Выполнить задание на хосте из файла задания или на выбранных хостах
+ * инвентаря. Возвращает код завершения программы: выход выполняется после
+ * остановки сервера передачи файлов, отправки уведомлений и закрытия
+ * потока событий
  */
-func Run(
-	ctrl *controller.Controller,
-	tasks *[]domains.Task,
-	variables controller.Artefacts,
-	depthLevel int,
-) {
-
-	logger.DEBUG(fmt.Sprintf("RUN: Starting tasks execution, depth: %v, taskCount: %v",
-		depthLevel, len(*tasks)))
-
-	// Выполняем поочерёдно все задания текущего уровня
-	// Текущим уровнем может быть и корневой уровень в том числе, если подзаданий не существует
-	// у любого из заданий корневого уровня, то по истечению данного цикла (выполнению всех
-	// подзадач текущего (корневого) уровня), будет осуществлён выход из программы
-	for taskIdx, task := range *tasks {
-
-		logger.DEBUG(fmt.Sprintf("RUN: Processing task '%v' with command '%v'", taskIdx, task.Command))
-
-		// Компилируем задание
-		//  - Вместо имён всех переменных подставляются их значения
-		//  - Вычисляется Timeout для ожидания ответа на основе данных в задании
-		ctrl.Compile(&task, variables)
-
-		// Проверяем установлено ли имя следующего задания
-		if len(ctrl.NextTaskName) > 0 {
-			// Если установлено имя следующего задания, то все задания, не соответствующие
-			// искомому - будут пропускаться
-			if task.Name != ctrl.NextTaskName {
-				logger.WARNING("RUN: Command: '" + task.Command + "' was skipped by GOTO action")
-				ctrl.SetTaskStatus(&(*tasks)[taskIdx], ports.PIPE_STATUS_SKIPPED)
-				continue
-			}
-			// Обнуляем имя следующего задания, т.к. оно было достигнуто
-			// далее задания будут выполняться в нормальном порядке
-			ctrl.NextTaskName = ""
-		}
+func Run(flags *Flags) int {
 
-		// Проверяем что задание не выполнялось ранее
-		// Если у задания в поле "status" уже есть результат выполнения, то это означает,
-		// что данный файл задания уже запускался ранее и необходимо защитить удалённых хост
-		// от повторного выполнения команд, если это не разрешено в явном виде
-		if len(task.Status) > 0 {
-			// Данное задание уже выполнялось
-			// Проверим разрешено ли повторное исполнение выполнение текущего задания
-			// Если задание - это подзадание n-го уровня, то ошибки быть не должно т.к.
-			// для подзадач свойственнен множественный запуск
-			if !task.Params.CommandRepeatAllowed && depthLevel == 0 {
-				// Разрешение на повторное выполнение отсутствует и это корневое задание
-				// Устанавливаем новый статус задания - SKIPPED
-				// Выполняем переход к следующему заданию
-				logger.WARNING("RUN: Command: '" + task.Command + "' has already been executed")
-				ctrl.SetTaskStatus(&(*tasks)[taskIdx], ports.PIPE_STATUS_SKIPPED)
-				continue
-			} else {
-				// Разрешение на повторное выполнение присутствует
-				//logger.INFO("RUN: Command: '" + task.Command + "' has already been executed," +
-				//	" but CommandRepeatAllowed is true, continue...")
-			}
-		}
+	username := environment.Get("CLI_USERNAME", "", true)
+	password := environment.Get("CLI_PASSWORD", "", true)
+
+	// Запускаем встроенный сервер передачи файлов на время выполнения задания
+	transferServer, transferServerError := StartTransferServer(flags)
+	if transferServerError != nil {
+		return failure(transferServerError, "Cannot start transfer server")
+	}
+	if transferServer != nil {
+		defer transferServer.Close()
+	}
 
-		// Проверяем еть ли в задании условия и выполняются ли они
-		conditionsSuccess, conditionsError := ctrl.WhenMatcher(task.When, variables)
-
-		if conditionsError != nil {
-			// Если была выявлена ошибка на логическом/программном уровне - завершнние работы
-			logger.ERROR("RUN: Command '" + task.Command + "' conditions is fail, error")
-			ctrl.ExitError(conditionsError.Error())
-
-		} else {
-			// Если условное выражение из задания неуспешно, то пропускаем (Условие не выполнено)
-			if !conditionsSuccess {
-				logger.INFO("RUN: Command '" + task.Command + "' conditions is fail, continue...")
-				ctrl.SetTaskStatus(&(*tasks)[taskIdx], ports.PIPE_STATUS_SKIPPED)
-				continue
-			}
+	notifier, notifierError := ReadNotifier(flags.WebhooksPath)
+	if notifierError != nil {
+		return failure(notifierError, "Cannot read webhooks file")
+	}
+
+	events, eventsError := OpenEvents(flags.EventsPath)
+	if eventsError != nil {
+		return failure(eventsError, "Cannot open events file")
+	}
+	defer events.Close()
+
+	// Метрики доступны на время выполнения заданий
+	if len(flags.MetricsAddress) > 0 {
+		if metricsError := metrics.Serve(flags.MetricsAddress); metricsError != nil {
+			return failure(metricsError, "Cannot start metrics server")
 		}
+	}
+
+	options := runner.Options{
+		Username:        username,
+		Password:        password,
+		TransferServer:  transferServer,
+		TransferAddress: flags.TransferAddress,
+		Workers:         flags.Workers,
+		GroupLimits:     flags.GroupLimits,
+		HostTimeout:     flags.HostTimeout,
+		Notifier:        notifier,
+		Events:          events,
+	}
 
-		// Выполняем отправку команды на удалённое устройство
-		output, commandSendError := ctrl.Send(&task)
-
-		if commandSendError != nil {
-			// Команда была отправлена с ошибками
-			logger.DEBUG("RUN: Send command: '" + task.Command + "' failed")
-			ctrl.SetTaskStatus(&(*tasks)[taskIdx], ports.PIPE_STATUS_FAIL)
-
-			// Аргумент OnErrorContinue разрешает продолжение выполнения команд даже если
-			// текущая команда была выполнена с ошибкой. Проверяем установлен ли данный флаг
-			if task.Params.OnErrorContinue {
-
-				// Команда не была выполнена - Продолжение разрешено
-				logger.WARNING("RUN: Send command: '" + task.Command + "' failed," +
-					" but OnErrorContinue is true, continue...")
-			} else {
-
-				// Команда не была выполнена - Продолжение недоступно - выход
-				logger.ERROR(fmt.Sprintf("RUN: Send command: '%s' failed by reason: '%v'",
-					task.Command, commandSendError))
-				ctrl.ExitError(commandSendError.Error())
-			}
-		} else {
-			// Команда была отправлена успешно
-			logger.INFO("RUN: Send command: '" + task.Command + "' successful")
-			ctrl.SetTaskStatus(&(*tasks)[taskIdx], ports.PIPE_STATUS_SUCCESS)
+	// Прерывание программы (Ctrl+C, SIGTERM) завершает выполнение заданий
+	// с сохранением результатов
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Дожидаемся отправки уведомлений (прерывание программы отменяет повторы)
+	defer notifier.Close(ctx)
+
+	// Выполнение задания на хосте, указанном в файле задания
+	if len(flags.InventoryPath) <= 0 {
+		_, executeError := executor.ExecuteFile(ctx, flags.TaskPath, executor.Options{
+			Username:        username,
+			Password:        password,
+			OutputDirectory: flags.OutputDirectory,
+			Timeout:         time.Duration(flags.HostTimeout) * time.Second,
+			EventStream:     events,
+			TransferServer:  transferServer,
+			TransferAddress: flags.TransferAddress,
+			Notifier:        notifier,
+		})
+		if executeError != nil {
+			log.Print(executeError)
+			return 1
 		}
+		return 0
+	}
+
+	// Выполнение задания на выбранных хостах инвентаря
+	inventoryData, inventoryError := inventory.Read(flags.InventoryPath)
+	if inventoryError != nil {
+		return failure(inventoryError, "Cannot read inventory file")
+	}
 
-		// Флаг OutputFile (если он не пустой) говорит о том, что необходимо выполнить сохранение
-		// вывода от текущей команды в файл, имя которого указано в данной переменной
-		if len(task.Params.OutputFile) > 0 && (*tasks)[taskIdx].Status == ports.PIPE_STATUS_SUCCESS {
-			// Сохраняем в файл полученный вывод после выполнения команды
-			savingFileError := ctrl.SaveOutput(output, task.Params.OutputFile)
+	targets, targetsError := inventory.Select(inventoryData, flags.Limit)
+	if targetsError != nil {
+		return failure(targetsError, "Cannot select hosts from inventory")
+	}
 
-			if savingFileError != nil {
-				logger.ERROR("Saving output file is fail by reason: " + savingFileError.Error())
-				ctrl.ExitError(savingFileError.Error())
-			}
+	var results []domains.HostResult
 
-			logger.INFO("RUN: Save output to file: '" + task.Params.OutputFile + "' successful")
+	if len(flags.Canary) > 0 || len(flags.WaveSize) > 0 || flags.Confirm {
+		// Поэтапное выполнение: канареечные хосты, затем волны
+		rollout, rolloutError := GetRollout(flags, inventoryData, targets)
+		if rolloutError != nil {
+			return failure(rolloutError, "Cannot select canary hosts from inventory")
 		}
 
-		// Выполняем проверку на наличие параметра "Filter" в задании
-		// Если данный параметр существует, то необходимо распарсить вывод, полученный
-		// после отправки команды и сгенерировать соответствующие подзадания с
-		// подстановкой в качестве заданных переменных (например, {{1}} или {{name}})
-		// найденных в результате парсинга значений
-		if len(task.Params.Filter) > 0 {
-
-			// Распарсим вывод на основе регулярного выражения
-			// task.Params.Filter - Регулярное выражение по которму будет получен срез
-			//     найденных в выводе значений для дальнейшей подстановки в задания
-			// task.Params.FilterExclude - Регулярное выражение по которому полученные
-			//     ранее значения будут исключены из среза
-			regMap, regCount, regError := ctrl.RegExpMatch(
-				output, task.Params.Filter, task.Params.FilterExclude)
-
-			// Если не удалось распарсить вывод, используя заложенное регулярное выражение,
-			// то подзадания выполняться не будут, а для задания будет установлено ошибочное
-			// состояние
-			if regError != nil {
-				logger.ERROR("RUN: Regular expression is fail by reason: " + regError.Error())
-				ctrl.ExitError(regError.Error())
-			}
-
-			// Проверяем сколько элементов содержит результат парсинга вывода регулярным выражением
-			// Если количество результатов нулевое, то продолжать нет смысла - следующее задание
-			if regCount <= 0 {
-				logger.INFO("RUN: Regular expression returns zero values, skipping...")
-				ctrl.SetTaskStatus(&(*tasks)[taskIdx], ports.PIPE_STATUS_SKIPPED)
-				continue
-			}
-
-			// В зависимоти от количества найденных регулярным выражением значений из вывода,
-			// необходимо запустить подзадание соответтсвующее количество раз, подставляя в
-			// набор артефактов новые значения, которые были получены по регулярному выражению
-			//
-			// Изначальный формат:
-			//
-			//	|-------------------------------------------|
-			//	| Команда: show vdc detail                  |
-			//	|-------------------------------------------|
-			//	    |
-			//	    |    |----------------------------------|
-			//	    |--> | Команда: switchto vdc {{vdc}}    |
-			//	    |    |----------------------------------|
-			//	    |--> | Команда: show vrf detail         |
-			//	    |    |----------------------------------|
-			//	             |
-			//	             |    |-------------------------------------|
-			//	             |    | Команда: show ip route vrf {{vrf}}  |
-			//	             |    |-------------------------------------|
-			//	             |--> | Команда: show ip arp vrf {{vrf}}    |
-			//	                  |-------------------------------------|
-			//
-			// Сформированные задания:
-			//
-			//	 artefacts = {vdc: [L3-CORE, AGG]}
-			//	|-------------------------------------------|
-			//	| Команда: show vdc detail                  |
-			//	|-------------------------------------------|
-			//	    |
-			//	    |     artefacts = {vdc : L3-CORE, vrf: [mgmt]}
-			//	    |    |-----------------------------------|
-			//	    |--> | Команда: switchto vdc L3-CORE     |
-			//	    |    |-----------------------------------|
-			//	    |
-			//	    |     artefacts = {vdc : L3-CORE, vrf: [big-data, inside]}
-			//	    |    |-----------------------------------|
-			//	    |--> | Команда: show vrf detail          |
-			//	    |    |-----------------------------------|
-			//	    |        |
-			//	    |        |     artefacts = {vdc: L3-CORE, vrf: big-data}
-			//	    |        |    |------------------------------------------|
-			//	    |        |    | Команда: show ip route vrf big-data      |
-			//	    |        |    |------------------------------------------|
-			//	    |        |--> | Команда: show ip arp vrf big-data        |
-			//	    |        |    |------------------------------------------|
-			//	    |        |
-			//	    |        |     artefacts = {vdc: L3-CORE, vrf: inside}
-			//	    |        |    |------------------------------------------|
-			//	    |        |    | Команда: show ip route vrf inside        |
-			//	    |        |    |------------------------------------------|
-			//	    |        |--> | Команда: show ip arp vrf inside          |
-			//	    |             |------------------------------------------|
-			//	    |
-			//	    |     artefacts = {vdc : AGG, vrf: [mgmt]}
-			//	    |    |-----------------------------------|
-			//	    |--> | Команда: switchto vdc AGG         |
-			//	    |    |-----------------------------------|
-			//	    |
-			//	    |     artefacts = {vdc : AGG, vrf: [mgmt]}
-			//	    |    |-----------------------------------|
-			//	    |--> | Команда: show vrf detail          |
-			//	         |-----------------------------------|
-			//	             |
-			//	             |     artefacts = {vdc: AGG, vrf: mgmt}
-			//	             |    |------------------------------------------|
-			//	             |    | Команда: show ip route vrf mgmt          |
-			//	             |    |------------------------------------------|
-			//	             |--> | Команда: show ip arp vrf mgmt            |
-			//	                  |------------------------------------------|
-			//
-			if task.Tasks != nil {
-
-				logger.DEBUG(fmt.Sprintf("RUN: Task has subtasks and regular expression"+
-					" return '%d' values in any groups, map: '%v', default artefacts is: '%v'",
-					regCount, regMap, variables))
-
-				// В зависимоти от числа полученных артефактов, подзадания нужно запустить
-				// в аналогичном объёме, подставляя в каждое подзадание новое значение
-				// артефакта из массива артефактов
-				for subTaskOrder := 0; subTaskOrder < regCount; subTaskOrder++ {
-
-					// Определим новую переменную, которая будет хранить в себе артефакты
-					// только для конкретного подзадания. Это используется для того, что бы
-					// данные из подзаданий не могли переноситься между смежными подзаданиями
-					// или даже в следующее родительское задание
-					var subTaskArtefacts = map[string]string{}
-					for index, value := range variables {
-						subTaskArtefacts[index] = value
-					}
-
-					// Добавляем новые артефакты для следующего задания
-					// Если ранее существовали артефакты с идентичными идентификаторами,
-					// то такие артефакты будут перезаписаны на новое значение, при чём
-					// во время возврата к предыдущему заданию будут доступны прежние значения
-					for index, values := range regMap {
-						subTaskArtefacts[index] = values[subTaskOrder]
-
-						logger.DEBUG(fmt.Sprintf("RUN: Set new value for artefact id"+
-							" '%s' = '%s'", index, values[subTaskOrder]))
-					}
-
-					logger.DEBUG("RUN: Starting new subtask using artefacts: '" +
-						fmt.Sprint(subTaskArtefacts))
-
-					// Рекурсивно апускаем выполнение следующего задания
-					Run(ctrl, (*tasks)[taskIdx].Tasks, subTaskArtefacts, depthLevel+1)
-				}
-			} else {
-				logger.WARNING("RUN: Task contains regular expression," +
-					" but not contains subtasks, skipping...")
-				ctrl.SetTaskStatus(&(*tasks)[taskIdx], ports.PIPE_STATUS_SKIPPED)
-			}
+		waves, wavesError := runner.PlanWaves(targets, rollout)
+		if wavesError != nil {
+			return failure(wavesError, "Cannot split hosts into waves")
 		}
+
+		results = runner.ExecuteRollout(ctx, flags.TaskPath, flags.OutputDirectory, waves, rollout, options)
+	} else {
+		results = runner.ExecuteInventory(ctx, flags.TaskPath, flags.OutputDirectory, targets, options)
 	}
+
+	var failed int
+	for _, result := range results {
+		if result.Status != ports.PIPE_STATUS_SUCCESS {
+			failed++
+			logger.ERROR(fmt.Sprintf("Host '%s' %s: '%s'", result.Name, result.Status, result.Error))
+		}
+	}
+
+	logger.INFO(fmt.Sprintf("Inventory execution finished, hosts: %v, failed: %v",
+		len(results), failed))
+
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+/*
+ * failure
+ *
+ * Записать ошибку в журнал (как logger.Must) и вернуть код завершения 1
+ * Выход из программы выполняет вызывающая сторона после освобождения ресурсов
+ */
+func failure(err error, message string) int {
+	logger.ERROR(message)
+	log.Print(err)
+	return 1
+}
+
+type Flags struct {
+	// Путь к файлу задания
+	TaskPath string
+
+	// Директория для сохранения выводов
+	OutputDirectory string
+
+	// Файл инвентаря и выражение для выбора хостов
+	InventoryPath string
+	Limit         string
+
+	// Параллельное выполнение на хостах инвентаря
+	Workers     int
+	GroupLimits map[string]int
+	HostTimeout int
+
+	// Поэтапное выполнение на хостах инвентаря
+	Canary      string
+	WaveSize    string
+	MaxFailRate float64
+	Confirm     bool
+
+	// Встроенный сервер передачи файлов
+	TFTPAddress     string
+	HTTPAddress     string
+	StageDirectory  string
+	TransferAddress string
+
+	// Файл с настройками уведомлений
+	WebhooksPath string
+
+	// Адрес для отдачи метрик
+	MetricsAddress string
+
+	// Файл потока событий выполнения ("-" - стандартный вывод)
+	EventsPath string
+
+	// Просмотр задания без подключения и директория с примерами выводов
+	DryRun  bool
+	Samples string
 }
 
 /*
@@ -317,17 +249,42 @@ func Run(
  *
  * Получить список всех флагов, с которыми была запущена программ
  */
-func GetFlags() (string, string, error) {
+func GetFlags() (*Flags, error) {
 
 	var taskArg string
 	var outputArg string
 	var debugArg bool
 	var version bool
+	var groupLimitsArg string
+	var flags Flags
 
 	flag.StringVar(&taskArg, "t", "", "Path to task file")
 	flag.StringVar(&outputArg, "o", "", "Path to output directory")
 	flag.BoolVar(&debugArg, "d", false, "Debug mode")
 	flag.BoolVar(&version, "version", false, "Show program version")
+	flag.StringVar(&flags.InventoryPath, "i", "", "Path to inventory file")
+	flag.StringVar(&flags.Limit, "l", "all", "Hosts and groups of inventory (e.g. 'core,sw-*,!sw-03')")
+	flag.IntVar(&flags.Workers, "w", 1, "Number of inventory hosts processed concurrently")
+	flag.StringVar(&groupLimitsArg, "group-limit", "",
+		"Concurrency limits of inventory groups (e.g. 'core=1,access=10')")
+	flag.IntVar(&flags.HostTimeout, "host-timeout", 0,
+		"Maximum execution time on one host in seconds (0 - unlimited)")
+	flag.StringVar(&flags.Canary, "canary", "",
+		"Canary hosts executed first: number of hosts or hosts and groups of inventory")
+	flag.StringVar(&flags.WaveSize, "wave", "", "Size of rollout waves: number of hosts or percent (e.g. '25%')")
+	flag.Float64Var(&flags.MaxFailRate, "max-fail", 100,
+		"Maximum percent of failed hosts before rollout is halted (100 - never halted, 0 - halted on first failure)")
+	flag.BoolVar(&flags.Confirm, "confirm", false, "Ask operator for confirmation before each rollout wave")
+	flag.StringVar(&flags.TFTPAddress, "tftp", "", "Start embedded TFTP server on address (e.g. ':69')")
+	flag.StringVar(&flags.HTTPAddress, "http", "", "Start embedded HTTP server on address (e.g. ':8080')")
+	flag.StringVar(&flags.StageDirectory, "stage", "", "Directory with files served by embedded servers")
+	flag.StringVar(&flags.TransferAddress, "transfer-address", "",
+		"Address of embedded servers reachable by devices (detected by default)")
+	flag.StringVar(&flags.WebhooksPath, "webhooks", "", "Path to webhook notifications file")
+	flag.StringVar(&flags.EventsPath, "events", "", "Write JSONL execution events to file ('-' - stdout)")
+	flag.StringVar(&flags.MetricsAddress, "metrics", "", "Expose Prometheus metrics on address (e.g. ':9100')")
+	flag.BoolVar(&flags.DryRun, "dry-run", false, "Print commands of task without connecting to hosts")
+	flag.StringVar(&flags.Samples, "samples", "", "Directory with sample command outputs for dry-run filters")
 
 	// After parsing, the arguments following the flags are available
 	// as the slice flag.Args() or individually as flag.Arg(i).
@@ -346,8 +303,9 @@ func GetFlags() (string, string, error) {
 	}
 
 	// Выполняем проверку обязательных флагов
-	if len(taskArg) <= 0 || len(outputArg) <= 0 {
-		return "", "",
+	// Для dry-run директория выводов не требуется
+	if len(taskArg) <= 0 || (len(outputArg) <= 0 && !flags.DryRun) {
+		return nil,
 			errors.New("Required flags not found\n" +
 				"-t (TASK FILE):\n" +
 				"    /var/tasks/TASK.json\n" +
@@ -359,22 +317,166 @@ func GetFlags() (string, string, error) {
 				"    outputs\n")
 	}
 
+	// Разбираем ограничения групп инвентаря
+	groupLimits, groupLimitsError := runner.ParseGroupLimits(groupLimitsArg)
+	if groupLimitsError != nil {
+		return nil, groupLimitsError
+	}
+	flags.GroupLimits = groupLimits
+
 	// Transform directories/filenames from flags to absolute paths
 	// Normalize the path to the task file
 	taskdir, taskerr := filepath.Abs(filepath.Dir(taskArg))
 	taskfile := filepath.Base(taskArg)
-	taskPath := filepath.Join(taskdir, taskfile)
+	flags.TaskPath = filepath.Join(taskdir, taskfile)
 
 	// Normalize the path to the log file
 	outputDirectory, logerr := filepath.Abs(outputArg)
+	flags.OutputDirectory = outputDirectory
+
+	logger.DEBUG("Path to task: \"" + flags.TaskPath + "\"")
+	logger.DEBUG("Path to logs: \"" + flags.OutputDirectory + "\"")
+
+	// Normalize the path to the inventory file
+	var inventoryerr error
+	if len(flags.InventoryPath) > 0 {
+		flags.InventoryPath, inventoryerr = filepath.Abs(flags.InventoryPath)
+	}
 
-	logger.DEBUG("Path to task: \"" + taskPath + "\"")
-	logger.DEBUG("Path to logs: \"" + outputDirectory + "\"")
+	// Normalize the path to the stage directory
+	var stageerr error
+	if len(flags.StageDirectory) > 0 {
+		flags.StageDirectory, stageerr = filepath.Abs(flags.StageDirectory)
+	}
+
+	// Normalize the path to the webhooks file
+	var webhookserr error
+	if len(flags.WebhooksPath) > 0 {
+		flags.WebhooksPath, webhookserr = filepath.Abs(flags.WebhooksPath)
+	}
 
 	// Verifying that absolute paths successful created
-	if taskerr != nil || logerr != nil {
-		return "", "", errors.New("Path(s) are unacceptable")
+	if taskerr != nil || logerr != nil || stageerr != nil || inventoryerr != nil || webhookserr != nil {
+		return nil, errors.New("Path(s) are unacceptable")
+	}
+
+	return &flags, nil
+}
+
+/*
+ * GetRollout
+ *
+ * Сформировать параметры поэтапного выполнения из флагов
+ * Канареечные хосты задаются количеством (первые хосты выборки) или
+ * выражением выбора хостов инвентаря
+ */
+func GetRollout(flags *Flags, inventoryData *domains.Inventory, targets []domains.Target) (runner.Rollout, error) {
+
+	rollout := runner.Rollout{
+		WaveSize:    flags.WaveSize,
+		MaxFailRate: flags.MaxFailRate,
+	}
+
+	if flags.Confirm {
+		rollout.Confirm = ConfirmWave
+	}
+
+	if len(flags.Canary) <= 0 {
+		return rollout, nil
+	}
+
+	if count, countError := strconv.Atoi(flags.Canary); countError == nil {
+		for index := 0; index < count && index < len(targets); index++ {
+			rollout.Canary = append(rollout.Canary, targets[index].Name)
+		}
+		return rollout, nil
+	}
+
+	canary, canaryError := inventory.Select(inventoryData, flags.Canary)
+	if canaryError != nil {
+		return rollout, canaryError
+	}
+	for _, target := range canary {
+		rollout.Canary = append(rollout.Canary, target.Name)
+	}
+
+	return rollout, nil
+}
+
+/*
+ * ConfirmWave
+ *
+ * Запросить у оператора подтверждение запуска следующей волны
+ */
+func ConfirmWave(wave int, targets []domains.Target, results []domains.HostResult) bool {
+
+	failed, finished := runner.FailedCount(results)
+
+	var names = []string{}
+	for _, target := range targets {
+		names = append(names, target.Name)
+	}
+
+	fmt.Printf("\nFinished hosts: %v, failed: %v\nNext wave %v: %s\nContinue? [y/N]: ",
+		finished, failed, wave, strings.Join(names, ", "))
+
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+
+	return answer == "y" || answer == "yes"
+}
+
+/*
+ * ReadNotifier
+ *
+ * Прочитать настройки уведомлений, если файл указан
+ */
+func ReadNotifier(webhooksPath string) (*webhook.Notifier, error) {
+	if len(webhooksPath) <= 0 {
+		return nil, nil
+	}
+	return webhook.Read(webhooksPath)
+}
+
+/*
+ * OpenEvents
+ *
+ * Открыть поток событий выполнения, если файл указан
+ */
+func OpenEvents(eventsPath string) (*eventstream.Stream, error) {
+	if len(eventsPath) <= 0 {
+		return nil, nil
+	}
+	return eventstream.Open(eventsPath)
+}
+
+/*
+ * StartTransferServer
+ *
+ * Запустить встроенные TFTP/HTTP сервера, если они указаны во флагах
+ * Принятые файлы сохраняются в директорию выводов
+ */
+func StartTransferServer(flags *Flags) (*transferserver.TransferServer, error) {
+
+	if len(flags.TFTPAddress) <= 0 && len(flags.HTTPAddress) <= 0 {
+		return nil, nil
+	}
+
+	server := transferserver.NewTransferServer(flags.OutputDirectory, flags.StageDirectory)
+
+	if len(flags.TFTPAddress) > 0 {
+		if startError := server.StartTFTP(flags.TFTPAddress); startError != nil {
+			server.Close()
+			return nil, startError
+		}
+	}
+
+	if len(flags.HTTPAddress) > 0 {
+		if startError := server.StartHTTP(flags.HTTPAddress); startError != nil {
+			server.Close()
+			return nil, startError
+		}
 	}
 
-	return taskPath, outputDirectory, nil
+	return server, nil
 }
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/andomize/network-automation-executor/internal/adapters/environment"
+	"github.com/andomize/network-automation-executor/internal/adapters/logger"
+	"github.com/andomize/network-automation-executor/internal/adapters/metrics"
+	"github.com/andomize/network-automation-executor/internal/core/services/runner"
+	"github.com/andomize/network-automation-executor/internal/core/services/scheduler"
+)
+
+/*
+ * Schedule
+ *
+ * Режим планировщика: задания из файла расписаний запускаются
+ * периодически до остановки программы
+ */
+func Schedule(arguments []string) error {
+
+	var schedulesPath, webhooksPath, metricsAddress, eventsPath string
+	var debugArg bool
+
+	flags := flag.NewFlagSet("schedule", flag.ExitOnError)
+	flags.StringVar(&schedulesPath, "f", "", "Path to schedules file")
+	flags.StringVar(&webhooksPath, "webhooks", "", "Path to webhook notifications file")
+	flags.StringVar(&metricsAddress, "metrics", "", "Expose Prometheus metrics on address (e.g. ':9100')")
+	flags.StringVar(&eventsPath, "events", "", "Write JSONL execution events to file ('-' - stdout)")
+	flags.BoolVar(&debugArg, "d", false, "Debug mode")
+	flags.Parse(arguments)
+
+	if debugArg {
+		logger.ModuleEnableDebug()
+	}
+
+	if len(schedulesPath) <= 0 {
+		return errors.New("Schedules file is not set (-f)")
+	}
+
+	schedulesPath, pathError := filepath.Abs(schedulesPath)
+	if pathError != nil {
+		return errors.New("Path(s) are unacceptable")
+	}
+
+	notifier, notifierError := ReadNotifier(webhooksPath)
+	if notifierError != nil {
+		return notifierError
+	}
+
+	events, eventsError := OpenEvents(eventsPath)
+	if eventsError != nil {
+		return eventsError
+	}
+	defer events.Close()
+
+	if len(metricsAddress) > 0 {
+		if metricsError := metrics.Serve(metricsAddress); metricsError != nil {
+			return metricsError
+		}
+	}
+
+	options := runner.Options{
+		Username: environment.Get("CLI_USERNAME", "", true),
+		Password: environment.Get("CLI_PASSWORD", "", true),
+		Notifier: notifier,
+		Events:   events,
+	}
+
+	s, schedulerError := scheduler.NewScheduler(schedulesPath, options)
+	if schedulerError != nil {
+		return schedulerError
+	}
+
+	// Остановка планировщика прерывает выполняемые запуски
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Отправка уведомлений завершается при остановке
+	defer notifier.Close(ctx)
+
+	s.Run(ctx)
+
+	logger.INFO("Scheduler is stopped")
+	return nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/andomize/network-automation-executor/internal/adapters/environment"
+	"github.com/andomize/network-automation-executor/internal/adapters/grpcsession"
+	"github.com/andomize/network-automation-executor/internal/adapters/logger"
+	"github.com/andomize/network-automation-executor/internal/adapters/restapi"
+	"github.com/andomize/network-automation-executor/internal/core/services/jobs"
+	"github.com/andomize/network-automation-executor/internal/core/services/runner"
+)
+
+/*
+ * Serve
+ *
+ * Режим сервера: задания принимаются через HTTP API и выполняются в фоне
+ * Состояние заданий хранится в директории -data и восстанавливается после
+ * перезапуска сервера
+ * Токен доступа к API задаётся переменной окружения API_TOKEN, без токена
+ * API доступно только на loopback адресе
+ */
+func Serve(arguments []string) error {
+
+	var listen, grpcListen, dataDirectory, inventoryPath, groupLimitsArg, webhooksPath, eventsPath string
+	var concurrency, workers, hostTimeout int
+	var debugArg bool
+
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	flags.StringVar(&listen, "listen", "127.0.0.1:8080", "Address of HTTP API")
+	flags.StringVar(&grpcListen, "grpc", "", "Address of gRPC interactive sessions service (e.g. ':9090')")
+	flags.StringVar(&dataDirectory, "data", "jobs", "Directory for jobs state and outputs")
+	flags.StringVar(&inventoryPath, "i", "", "Path to inventory file")
+	flags.IntVar(&concurrency, "jobs", 4, "Number of jobs executed concurrently")
+	flags.IntVar(&workers, "w", 1, "Number of inventory hosts processed concurrently in one job")
+	flags.StringVar(&groupLimitsArg, "group-limit", "",
+		"Concurrency limits of inventory groups (e.g. 'core=1,access=10')")
+	flags.IntVar(&hostTimeout, "host-timeout", 0,
+		"Maximum execution time on one host in seconds (0 - unlimited)")
+	flags.StringVar(&webhooksPath, "webhooks", "", "Path to webhook notifications file")
+	flags.StringVar(&eventsPath, "events", "", "Write JSONL execution events to file ('-' - stdout)")
+	flags.BoolVar(&debugArg, "d", false, "Debug mode")
+	flags.Parse(arguments)
+
+	if debugArg {
+		logger.ModuleEnableDebug()
+	}
+
+	token := environment.Get("API_TOKEN", "", false)
+	for _, address := range []string{listen, grpcListen} {
+		if len(address) > 0 && len(token) <= 0 && !IsLoopback(address) {
+			return errors.New("Environment 'API_TOKEN' is required to listen on non-loopback address '" + address + "'")
+		}
+	}
+
+	groupLimits, groupLimitsError := runner.ParseGroupLimits(groupLimitsArg)
+	if groupLimitsError != nil {
+		return groupLimitsError
+	}
+
+	notifier, notifierError := ReadNotifier(webhooksPath)
+	if notifierError != nil {
+		return notifierError
+	}
+
+	events, eventsError := OpenEvents(eventsPath)
+	if eventsError != nil {
+		return eventsError
+	}
+	defer events.Close()
+
+	// Transform directories from flags to absolute paths
+	dataDirectory, dataerr := filepath.Abs(dataDirectory)
+	var inventoryerr error
+	if len(inventoryPath) > 0 {
+		inventoryPath, inventoryerr = filepath.Abs(inventoryPath)
+	}
+	if dataerr != nil || inventoryerr != nil {
+		return errors.New("Path(s) are unacceptable")
+	}
+
+	options := runner.Options{
+		Username:    environment.Get("CLI_USERNAME", "", true),
+		Password:    environment.Get("CLI_PASSWORD", "", true),
+		Workers:     workers,
+		GroupLimits: groupLimits,
+		HostTimeout: hostTimeout,
+		Notifier:    notifier,
+		Events:      events,
+	}
+
+	// Остановка сервера прерывает выполняемые задания, они будут запущены
+	// повторно после перезапуска
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Отправка уведомлений завершается при остановке
+	defer notifier.Close(ctx)
+
+	manager, managerError := jobs.NewManager(ctx, dataDirectory, inventoryPath, concurrency, options)
+	if managerError != nil {
+		return managerError
+	}
+
+	// Сервис интерактивных сессий
+	if len(grpcListen) > 0 {
+		sessions := grpcsession.NewSessionServer(token)
+		go func() {
+			logger.Must(sessions.Serve(grpcListen), "Cannot start gRPC sessions service")
+		}()
+		defer sessions.Close()
+	}
+
+	api := restapi.NewRestAPI(manager, token)
+	go func() {
+		<-ctx.Done()
+		logger.INFO("Server is stopping")
+		api.Close()
+	}()
+
+	return api.Serve(listen)
+}
+
+/*
+ * IsLoopback
+ *
+ * Адрес в формате "host:port" доступен только локально (127.0.0.1, ::1, localhost)
+ */
+func IsLoopback(address string) bool {
+
+	host, _, splitError := net.SplitHostPort(address)
+	if splitError != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/andomize/network-automation-executor/internal/adapters/inventory"
+	"github.com/andomize/network-automation-executor/internal/core/services/validator"
+)
+
+/*
+ * Validate
+ *
+ * Проверить файлы заданий без подключения к хостам
+ * Замечания выводятся в стандартный вывод, при наличии ошибок
+ * возвращается ошибка
+ */
+func Validate(arguments []string) error {
+
+	var inventoryPath, variablesArg, localRoot string
+	var schema bool
+
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	flags.StringVar(&inventoryPath, "i", "", "Path to inventory file (its variables are considered defined)")
+	flags.StringVar(&variablesArg, "vars", "", "External variables considered defined (e.g. 'site,vlan')")
+	flags.StringVar(&localRoot, "root", "",
+		"Directory that local files of task (loop CSV) must stay within, as for serve jobs")
+	flags.BoolVar(&schema, "schema", false, "Print JSON Schema of task file")
+	flags.Parse(arguments)
+
+	if schema {
+		os.Stdout.Write(validator.Schema)
+		return nil
+	}
+
+	if flags.NArg() <= 0 {
+		return errors.New("Task file(s) are not set: validate [-i inventory] [-vars a,b] [-root dir] TASK.json...")
+	}
+
+	var variables []string
+	for _, name := range strings.Split(variablesArg, ",") {
+		if name = strings.TrimSpace(name); len(name) > 0 {
+			variables = append(variables, name)
+		}
+	}
+
+	// Переменные инвентаря (всех хостов и групп)
+	if len(inventoryPath) > 0 {
+		inventoryData, inventoryError := inventory.Read(inventoryPath)
+		if inventoryError != nil {
+			return inventoryError
+		}
+		targets, targetsError := inventory.Select(inventoryData, "all")
+		if targetsError != nil {
+			return targetsError
+		}
+		for _, target := range targets {
+			for name := range target.Variables {
+				variables = append(variables, name)
+			}
+		}
+	}
+
+	var failed int
+	for _, taskPath := range flags.Args() {
+
+		issues, readError := validator.ValidateFile(taskPath, localRoot, variables)
+		if readError != nil {
+			fmt.Printf("%s: error: %v\n", taskPath, readError)
+			failed++
+			continue
+		}
+
+		for _, issue := range issues {
+			fmt.Printf("%s: %s\n", taskPath, issue)
+		}
+
+		if validator.HasErrors(issues) {
+			failed++
+		} else {
+			fmt.Printf("%s: OK\n", taskPath)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%v of %v task file(s) contain errors", failed, flags.NArg())
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package eventstream
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/andomize/network-automation-executor/internal/adapters/logger"
+	"github.com/andomize/network-automation-executor/internal/core/domains"
+)
+
+/*
+ * Stream
+ *
+ * Поток событий выполнения в формате JSONL (один JSON объект на строку)
+ * Поток может использоваться одновременно несколькими хостами
+ * Методы допускают вызов для nil (поток событий отключён)
+ */
+type Stream struct {
+	mutex  sync.Mutex
+	writer io.Writer
+	closer io.Closer
+}
+
+func New(writer io.Writer) *Stream {
+	return &Stream{writer: writer}
+}
+
+/*
+ * Open
+ *
+ * Открыть файл для записи событий (дописываются в конец файла)
+ * Путь "-" - стандартный вывод
+ */
+func Open(path string) (*Stream, error) {
+
+	if path == "-" {
+		return New(os.Stdout), nil
+	}
+
+	file, openError := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if openError != nil {
+		return nil, openError
+	}
+
+	return &Stream{writer: file, closer: file}, nil
+}
+
+/*
+ * Stream.Emit
+ *
+ * Записать событие. Время события устанавливается, если не указано
+ */
+func (s *Stream) Emit(event domains.Event) {
+
+	if s == nil {
+		return
+	}
+
+	if len(event.Time) <= 0 {
+		event.Time = time.Now().Format(time.RFC3339Nano)
+	}
+
+	eventJSON, marshalError := json.Marshal(event)
+	if marshalError != nil {
+		logger.ERROR("EVENTS: Cannot marshal event '" + event.Event + "': " + marshalError.Error())
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, writeError := s.writer.Write(append(eventJSON, '\n')); writeError != nil {
+		logger.ERROR("EVENTS: Cannot write event '" + event.Event + "': " + writeError.Error())
+	}
+}
+
+func (s *Stream) Close() {
+	if s == nil || s.closer == nil {
+		return
+	}
+	s.closer.Close()
+}
+
+// Длительность в миллисекундах для событий (поле присутствует и при нулевом значении)
+func Milliseconds(duration time.Duration) *int64 {
+	milliseconds := duration.Milliseconds()
+	return &milliseconds
+}
@@ -1,7 +1,10 @@
 package filestorage
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -44,22 +47,10 @@ func (f *FileStorage) Save(data []byte, filename string) error {
 		return nil
 	}
 
-	// Проверяем корректность имени файла
-	if nameIsOk := f.NameVerify(filename); !nameIsOk {
-		if normalize := f.NameNormalization(filename); len(normalize) > 0 {
-			filename = normalize
-		} else {
-			return fmt.Errorf("Filename is incorrect: '%s'", filename)
-		}
-	}
-
-	// Создаём путь к файлу, если он не был создан ранее
-	filepath := filepath.Join(f.directory, filename)
-
-	// Создаём путь к сохраняемому файлу, если он не существует
-	errorCreateDir := os.MkdirAll(f.directory, os.ModePerm)
-	if errorCreateDir != nil && !os.IsExist(errorCreateDir) {
-		return errorCreateDir
+	// Получаем путь к файлу, директория создаётся, если не существует
+	filepath, errorPath := f.Path(filename)
+	if errorPath != nil {
+		return errorPath
 	}
 
 	// Create creates or truncates the named file.
@@ -139,6 +130,51 @@ func (f *FileStorage) Read(filename string) ([]byte, error) {
 	return filereader, nil
 }
 
+/* FileStorage.Path
+ *
+ * Получить абсолютный путь к файлу в пределах директории
+ * Имя файла нормализуется, директория создаётся, если не существует
+ */
+func (f *FileStorage) Path(filename string) (string, error) {
+
+	// Проверяем корректность имени файла
+	if nameIsOk := f.NameVerify(filename); !nameIsOk {
+		if normalize := f.NameNormalization(filename); len(normalize) > 0 {
+			filename = normalize
+		} else {
+			return "", fmt.Errorf("Filename is incorrect: '%s'", filename)
+		}
+	}
+
+	// Создаём путь к файлу, если он не существует
+	errorCreateDir := os.MkdirAll(f.directory, os.ModePerm)
+	if errorCreateDir != nil && !os.IsExist(errorCreateDir) {
+		return "", errorCreateDir
+	}
+
+	return filepath.Join(f.directory, filename), nil
+}
+
+/* Checksum
+ *
+ * Получить контрольную сумму SHA-256 файла
+ */
+func Checksum(path string) (string, error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
 /* FileStorage.GetDirectory
  *
  * Получить текущее расположение
@@ -0,0 +1,194 @@
+package grpcsession
+
+import (
+	"errors"
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Типы событий сессии (SessionEvent.Type в session.proto)
+const (
+	EventConnected     = 1
+	EventOutput        = 2
+	EventPromptChanged = 3
+	EventCommandDone   = 4
+	EventError         = 5
+	EventClosed        = 6
+)
+
+type SessionRequest struct {
+	Host                string
+	Username            string
+	Password            string
+	Command             string
+	Timeout             int32
+	PromptChangeAllowed bool
+}
+
+type SessionEvent struct {
+	Type       int32
+	Data       string
+	Prompt     string
+	Vendor     string
+	Error      string
+	ErrorClass string
+	Command    string
+}
+
+/*
+ * codec
+ *
+ * Кодирование сообщений сервиса в формате Protocol Buffers (session.proto)
+ * без генерации кода: клиенты могут использовать код, сгенерированный из
+ * session.proto для любого языка
+ */
+type codec struct{}
+
+func (codec) Name() string {
+	return "proto"
+}
+
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	switch message := v.(type) {
+	case *SessionEvent:
+		return message.marshal(), nil
+	case *SessionRequest:
+		return message.marshal(), nil
+	}
+	return nil, fmt.Errorf("Unsupported message type: %T", v)
+}
+
+func (codec) Unmarshal(data []byte, v interface{}) error {
+	switch message := v.(type) {
+	case *SessionRequest:
+		return message.unmarshal(data)
+	case *SessionEvent:
+		return message.unmarshal(data)
+	}
+	return fmt.Errorf("Unsupported message type: %T", v)
+}
+
+func (m *SessionRequest) marshal() []byte {
+	var b []byte
+	b = appendString(b, 1, m.Host)
+	b = appendString(b, 2, m.Username)
+	b = appendString(b, 3, m.Password)
+	b = appendString(b, 4, m.Command)
+	b = appendVarint(b, 5, uint64(m.Timeout))
+	b = appendVarint(b, 6, protowire.EncodeBool(m.PromptChangeAllowed))
+	return b
+}
+
+func (m *SessionRequest) unmarshal(data []byte) error {
+	*m = SessionRequest{}
+	return parse(data, func(number protowire.Number, value uint64, text string) {
+		switch number {
+		case 1:
+			m.Host = text
+		case 2:
+			m.Username = text
+		case 3:
+			m.Password = text
+		case 4:
+			m.Command = text
+		case 5:
+			m.Timeout = int32(value)
+		case 6:
+			m.PromptChangeAllowed = protowire.DecodeBool(value)
+		}
+	})
+}
+
+func (m *SessionEvent) marshal() []byte {
+	var b []byte
+	b = appendVarint(b, 1, uint64(m.Type))
+	b = appendString(b, 2, m.Data)
+	b = appendString(b, 3, m.Prompt)
+	b = appendString(b, 4, m.Vendor)
+	b = appendString(b, 5, m.Error)
+	b = appendString(b, 6, m.ErrorClass)
+	b = appendString(b, 7, m.Command)
+	return b
+}
+
+func (m *SessionEvent) unmarshal(data []byte) error {
+	*m = SessionEvent{}
+	return parse(data, func(number protowire.Number, value uint64, text string) {
+		switch number {
+		case 1:
+			m.Type = int32(value)
+		case 2:
+			m.Data = text
+		case 3:
+			m.Prompt = text
+		case 4:
+			m.Vendor = text
+		case 5:
+			m.Error = text
+		case 6:
+			m.ErrorClass = text
+		case 7:
+			m.Command = text
+		}
+	})
+}
+
+// Поля со значениями по умолчанию не кодируются (правила proto3)
+func appendString(b []byte, number protowire.Number, value string) []byte {
+	if len(value) <= 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, number, protowire.BytesType)
+	return protowire.AppendString(b, value)
+}
+
+func appendVarint(b []byte, number protowire.Number, value uint64) []byte {
+	if value == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, number, protowire.VarintType)
+	return protowire.AppendVarint(b, value)
+}
+
+/*
+ * parse
+ *
+ * Разобрать сообщение, передавая значения полей в field
+ * Неизвестные поля пропускаются
+ */
+func parse(data []byte, field func(number protowire.Number, value uint64, text string)) error {
+	for len(data) > 0 {
+		number, wireType, length := protowire.ConsumeTag(data)
+		if length < 0 {
+			return protowire.ParseError(length)
+		}
+		data = data[length:]
+
+		switch wireType {
+		case protowire.VarintType:
+			value, valueLength := protowire.ConsumeVarint(data)
+			if valueLength < 0 {
+				return protowire.ParseError(valueLength)
+			}
+			field(number, value, "")
+			data = data[valueLength:]
+
+		case protowire.BytesType:
+			value, valueLength := protowire.ConsumeBytes(data)
+			if valueLength < 0 {
+				return protowire.ParseError(valueLength)
+			}
+			field(number, 0, string(value))
+			data = data[valueLength:]
+
+		default:
+			skipLength := protowire.ConsumeFieldValue(number, wireType, data)
+			if skipLength < 0 {
+				return errors.New("Cannot parse message: " + protowire.ParseError(skipLength).Error())
+			}
+			data = data[skipLength:]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+package grpcsession
+
+import (
+	"crypto/subtle"
+	"errors"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/andomize/network-automation-executor/internal/adapters/logger"
+	"github.com/andomize/network-automation-executor/internal/core/ports"
+	"github.com/andomize/network-automation-executor/internal/core/services/spawner"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Описание сервиса executor.session.v1.Session (session.proto)
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: "executor.session.v1.Session",
+	HandlerType: (*interface{})(nil),
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "Open",
+		Handler:       openHandler,
+		ServerStreams: true,
+		ClientStreams: true,
+	}},
+	Metadata: "session.proto",
+}
+
+/*
+ * SessionServer
+ *
+ * gRPC сервис интерактивных сессий: клиент открывает сессию с устройством
+ * через spawner, отправляет команды и получает вывод по мере поступления,
+ * события смены Prompt и классифицированные ошибки
+ * Учётные данные устройства передаются клиентом в первом сообщении сессии
+ * Если задан токен, то метаданные вызова должны содержать заголовок
+ * "authorization: Bearer <токен>"
+ */
+type SessionServer struct {
+	server *grpc.Server
+
+	// Токен доступа к сервису (пустой - без аутентификации)
+	token string
+}
+
+func NewSessionServer(token string) *SessionServer {
+	s := &SessionServer{
+		server: grpc.NewServer(grpc.ForceServerCodec(codec{})),
+		token:  token,
+	}
+	s.server.RegisterService(&serviceDesc, s)
+	return s
+}
+
+/*
+ * SessionServer.Serve
+ *
+ * Принимать подключения на адресе (например, ":9090") до вызова Close
+ */
+func (s *SessionServer) Serve(address string) error {
+
+	listener, listenError := net.Listen("tcp", address)
+	if listenError != nil {
+		return listenError
+	}
+
+	logger.INFO("GRPC_SESSION: Listening on '" + listener.Addr().String() + "'")
+	return s.server.Serve(listener)
+}
+
+func (s *SessionServer) Close() {
+	s.server.Stop()
+}
+
+func openHandler(server interface{}, stream grpc.ServerStream) error {
+	return server.(*SessionServer).open(stream)
+}
+
+/*
+ * SessionServer.open
+ *
+ * Обработка одной сессии
+ */
+func (s *SessionServer) open(stream grpc.ServerStream) error {
+
+	if !s.authorized(stream) {
+		logger.WARNING("GRPC_SESSION: Unauthorized session request")
+		return status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+
+	var request SessionRequest
+	if recvError := stream.RecvMsg(&request); recvError != nil {
+		return recvError
+	}
+
+	if len(request.Host) <= 0 {
+		return stream.SendMsg(errorEvent("", errors.New(ports.ERROR_SYNTAX_NO_HOST)))
+	}
+
+	if len(request.Username) <= 0 || len(request.Password) <= 0 {
+		return stream.SendMsg(errorEvent("", errors.New(ports.ERROR_SYNTAX_NO_CREDENTIALS)))
+	}
+	username, password := request.Username, request.Password
+
+	log := logger.New(request.Host, nil)
+	log.INFO("GRPC_SESSION: Opening session with host '" + request.Host + "'")
+
+	events := newEventStream(stream)
+	defer events.close()
+
+	connection, connectionError := spawner.NewConnection(stream.Context(), request.Host, username, password, log)
+	if connectionError != nil {
+		if connection != nil {
+			connection.Close()
+		}
+		events.send(errorEvent("", connectionError))
+		events.send(&SessionEvent{Type: EventClosed})
+		return nil
+	}
+	defer connection.Close()
+
+	events.send(&SessionEvent{Type: EventConnected,
+		Prompt: connection.Prompt.Name, Vendor: connection.Prompt.Vendor})
+
+	// Вывод устройства передаётся клиенту по мере поступления
+	connection.SetOutput(events)
+	defer connection.SetOutput(nil)
+
+	for {
+		if recvError := stream.RecvMsg(&request); recvError != nil {
+			if recvError != io.EOF {
+				log.DEBUG("GRPC_SESSION: Session closed by reason: " + recvError.Error())
+			}
+			break
+		}
+
+		timeout := int(request.Timeout)
+		if timeout <= 0 {
+			timeout = ports.SPAWN_TIMEOUT_SYSTEM
+		}
+
+		previousPrompt := connection.Prompt.Name
+		output, sendError := connection.Send(request.Command, timeout, request.PromptChangeAllowed)
+
+		if connection.Prompt.Name != previousPrompt {
+			events.send(&SessionEvent{Type: EventPromptChanged,
+				Prompt: connection.Prompt.Name, Vendor: connection.Prompt.Vendor})
+		}
+
+		done := &SessionEvent{Type: EventCommandDone, Command: request.Command, Data: output}
+		if sendError != nil {
+			done.Error, done.ErrorClass = sendError.Error(), Classify(sendError.Error())
+			events.send(errorEvent(request.Command, sendError))
+		}
+		events.send(done)
+
+		// Соединение с устройством потеряно - продолжать сессию невозможно
+		if sendError != nil && Classify(sendError.Error()) == "connection" {
+			break
+		}
+	}
+
+	log.INFO("GRPC_SESSION: Session with host '" + request.Host + "' closed")
+	events.send(&SessionEvent{Type: EventClosed})
+	return nil
+}
+
+/*
+ * SessionServer.authorized
+ *
+ * Проверить токен доступа в метаданных вызова
+ */
+func (s *SessionServer) authorized(stream grpc.ServerStream) bool {
+
+	if len(s.token) <= 0 {
+		return true
+	}
+
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	for _, header := range md.Get("authorization") {
+		provided := strings.TrimPrefix(header, "Bearer ")
+		if provided != header && subtle.ConstantTimeCompare([]byte(provided), []byte(s.token)) == 1 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func errorEvent(command string, err error) *SessionEvent {
+	return &SessionEvent{Type: EventError, Command: command,
+		Error: err.Error(), ErrorClass: Classify(err.Error())}
+}
+
+/*
+ * Classify
+ *
+ * Классификация кода ошибки: connection, prompt, command, syntax, internal
+ * Ошибки, не являющиеся кодами программы, относятся к классу unknown
+ */
+func Classify(code string) string {
+	switch {
+	case strings.HasPrefix(code, "connection-"),
+		code == ports.ERROR_RELOAD_RETURN_TIMEOUT,
+		code == ports.ERROR_CONN_TIMEOUT,
+		strings.Contains(code, "Process not running"):
+		return "connection"
+	case strings.HasPrefix(code, "spawner-prompt-"):
+		return "prompt"
+	case strings.HasPrefix(code, "spawner-"):
+		return "command"
+	case strings.HasPrefix(code, "syntax-"):
+		return "syntax"
+	case strings.HasPrefix(code, "internal-"):
+		return "internal"
+	}
+	return "unknown"
+}
@@ -0,0 +1,69 @@
+package grpcsession
+
+import (
+	"sync"
+
+	"google.golang.org/grpc"
+)
+
+/*
+ * eventStream
+ *
+ * Последовательная отправка событий клиенту из нескольких горутин
+ * (вывод устройства поступает из горутины чтения Spawn-сессии)
+ * Порядок событий сохраняется
+ */
+type eventStream struct {
+	events chan *SessionEvent
+	wait   sync.WaitGroup
+
+	mutex  sync.RWMutex
+	closed bool
+}
+
+func newEventStream(stream grpc.ServerStream) *eventStream {
+	e := &eventStream{
+		events: make(chan *SessionEvent, 64),
+	}
+
+	e.wait.Add(1)
+	go func() {
+		defer e.wait.Done()
+		for event := range e.events {
+			// Ошибка отправки означает, что клиент отключился: оставшиеся
+			// события вычитываются, что бы не блокировать отправителей
+			stream.SendMsg(event)
+		}
+	}()
+
+	return e
+}
+
+func (e *eventStream) send(event *SessionEvent) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if !e.closed {
+		e.events <- event
+	}
+}
+
+// Вывод устройства передаётся событиями OUTPUT
+func (e *eventStream) Write(data []byte) (int, error) {
+	e.send(&SessionEvent{Type: EventOutput, Data: string(data)})
+	return len(data), nil
+}
+
+/*
+ * eventStream.close
+ *
+ * Дождаться отправки всех событий. После закрытия события не отправляются
+ */
+func (e *eventStream) close() {
+	e.mutex.Lock()
+	e.closed = true
+	close(e.events)
+	e.mutex.Unlock()
+
+	e.wait.Wait()
+}
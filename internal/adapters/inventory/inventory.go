@@ -0,0 +1,230 @@
+package inventory
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/andomize/network-automation-executor/internal/adapters/logger"
+	"github.com/andomize/network-automation-executor/internal/core/domains"
+)
+
+/*
+ * Read
+ *
+ * Прочитать файл инвентаря
+ */
+func Read(filepath string) (*domains.Inventory, error) {
+
+	logger.DEBUG("INVENTORY_READ: Starting read file: '" + filepath + "'")
+
+	var inventory domains.Inventory
+
+	// Открываем файл для чтения и извлекаем его содержимое
+	inventoryContent, readFileError := ioutil.ReadFile(filepath)
+	if readFileError != nil {
+		logger.ERROR("INVENTORY_READ: Cannot read file: '" + filepath + "'")
+		return nil, readFileError
+	}
+
+	// Преобразуем прочитанные данные в объект
+	unmarshallError := json.Unmarshal(inventoryContent, &inventory)
+	if unmarshallError != nil {
+		logger.ERROR("INVENTORY_READ: Cannot unmarshall file: '" + filepath + "'")
+		return nil, unmarshallError
+	}
+
+	// Проверяем что группы ссылаются только на существующие хосты
+	for groupName, group := range inventory.Groups {
+		for _, hostName := range group.Hosts {
+			if _, exist := inventory.Hosts[hostName]; !exist {
+				return nil, fmt.Errorf("Group '%s' contains unknown host '%s'", groupName, hostName)
+			}
+		}
+	}
+
+	return &inventory, nil
+}
+
+/*
+ * Select
+ *
+ * Выбрать хосты инвентаря по выражению, например "core,sw-*,!sw-03"
+ *  - Элементы выражения разделяются запятой
+ *  - Элемент - имя группы или имя хоста, поддерживаются шаблоны (*, ?)
+ *  - Элемент с "!" исключает хосты из выборки
+ *  - Пустое выражение или "all" - все хосты
+ *
+ * Переменные хоста формируются в порядке возрастания приоритета:
+ * переменные инвентаря, переменные групп (по алфавиту), переменные хоста
+ */
+func Select(inventory *domains.Inventory, limit string) ([]domains.Target, error) {
+
+	var selected = map[string]bool{}
+
+	if len(strings.TrimSpace(limit)) <= 0 {
+		limit = "all"
+	}
+
+	for _, term := range strings.Split(limit, ",") {
+		term = strings.TrimSpace(term)
+		exclude := strings.HasPrefix(term, "!")
+		term = strings.TrimPrefix(term, "!")
+
+		if len(term) <= 0 {
+			continue
+		}
+
+		matches, matchError := match(inventory, term)
+		if matchError != nil {
+			return nil, matchError
+		}
+		if len(matches) <= 0 {
+			logger.WARNING("INVENTORY_SELECT: Pattern '" + term + "' does not match any host or group")
+		}
+
+		for _, hostName := range matches {
+			if exclude {
+				delete(selected, hostName)
+			} else {
+				selected[hostName] = true
+			}
+		}
+	}
+
+	var names = []string{}
+	for hostName := range selected {
+		names = append(names, hostName)
+	}
+	sort.Strings(names)
+
+	var targets = []domains.Target{}
+	for _, hostName := range names {
+		targets = append(targets, target(inventory, hostName))
+	}
+
+	return targets, nil
+}
+
+/*
+ * WriteReport
+ *
+ * Сохранить сводный результат выполнения задания на хостах инвентаря
+ */
+func WriteReport(filepath string, results []domains.HostResult) error {
+
+	logger.DEBUG("INVENTORY_REPORT: Start saving file: '" + filepath + "'")
+
+	reportJSON, err := json.MarshalIndent(results, "", "    ")
+	if err != nil {
+		logger.ERROR("INVENTORY_REPORT: Cannot marshal data: '" + filepath + "'")
+		return err
+	}
+
+	err = os.MkdirAll(path.Dir(filepath), os.ModePerm)
+	if err != nil {
+		logger.ERROR("INVENTORY_REPORT: Cannot create directory: '" + filepath + "'")
+		return err
+	}
+
+	err = ioutil.WriteFile(filepath, reportJSON, 0644)
+	if err != nil {
+		logger.ERROR("INVENTORY_REPORT: Cannot write data: '" + filepath + "'")
+		return err
+	}
+
+	return nil
+}
+
+/*
+ * match
+ *
+ * Найти все хосты, соответствующие шаблону имени хоста или группы
+ */
+func match(inventory *domains.Inventory, pattern string) ([]string, error) {
+
+	var result = []string{}
+
+	if pattern == "all" {
+		for hostName := range inventory.Hosts {
+			result = append(result, hostName)
+		}
+		return result, nil
+	}
+
+	for groupName, group := range inventory.Groups {
+		matched, matchError := path.Match(pattern, groupName)
+		if matchError != nil {
+			return nil, matchError
+		}
+		if matched {
+			result = append(result, group.Hosts...)
+		}
+	}
+
+	for hostName := range inventory.Hosts {
+		matched, matchError := path.Match(pattern, hostName)
+		if matchError != nil {
+			return nil, matchError
+		}
+		if matched {
+			result = append(result, hostName)
+		}
+	}
+
+	return result, nil
+}
+
+/*
+ * target
+ *
+ * Сформировать цель выполнения для хоста инвентаря
+ */
+func target(inventory *domains.Inventory, hostName string) domains.Target {
+
+	var result = domains.Target{
+		Name:      hostName,
+		Host:      hostName,
+		Groups:    []string{},
+		Variables: map[string]string{},
+	}
+
+	for index, value := range inventory.Variables {
+		result.Variables[index] = value
+	}
+
+	// Группы, в которые входит хост
+	for groupName, group := range inventory.Groups {
+		for _, member := range group.Hosts {
+			if member == hostName {
+				result.Groups = append(result.Groups, groupName)
+				break
+			}
+		}
+	}
+	sort.Strings(result.Groups)
+
+	for _, groupName := range result.Groups {
+		for index, value := range inventory.Groups[groupName].Variables {
+			result.Variables[index] = value
+		}
+	}
+
+	if host := inventory.Hosts[hostName]; host != nil {
+		if len(host.Host) > 0 {
+			result.Host = host.Host
+		}
+		for index, value := range host.Variables {
+			result.Variables[index] = value
+		}
+	}
+
+	// Имя хоста в инвентаре доступно в задании как переменная
+	result.Variables["inventoryName"] = hostName
+
+	return result
+}
@@ -3,6 +3,9 @@ package jsontask
 import (
 	"encoding/json"
 	"io/ioutil"
+	"os"
+	"path"
+	"strings"
 
 	"github.com/andomize/network-automation-executor/internal/adapters/logger"
 	"github.com/andomize/network-automation-executor/internal/core/domains"
@@ -12,11 +15,31 @@ import (
  * Read
  *
  * Прочитать файл задания
+ * Файлы .yaml и .yml читаются как YAML (см. YAMLToJSON)
+ * Элементы include раскрываются (см. ResolveIncludes), их наличие в файле
+ * отмечается в поле Includes
  */
 func Read(filepath string) (*domains.TaskPattern, error) {
 
 	logger.DEBUG("JSON_TASK_READ: Starting read file: '" + filepath + "'")
 
+	taskPattern, readError := readFile(filepath)
+	if readError != nil {
+		return nil, readError
+	}
+
+	// Подключаем задания из других файлов и библиотек
+	taskPattern.Includes = hasIncludes(taskPattern.Tasks)
+	if includeError := ResolveIncludes(taskPattern, filepath); includeError != nil {
+		logger.ERROR("JSON_TASK_READ: Cannot resolve includes: " + includeError.Error())
+		return nil, includeError
+	}
+
+	return taskPattern, nil
+}
+
+func readFile(filepath string) (*domains.TaskPattern, error) {
+
 	// Создаём экземпляр будущего задания
 	var taskPattern domains.TaskPattern
 
@@ -27,6 +50,16 @@ func Read(filepath string) (*domains.TaskPattern, error) {
 		return nil, readFileError
 	}
 
+	// YAML задание преобразуется в JSON с теми же полями
+	if IsYAML(filepath) {
+		var convertError error
+		taskContent, convertError = YAMLToJSON(taskContent)
+		if convertError != nil {
+			logger.ERROR("JSON_TASK_READ: Cannot parse YAML file: '" + filepath + "'")
+			return nil, convertError
+		}
+	}
+
 	// Преобразуем прочитанные данные в объект
 	unmarshallError := json.Unmarshal(taskContent, &taskPattern)
 	if unmarshallError != nil {
@@ -37,10 +70,31 @@ func Read(filepath string) (*domains.TaskPattern, error) {
 	return &taskPattern, nil
 }
 
+/*
+ * ResultPath
+ *
+ * Путь для сохранения результата выполнения задания task, прочитанного из
+ * файла taskPath (см. Read)
+ * JSON файл задания перезаписывается результатом. Для YAML файла и файла
+ * с элементами include результат сохраняется рядом в <имя>.result.<ext>,
+ * что бы не потерять комментарии, якоря и слияния исходного файла и не
+ * сохранить в нём раскрытые подключения
+ */
+func ResultPath(taskPath string, task domains.TaskPattern) string {
+
+	if !IsYAML(taskPath) && !task.Includes {
+		return taskPath
+	}
+
+	extension := path.Ext(taskPath)
+	return strings.TrimSuffix(taskPath, extension) + ".result" + extension
+}
+
 /*
  * Write
  *
  * Сохранить файл задания
+ * В файлы .yaml и .yml задание сохраняется в формате YAML
  */
 func Write(filepath string, task domains.TaskPattern) error {
 
@@ -61,6 +115,21 @@ func Write(filepath string, task domains.TaskPattern) error {
 		return err
 	}
 
+	if IsYAML(filepath) {
+		taskJSON, err = JSONToYAML(taskJSON)
+		if err != nil {
+			logger.ERROR("JSON_TASK_WRITE: Cannot convert data to YAML: '" + filepath + "'")
+			return err
+		}
+	}
+
+	// Создаём директорию для файла задания, если она не существует
+	err = os.MkdirAll(path.Dir(filepath), os.ModePerm)
+	if err != nil {
+		logger.ERROR("JSON_TASK_WRITE: Cannot create directory: '" + filepath + "'")
+		return err
+	}
+
 	// WriteFile writes data to a file named by
 	// filename. If the file does not exist,
 	// WriteFile creates it with permissions perm
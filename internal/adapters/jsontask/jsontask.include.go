@@ -0,0 +1,252 @@
+package jsontask
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/andomize/network-automation-executor/internal/core/domains"
+	"github.com/andomize/network-automation-executor/internal/core/ports"
+	"github.com/andomize/network-automation-executor/internal/core/services/template"
+)
+
+// Префикс имени задания из библиотеки ("include": "@cisco/pager")
+const LibraryPrefix = "@"
+
+var (
+	librariesMutex sync.RWMutex
+	libraries      []string
+)
+
+/*
+ * SetLibraries
+ *
+ * Установить директории библиотек заданий, в которых ищутся подключения
+ * вида "@<name>" (в порядке перечисления)
+ */
+func SetLibraries(directories []string) {
+	librariesMutex.Lock()
+	defer librariesMutex.Unlock()
+
+	libraries = nil
+	for _, directory := range directories {
+		if len(directory) > 0 {
+			libraries = append(libraries, directory)
+		}
+	}
+}
+
+/*
+ * ResolveIncludes
+ *
+ * Раскрыть элементы include задания, прочитанного из файла path:
+ *  - "include": "common/pager.json" - файл относительно подключающего файла
+ *  - "include": "@cisco/pager" - файл из директории библиотеки
+ *    (расширение .json, .yaml или .yml можно не указывать)
+ * Элемент заменяется списком tasks подключаемого файла, в котором
+ * переменные {{name}} заменяются значениями variables элемента
+ * (по умолчанию - значениями variables подключаемого файла)
+ * Переменные без значения остаются для подстановки при выполнении
+ */
+func ResolveIncludes(task *domains.TaskPattern, path string) error {
+
+	if task.Tasks == nil {
+		return nil
+	}
+
+	absolutePath, absError := filepath.Abs(path)
+	if absError != nil {
+		return absError
+	}
+
+	tasks, resolveError := resolveTasks(task.Tasks, absolutePath, "tasks", []string{absolutePath})
+	if resolveError != nil {
+		return resolveError
+	}
+
+	task.Tasks = tasks
+	return nil
+}
+
+func resolveTasks(tasks *[]domains.Task, file, path string, stack []string) (*[]domains.Task, error) {
+
+	if tasks == nil {
+		return nil, nil
+	}
+
+	result := []domains.Task{}
+
+	for index, task := range *tasks {
+		taskPath := fmt.Sprintf("%s[%d]", path, index)
+
+		if len(task.Include) <= 0 {
+			for _, nested := range []struct {
+				name  string
+				tasks **[]domains.Task
+			}{{"tasks", &task.Tasks}, {"block", &task.Block}, {"rescue", &task.Rescue}, {"always", &task.Always}} {
+				subTasks, resolveError := resolveTasks(*nested.tasks, file, taskPath+"."+nested.name, stack)
+				if resolveError != nil {
+					return nil, resolveError
+				}
+				*nested.tasks = subTasks
+			}
+			result = append(result, task)
+			continue
+		}
+
+		// Элемент include содержит только путь и переменные
+		if len(task.Command) > 0 || task.Tasks != nil || task.When != nil || task.Params != (domains.Param{}) ||
+			task.Loop != nil || task.Block != nil || task.Rescue != nil || task.Always != nil {
+			return nil, includeError(file, taskPath, task.Include, ports.ERROR_SYNTAX_INCLUDE_MIXED)
+		}
+
+		includePath := locateInclude(task.Include, filepath.Dir(file))
+		if len(includePath) <= 0 {
+			return nil, includeError(file, taskPath, task.Include, ports.ERROR_SYNTAX_INCLUDE_NOT_FOUND)
+		}
+
+		for _, parent := range stack {
+			if parent == includePath {
+				return nil, includeError(file, taskPath, task.Include, ports.ERROR_SYNTAX_INCLUDE_CYCLE+
+					" ("+strings.Join(append(stack, includePath), " -> ")+")")
+			}
+		}
+
+		included, readError := readFile(includePath)
+		if readError != nil {
+			return nil, includeError(file, taskPath, task.Include, readError.Error())
+		}
+		if included.Tasks == nil || len(*included.Tasks) <= 0 {
+			return nil, includeError(file, taskPath, task.Include, ports.ERROR_SYNTAX_NO_TASKS)
+		}
+
+		expanded, resolveError := resolveTasks(included.Tasks, includePath, "tasks",
+			append(append([]string{}, stack...), includePath))
+		if resolveError != nil {
+			return nil, resolveError
+		}
+
+		variables := map[string]string{}
+		for name, value := range included.Variables {
+			variables[name] = value
+		}
+		for name, value := range task.Variables {
+			variables[name] = value
+		}
+
+		bindVariables(reflect.ValueOf(expanded), variables)
+		result = append(result, *expanded...)
+	}
+
+	return &result, nil
+}
+
+/*
+ * hasIncludes
+ *
+ * Задания содержат элементы include (в том числе вложенные)
+ */
+func hasIncludes(tasks *[]domains.Task) bool {
+
+	if tasks == nil {
+		return false
+	}
+
+	for _, task := range *tasks {
+		if len(task.Include) > 0 {
+			return true
+		}
+		for _, nested := range []*[]domains.Task{task.Tasks, task.Block, task.Rescue, task.Always} {
+			if hasIncludes(nested) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+/*
+ * locateInclude
+ *
+ * Найти подключаемый файл, возвращает абсолютный путь или пустую строку
+ */
+func locateInclude(include, directory string) string {
+
+	var bases []string
+	if strings.HasPrefix(include, LibraryPrefix) {
+		// Имя из библиотеки не может выходить за пределы директории библиотеки
+		name := filepath.Clean(strings.TrimPrefix(include, LibraryPrefix))
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return ""
+		}
+
+		librariesMutex.RLock()
+		for _, library := range libraries {
+			bases = append(bases, filepath.Join(library, name))
+		}
+		librariesMutex.RUnlock()
+	} else if filepath.IsAbs(include) {
+		bases = append(bases, include)
+	} else {
+		bases = append(bases, filepath.Join(directory, include))
+	}
+
+	for _, base := range bases {
+		for _, candidate := range []string{base, base + ".json", base + ".yaml", base + ".yml"} {
+			if info, statError := os.Stat(candidate); statError == nil && info.Mode().IsRegular() {
+				if absolutePath, absError := filepath.Abs(candidate); absError == nil {
+					return absolutePath
+				}
+			}
+		}
+	}
+
+	return ""
+}
+
+/*
+ * bindVariables
+ *
+ * Подставить значения переменных во всех строковых полях (см. template.Bind)
+ */
+func bindVariables(value reflect.Value, variables map[string]string) {
+
+	if len(variables) <= 0 {
+		return
+	}
+
+	switch value.Kind() {
+	case reflect.Ptr:
+		if !value.IsNil() {
+			bindVariables(value.Elem(), variables)
+		}
+	case reflect.Struct:
+		for index := 0; index < value.NumField(); index++ {
+			bindVariables(value.Field(index), variables)
+		}
+	case reflect.Slice:
+		for index := 0; index < value.Len(); index++ {
+			bindVariables(value.Index(index), variables)
+		}
+	case reflect.Map:
+		for _, key := range value.MapKeys() {
+			item := reflect.New(value.Type().Elem()).Elem()
+			item.Set(value.MapIndex(key))
+			bindVariables(item, variables)
+			value.SetMapIndex(key, item)
+		}
+	case reflect.String:
+		if value.CanSet() {
+			value.SetString(template.Bind(value.String(), variables))
+		}
+	}
+}
+
+func includeError(file, path, include, reason string) error {
+	return errors.New(file + ": " + path + ".include '" + include + "': " + reason)
+}
@@ -0,0 +1,180 @@
+package jsontask
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+/*
+ * IsYAML
+ *
+ * Является ли файл задания YAML файлом (расширение .yaml или .yml)
+ */
+func IsYAML(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+/*
+ * YAMLToJSON
+ *
+ * Преобразовать YAML задание в JSON с теми же полями
+ *  - якоря (&name), ссылки (*name) и ключи слияния (<<) раскрываются
+ *  - все скалярные значения становятся строками в том виде, в котором они
+ *    записаны в файле ("timeout": 30 -> "30", onExit: true -> "true"),
+ *    так как числовые и логические поля задания имеют тег ",string"
+ *  - null (~, пустое значение) остаётся null
+ */
+func YAMLToJSON(content []byte) ([]byte, error) {
+
+	var document yaml.Node
+	if unmarshalError := yaml.Unmarshal(content, &document); unmarshalError != nil {
+		return nil, unmarshalError
+	}
+
+	value, convertError := yamlValue(&document)
+	if convertError != nil {
+		return nil, convertError
+	}
+
+	return json.Marshal(value)
+}
+
+/*
+ * JSONToYAML
+ *
+ * Преобразовать JSON в YAML с сохранением порядка полей
+ */
+func JSONToYAML(content []byte) ([]byte, error) {
+
+	var document yaml.Node
+	if unmarshalError := yaml.Unmarshal(content, &document); unmarshalError != nil {
+		return nil, unmarshalError
+	}
+
+	// JSON разбирается как YAML во flow-стиле ({...}, [...]) с кавычками,
+	// сбрасываем стили для вывода в блочном стиле
+	resetStyle(&document)
+
+	var buffer bytes.Buffer
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(2)
+	if encodeError := encoder.Encode(&document); encodeError != nil {
+		return nil, encodeError
+	}
+	encoder.Close()
+
+	return buffer.Bytes(), nil
+}
+
+func yamlValue(node *yaml.Node) (interface{}, error) {
+
+	switch node.Kind {
+
+	case yaml.DocumentNode:
+		if len(node.Content) <= 0 {
+			return nil, nil
+		}
+		return yamlValue(node.Content[0])
+
+	case yaml.AliasNode:
+		return yamlValue(node.Alias)
+
+	case yaml.ScalarNode:
+		if node.Tag == "!!null" {
+			return nil, nil
+		}
+		return node.Value, nil
+
+	case yaml.SequenceNode:
+		list := []interface{}{}
+		for _, item := range node.Content {
+			value, valueError := yamlValue(item)
+			if valueError != nil {
+				return nil, valueError
+			}
+			list = append(list, value)
+		}
+		return list, nil
+
+	case yaml.MappingNode:
+		object := map[string]interface{}{}
+		for index := 0; index+1 < len(node.Content); index += 2 {
+			key, value := node.Content[index], node.Content[index+1]
+
+			// Ключ слияния: поля не заменяют явно указанные поля объекта
+			if key.Tag == "!!merge" {
+				if mergeError := yamlMerge(object, value); mergeError != nil {
+					return nil, mergeError
+				}
+				continue
+			}
+
+			converted, valueError := yamlValue(value)
+			if valueError != nil {
+				return nil, valueError
+			}
+			object[key.Value] = converted
+		}
+		return object, nil
+	}
+
+	return nil, errors.New("unsupported YAML node at line " + strconv.Itoa(node.Line))
+}
+
+/*
+ * yamlMerge
+ *
+ * Добавить в объект поля из значения ключа слияния (объект или список
+ * объектов; первый объект списка имеет приоритет)
+ */
+func yamlMerge(object map[string]interface{}, node *yaml.Node) error {
+
+	for node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+
+	var sources []*yaml.Node
+	switch node.Kind {
+	case yaml.MappingNode:
+		sources = []*yaml.Node{node}
+	case yaml.SequenceNode:
+		sources = node.Content
+	default:
+		return errors.New("merge key '<<' requires mapping at line " + strconv.Itoa(node.Line))
+	}
+
+	for _, source := range sources {
+		value, valueError := yamlValue(source)
+		if valueError != nil {
+			return valueError
+		}
+		fields, isObject := value.(map[string]interface{})
+		if !isObject {
+			return errors.New("merge key '<<' requires mapping at line " + strconv.Itoa(source.Line))
+		}
+		for name, field := range fields {
+			if _, exist := object[name]; !exist {
+				object[name] = field
+			}
+		}
+	}
+
+	return nil
+}
+
+func resetStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetStyle(child)
+	}
+}
@@ -1,25 +1,85 @@
 package logger
 
 import (
+	"fmt"
+	"io"
 	"log"
+	"sync"
+	"time"
 )
 
-func DEBUG(message string) {
+/*
+ * Logger
+ *
+ * Журнал отдельного исполнителя (например, хоста при параллельном выполнении)
+ * Сообщения дополняются префиксом и, при наличии, дублируются в отдельный файл
+ * Нулевой указатель *Logger допустим и соответствует общему журналу программы
+ */
+type Logger struct {
+	// Префикс сообщений, например имя хоста
+	prefix string
+
+	// Дополнительный получатель сообщений, например файл журнала хоста
+	writer io.Writer
+	mutex  sync.Mutex
+}
+
+func New(prefix string, writer io.Writer) *Logger {
+	return &Logger{prefix: prefix, writer: writer}
+}
+
+func (l *Logger) DEBUG(message string) {
 	if debugMode {
-		log.Println("[" + eDEBUG.PRI + "] " + message)
+		l.print(eDEBUG, message)
 	}
 }
 
+func (l *Logger) INFO(message string) {
+	l.print(eINFO, message)
+}
+
+func (l *Logger) WARNING(message string) {
+	l.print(eWARNING, message)
+}
+
+func (l *Logger) ERROR(message string) {
+	l.print(eERROR, message)
+}
+
+func (l *Logger) print(severity Severity, message string) {
+	line := "[" + severity.PRI + "] " + message
+
+	if l == nil {
+		log.Println(line)
+		return
+	}
+
+	if len(l.prefix) > 0 {
+		line = "[" + l.prefix + "] " + line
+	}
+	log.Println(line)
+
+	if l.writer != nil {
+		l.mutex.Lock()
+		fmt.Fprintln(l.writer, time.Now().Format("2006/01/02 15:04:05")+" "+line)
+		l.mutex.Unlock()
+	}
+}
+
+func DEBUG(message string) {
+	(*Logger)(nil).DEBUG(message)
+}
+
 func INFO(message string) {
-	log.Println("[" + eINFO.PRI + "] " + message)
+	(*Logger)(nil).INFO(message)
 }
 
 func WARNING(message string) {
-	log.Println("[" + eWARNING.PRI + "] " + message)
+	(*Logger)(nil).WARNING(message)
 }
 
 func ERROR(message string) {
-	log.Println("[" + eERROR.PRI + "] " + message)
+	(*Logger)(nil).ERROR(message)
 }
 
 func ModuleEnableDebug() {
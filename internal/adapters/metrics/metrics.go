@@ -0,0 +1,267 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andomize/network-automation-executor/internal/adapters/logger"
+)
+
+/*
+ * Метрики выполнения в формате Prometheus (text exposition format 0.0.4)
+ * Метрики накапливаются в пределах процесса и отдаются обработчиком Handler
+ */
+
+var (
+	connections = newCounter("executor_connections_total",
+		"Connection attempts by protocol and result (success or connection error code)",
+		"protocol", "result")
+
+	commandDuration = newHistogram("executor_command_duration_seconds",
+		"Latency of commands sent to devices by vendor",
+		[]float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+		"vendor")
+
+	promptFailures = newCounter("executor_prompt_failures_total",
+		"Prompt detection failures by vendor and error code",
+		"vendor", "reason")
+
+	receivedBytes = newCounter("executor_received_bytes_total",
+		"Bytes received from devices")
+
+	taskStatuses = newCounter("executor_tasks_total",
+		"Executed tasks by vendor and status",
+		"vendor", "status")
+
+	hostStatuses = newCounter("executor_hosts_total",
+		"Finished host executions by status",
+		"status")
+
+	collectors = []collector{connections, commandDuration, promptFailures,
+		receivedBytes, taskStatuses, hostStatuses}
+)
+
+// Попытка подключения: result - "success" или код ошибки подключения
+func ConnectionAttempt(protocol, result string) {
+	connections.add(1, protocol, result)
+}
+
+// Время выполнения команды на устройстве
+func CommandDuration(vendor string, duration time.Duration) {
+	commandDuration.observe(duration.Seconds(), vendor)
+}
+
+// Ошибка определения Prompt: reason - код ошибки
+func PromptFailure(vendor, reason string) {
+	promptFailures.add(1, vendor, reason)
+}
+
+// Получены данные от устройства
+func BytesReceived(count int) {
+	receivedBytes.add(float64(count))
+}
+
+// Установлен статус подзадачи
+func TaskStatus(vendor, status string) {
+	taskStatuses.add(1, vendor, status)
+}
+
+// Завершено выполнение задания на хосте
+func HostStatus(status string) {
+	hostStatuses.add(1, status)
+}
+
+/*
+ * Write
+ *
+ * Записать значения всех метрик
+ */
+func Write(w io.Writer) {
+	for _, c := range collectors {
+		c.write(w)
+	}
+}
+
+// Обработчик HTTP запросов метрик
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		Write(w)
+	})
+}
+
+/*
+ * Serve
+ *
+ * Отдавать метрики по адресу http://<address>/metrics
+ * Сервер работает до завершения программы
+ */
+func Serve(address string) error {
+
+	listener, listenError := net.Listen("tcp", address)
+	if listenError != nil {
+		return listenError
+	}
+
+	logger.INFO("METRICS: Listening on '" + listener.Addr().String() + "'")
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+
+	go func() {
+		serveError := http.Serve(listener, mux)
+		if serveError != nil && !errors.Is(serveError, http.ErrServerClosed) {
+			logger.ERROR("METRICS: Server stopped by reason: " + serveError.Error())
+		}
+	}()
+
+	return nil
+}
+
+type collector interface {
+	write(w io.Writer)
+}
+
+/*
+ * series
+ *
+ * Значения меток, сериализованные в ключ серии
+ */
+type series struct {
+	mutex  sync.Mutex
+	labels []string
+	keys   map[string][]string
+}
+
+func (s *series) key(values []string) string {
+	if len(values) != len(s.labels) {
+		panic(fmt.Sprintf("metrics: expected %v label values, got %v", len(s.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	if _, exist := s.keys[key]; !exist {
+		s.keys[key] = append([]string{}, values...)
+	}
+	return key
+}
+
+// Ключи серий в порядке сортировки (стабильный вывод)
+func (s *series) sorted() []string {
+	keys := make([]string, 0, len(s.keys))
+	for key := range s.keys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *series) format(key string, extra ...string) string {
+	var pairs []string
+	for index, label := range s.labels {
+		pairs = append(pairs, label+"=\""+escape(s.keys[key][index])+"\"")
+	}
+	for index := 0; index+1 < len(extra); index += 2 {
+		pairs = append(pairs, extra[index]+"=\""+extra[index+1]+"\"")
+	}
+	if len(pairs) <= 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+type counter struct {
+	series
+	name   string
+	help   string
+	values map[string]float64
+}
+
+func newCounter(name, help string, labels ...string) *counter {
+	return &counter{
+		series: series{labels: labels, keys: map[string][]string{}},
+		name:   name,
+		help:   help,
+		values: map[string]float64{},
+	}
+}
+
+func (c *counter) add(value float64, labels ...string) {
+	c.mutex.Lock()
+	c.values[c.key(labels)] += value
+	c.mutex.Unlock()
+}
+
+func (c *counter) write(w io.Writer) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	for _, key := range c.sorted() {
+		fmt.Fprintf(w, "%s%s %v\n", c.name, c.format(key), c.values[key])
+	}
+}
+
+type histogram struct {
+	series
+	name    string
+	help    string
+	buckets []float64
+	counts  map[string][]uint64
+	sums    map[string]float64
+	totals  map[string]uint64
+}
+
+func newHistogram(name, help string, buckets []float64, labels ...string) *histogram {
+	return &histogram{
+		series:  series{labels: labels, keys: map[string][]string{}},
+		name:    name,
+		help:    help,
+		buckets: buckets,
+		counts:  map[string][]uint64{},
+		sums:    map[string]float64{},
+		totals:  map[string]uint64{},
+	}
+}
+
+func (h *histogram) observe(value float64, labels ...string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	key := h.key(labels)
+	if h.counts[key] == nil {
+		h.counts[key] = make([]uint64, len(h.buckets))
+	}
+	for index, bound := range h.buckets {
+		if value <= bound {
+			h.counts[key][index]++
+		}
+	}
+	h.sums[key] += value
+	h.totals[key]++
+}
+
+func (h *histogram) write(w io.Writer) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	for _, key := range h.sorted() {
+		for index, bound := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %v\n", h.name,
+				h.format(key, "le", fmt.Sprint(bound)), h.counts[key][index])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %v\n", h.name, h.format(key, "le", "+Inf"), h.totals[key])
+		fmt.Fprintf(w, "%s_sum%s %v\n", h.name, h.format(key), h.sums[key])
+		fmt.Fprintf(w, "%s_count%s %v\n", h.name, h.format(key), h.totals[key])
+	}
+}
+
+func escape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(value)
+}
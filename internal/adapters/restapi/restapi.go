@@ -0,0 +1,242 @@
+package restapi
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/andomize/network-automation-executor/internal/adapters/logger"
+	"github.com/andomize/network-automation-executor/internal/adapters/metrics"
+	"github.com/andomize/network-automation-executor/internal/core/domains"
+	"github.com/andomize/network-automation-executor/internal/core/ports"
+	"github.com/andomize/network-automation-executor/internal/core/services/jobs"
+)
+
+/*
+ * RestAPI
+ *
+ * HTTP API режима сервера:
+ *  POST   /jobs                  - поставить задание в очередь
+ *  GET    /jobs                  - список заданий
+ *  GET    /jobs/<id>             - состояние задания
+ *  DELETE /jobs/<id>             - отменить задание (также POST /jobs/<id>/cancel)
+ *  GET    /jobs/<id>/result      - результат задания (?host=<имя> для инвентаря)
+ *  GET    /jobs/<id>/files       - список файлов выводов задания
+ *  GET    /jobs/<id>/files/<путь> - файл вывода задания
+ *  GET    /metrics               - метрики в формате Prometheus
+ * Если задан токен, то каждый запрос должен содержать заголовок
+ * "Authorization: Bearer <токен>"
+ */
+type RestAPI struct {
+	manager *jobs.Manager
+	server  *http.Server
+
+	// Токен доступа к API (пустой - без аутентификации)
+	token string
+}
+
+func NewRestAPI(manager *jobs.Manager, token string) *RestAPI {
+	api := &RestAPI{manager: manager, token: token}
+	api.server = &http.Server{Handler: api}
+	return api
+}
+
+/*
+ * RestAPI.Serve
+ *
+ * Принимать запросы на адресе (например, ":8080") до вызова Close
+ */
+func (a *RestAPI) Serve(address string) error {
+
+	listener, listenError := net.Listen("tcp", address)
+	if listenError != nil {
+		return listenError
+	}
+
+	logger.INFO("REST_API: Listening on '" + listener.Addr().String() + "'")
+
+	if serveError := a.server.Serve(listener); !errors.Is(serveError, http.ErrServerClosed) {
+		return serveError
+	}
+	return nil
+}
+
+func (a *RestAPI) Close() {
+	a.server.Close()
+}
+
+func (a *RestAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	logger.DEBUG("REST_API: Request '" + r.Method + "' from '" + r.RemoteAddr + "' for '" + r.URL.Path + "'")
+
+	if !Authorized(r.Header.Get("Authorization"), a.token) {
+		logger.WARNING("REST_API: Unauthorized request from '" + r.RemoteAddr + "'")
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+
+	if r.URL.Path == "/metrics" && r.Method == http.MethodGet {
+		metrics.Handler().ServeHTTP(w, r)
+		return
+	}
+
+	// /jobs/<id>/<действие>/<путь>
+	parts := strings.SplitN(strings.Trim(r.URL.Path, "/"), "/", 4)
+	if parts[0] != "jobs" {
+		writeError(w, http.StatusNotFound, errors.New("Not found"))
+		return
+	}
+
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodPost:
+		a.submit(w, r)
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, a.manager.List())
+	case len(parts) == 2 && r.Method == http.MethodGet:
+		a.get(w, parts[1])
+	case len(parts) == 2 && r.Method == http.MethodDelete,
+		len(parts) == 3 && parts[2] == "cancel" && r.Method == http.MethodPost:
+		a.cancel(w, parts[1])
+	case len(parts) == 3 && parts[2] == "result" && r.Method == http.MethodGet:
+		a.result(w, r, parts[1])
+	case len(parts) == 3 && parts[2] == "files" && r.Method == http.MethodGet:
+		a.files(w, parts[1])
+	case len(parts) == 4 && parts[2] == "files" && r.Method == http.MethodGet:
+		a.file(w, r, parts[1], parts[3])
+	default:
+		writeError(w, http.StatusNotFound, errors.New("Not found"))
+	}
+}
+
+func (a *RestAPI) submit(w http.ResponseWriter, r *http.Request) {
+
+	var request domains.JobRequest
+	if decodeError := json.NewDecoder(r.Body).Decode(&request); decodeError != nil {
+		writeError(w, http.StatusBadRequest, decodeError)
+		return
+	}
+
+	job, submitError := a.manager.Submit(request)
+	if submitError != nil {
+		writeError(w, http.StatusBadRequest, submitError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, job)
+}
+
+func (a *RestAPI) get(w http.ResponseWriter, id string) {
+	job, jobError := a.manager.Get(id)
+	if jobError != nil {
+		writeError(w, http.StatusNotFound, jobError)
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+func (a *RestAPI) cancel(w http.ResponseWriter, id string) {
+	job, cancelError := a.manager.Cancel(id)
+	if cancelError != nil {
+		status := http.StatusConflict
+		if cancelError.Error() == ports.ERROR_JOB_NOT_FOUND {
+			status = http.StatusNotFound
+		}
+		writeError(w, status, cancelError)
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+func (a *RestAPI) result(w http.ResponseWriter, r *http.Request, id string) {
+	resultPath, resultError := a.manager.ResultPath(id, r.URL.Query().Get("host"))
+	if resultError != nil {
+		writeError(w, http.StatusNotFound, resultError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	http.ServeFile(w, r, resultPath)
+}
+
+func (a *RestAPI) files(w http.ResponseWriter, id string) {
+
+	if _, jobError := a.manager.Get(id); jobError != nil {
+		writeError(w, http.StatusNotFound, jobError)
+		return
+	}
+
+	directory := a.manager.OutputDirectory(id)
+
+	var files = []string{}
+	filepath.Walk(directory, func(path string, info os.FileInfo, walkError error) error {
+		if walkError == nil && !info.IsDir() {
+			if relative, relError := filepath.Rel(directory, path); relError == nil {
+				files = append(files, filepath.ToSlash(relative))
+			}
+		}
+		return nil
+	})
+
+	writeJSON(w, http.StatusOK, files)
+}
+
+func (a *RestAPI) file(w http.ResponseWriter, r *http.Request, id, name string) {
+
+	if _, jobError := a.manager.Get(id); jobError != nil {
+		writeError(w, http.StatusNotFound, jobError)
+		return
+	}
+
+	// Файл должен находиться внутри директории выводов задания
+	directory := a.manager.OutputDirectory(id)
+	path := filepath.Join(directory, filepath.FromSlash(name))
+	if !strings.HasPrefix(path, directory+string(filepath.Separator)) {
+		writeError(w, http.StatusForbidden, errors.New("Path is outside of job directory"))
+		return
+	}
+
+	if info, statError := os.Stat(path); statError != nil || info.IsDir() {
+		writeError(w, http.StatusNotFound, errors.New("File not found"))
+		return
+	}
+
+	http.ServeFile(w, r, path)
+}
+
+/*
+ * Authorized
+ *
+ * Проверить значение заголовка Authorization ("Bearer <токен>")
+ * Пустой токен - аутентификация не требуется
+ */
+func Authorized(header, token string) bool {
+
+	if len(token) <= 0 {
+		return true
+	}
+
+	provided := strings.TrimPrefix(header, "Bearer ")
+	if provided == header {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "    ")
+	encoder.Encode(data)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,122 @@
+package transferserver
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/andomize/network-automation-executor/internal/adapters/filestorage"
+	"github.com/andomize/network-automation-executor/internal/adapters/logger"
+)
+
+/*
+ * TransferServer
+ *
+ * Встроенный сервер передачи файлов (TFTP и HTTP) для копирования файлов,
+ * инициируемого самим устройством (copy running-config tftp://...)
+ * Сервер работает только во время выполнения задания:
+ *  - Принятые от устройств файлы сохраняются в директорию выводов
+ *  - Устройствам отдаются только файлы из директории подготовленных файлов
+ */
+type TransferServer struct {
+	// Директория для сохранения принятых файлов
+	uploadStorage *filestorage.FileStorage
+
+	// Директория с подготовленными для отдачи файлами (может быть пустой)
+	stageDirectory string
+
+	// Запущенные службы
+	tftp *tftpServer
+	http *httpServer
+}
+
+func NewTransferServer(uploadDirectory, stageDirectory string) *TransferServer {
+	return &TransferServer{
+		uploadStorage:  filestorage.NewFileStorage(uploadDirectory),
+		stageDirectory: stageDirectory,
+	}
+}
+
+/*
+ * TransferServer.TFTPAddr
+ *
+ * Адрес, на котором запущен TFTP сервер (nil, если сервер не запущен)
+ */
+func (t *TransferServer) TFTPAddr() *net.UDPAddr {
+	if t.tftp == nil {
+		return nil
+	}
+	return t.tftp.conn.LocalAddr().(*net.UDPAddr)
+}
+
+/*
+ * TransferServer.HTTPAddr
+ *
+ * Адрес, на котором запущен HTTP сервер (nil, если сервер не запущен)
+ */
+func (t *TransferServer) HTTPAddr() *net.TCPAddr {
+	if t.http == nil {
+		return nil
+	}
+	return t.http.listener.Addr().(*net.TCPAddr)
+}
+
+/*
+ * TransferServer.Close
+ *
+ * Остановить все запущенные службы
+ */
+func (t *TransferServer) Close() {
+	logger.DEBUG("TRANSFER_SERVER: Closing transfer server")
+	if t.tftp != nil {
+		t.tftp.close()
+	}
+	if t.http != nil {
+		t.http.close()
+	}
+}
+
+/*
+ * TransferServer.uploadPath
+ *
+ * Путь для сохранения принимаемого файла (имя файла нормализуется)
+ */
+func (t *TransferServer) uploadPath(filename string) (string, error) {
+	return t.uploadStorage.Path(filepath.Base(filepath.Clean("/" + filename)))
+}
+
+/*
+ * TransferServer.stagePath
+ *
+ * Путь к отдаваемому файлу. Выход за пределы директории запрещён
+ */
+func (t *TransferServer) stagePath(filename string) (string, error) {
+	if len(t.stageDirectory) <= 0 {
+		return "", errors.New("Stage directory is not set")
+	}
+
+	path := filepath.Join(t.stageDirectory, filepath.Clean("/"+filename))
+	if !strings.HasPrefix(path, filepath.Clean(t.stageDirectory)+string(os.PathSeparator)) {
+		return "", errors.New("Filename is incorrect: '" + filename + "'")
+	}
+
+	return path, nil
+}
+
+/*
+ * AdvertiseAddress
+ *
+ * Определить локальный IP адрес, через который доступен удалённый хост
+ * Пакеты при этом не отправляются - используется только таблица маршрутизации
+ */
+func AdvertiseAddress(host string) (string, error) {
+	conn, dialError := net.Dial("udp", net.JoinHostPort(host, "69"))
+	if dialError != nil {
+		return "", dialError
+	}
+	defer conn.Close()
+
+	return conn.LocalAddr().(*net.UDPAddr).IP.String(), nil
+}
@@ -0,0 +1,87 @@
+package transferserver
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/andomize/network-automation-executor/internal/adapters/logger"
+)
+
+type httpServer struct {
+	listener net.Listener
+	server   *http.Server
+	parent   *TransferServer
+}
+
+/*
+ * TransferServer.StartHTTP
+ *
+ * Запустить HTTP сервер на указанном адресе (например, ":8080")
+ *  - GET/HEAD - отдать подготовленный файл
+ *  - PUT/POST - принять файл (тело запроса) в директорию выводов
+ */
+func (t *TransferServer) StartHTTP(address string) error {
+
+	listener, listenError := net.Listen("tcp", address)
+	if listenError != nil {
+		return listenError
+	}
+
+	t.http = &httpServer{listener: listener, parent: t}
+	t.http.server = &http.Server{Handler: t.http}
+	go t.http.server.Serve(listener)
+
+	logger.INFO("TRANSFER_SERVER: HTTP server listening on '" + listener.Addr().String() + "'")
+	return nil
+}
+
+func (s *httpServer) close() {
+	s.server.Close()
+}
+
+func (s *httpServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	logger.DEBUG("TRANSFER_SERVER: HTTP request '" + r.Method + "' from '" + r.RemoteAddr +
+		"' for file '" + r.URL.Path + "'")
+
+	switch r.Method {
+
+	case http.MethodGet, http.MethodHead:
+		path, pathError := s.parent.stagePath(r.URL.Path)
+		if pathError != nil {
+			http.Error(w, pathError.Error(), http.StatusForbidden)
+			return
+		}
+		http.ServeFile(w, r, path)
+
+	case http.MethodPut, http.MethodPost:
+		path, pathError := s.parent.uploadPath(r.URL.Path)
+		if pathError != nil {
+			http.Error(w, pathError.Error(), http.StatusForbidden)
+			return
+		}
+
+		file, createError := os.Create(path)
+		if createError != nil {
+			http.Error(w, createError.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer file.Close()
+
+		if _, copyError := io.Copy(file, r.Body); copyError != nil {
+			logger.ERROR("TRANSFER_SERVER: HTTP upload of file '" + r.URL.Path + "' from '" +
+				r.RemoteAddr + "' failed by reason: " + copyError.Error())
+			http.Error(w, copyError.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		logger.INFO("TRANSFER_SERVER: HTTP upload of file '" + r.URL.Path + "' from '" +
+			r.RemoteAddr + "' successful")
+		w.WriteHeader(http.StatusCreated)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,390 @@
+package transferserver
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andomize/network-automation-executor/internal/adapters/logger"
+)
+
+// Коды операций TFTP (RFC 1350, RFC 2347)
+const (
+	tftpOpRRQ   = 1
+	tftpOpWRQ   = 2
+	tftpOpDATA  = 3
+	tftpOpACK   = 4
+	tftpOpERROR = 5
+	tftpOpOACK  = 6
+)
+
+// Коды ошибок TFTP
+const (
+	tftpErrUndefined  = 0
+	tftpErrNotFound   = 1
+	tftpErrAccess     = 2
+	tftpErrIllegalOp  = 4
+	tftpErrUnknownTID = 5
+	tftpErrFileExists = 6
+)
+
+// Параметры передачи TFTP
+const (
+	tftpBlockSize       = 512
+	tftpBlockSizeMax    = 65464
+	tftpRetries         = 5
+	tftpTimeout         = 5 * time.Second
+	tftpPacketSizeLimit = 65536
+)
+
+type tftpServer struct {
+	conn   *net.UDPConn
+	parent *TransferServer
+}
+
+/*
+ * TransferServer.StartTFTP
+ *
+ * Запустить TFTP сервер на указанном адресе (например, ":69")
+ */
+func (t *TransferServer) StartTFTP(address string) error {
+
+	udpAddr, resolveError := net.ResolveUDPAddr("udp", address)
+	if resolveError != nil {
+		return resolveError
+	}
+
+	conn, listenError := net.ListenUDP("udp", udpAddr)
+	if listenError != nil {
+		return listenError
+	}
+
+	t.tftp = &tftpServer{conn: conn, parent: t}
+	go t.tftp.serve()
+
+	logger.INFO("TRANSFER_SERVER: TFTP server listening on '" + conn.LocalAddr().String() + "'")
+	return nil
+}
+
+func (s *tftpServer) close() {
+	s.conn.Close()
+}
+
+/*
+ * tftpServer.serve
+ *
+ * Принимаем запросы на чтение/запись, каждая передача выполняется в отдельной
+ * горутине с отдельного порта (TID) согласно RFC 1350
+ */
+func (s *tftpServer) serve() {
+	buffer := make([]byte, tftpPacketSizeLimit)
+
+	for {
+		n, remote, readError := s.conn.ReadFromUDP(buffer)
+		if readError != nil {
+			// Сервер остановлен
+			return
+		}
+
+		packet := make([]byte, n)
+		copy(packet, buffer[:n])
+
+		go s.handle(packet, remote)
+	}
+}
+
+func (s *tftpServer) handle(packet []byte, remote *net.UDPAddr) {
+
+	// Открываем новый порт для передачи на том же адресе, что и сервер
+	localAddr := &net.UDPAddr{IP: s.conn.LocalAddr().(*net.UDPAddr).IP}
+	conn, listenError := net.ListenUDP("udp", localAddr)
+	if listenError != nil {
+		logger.ERROR("TRANSFER_SERVER: TFTP cannot open transfer port: " + listenError.Error())
+		return
+	}
+	defer conn.Close()
+
+	if len(packet) < 2 {
+		return
+	}
+
+	opcode := binary.BigEndian.Uint16(packet[:2])
+	if opcode != tftpOpRRQ && opcode != tftpOpWRQ {
+		tftpSendError(conn, remote, tftpErrIllegalOp, "Illegal TFTP operation")
+		return
+	}
+
+	filename, mode, options, parseError := tftpParseRequest(packet[2:])
+	if parseError != nil {
+		tftpSendError(conn, remote, tftpErrIllegalOp, parseError.Error())
+		return
+	}
+
+	logger.DEBUG(fmt.Sprintf("TRANSFER_SERVER: TFTP request '%v' from '%v' for file '%s', mode '%s'",
+		opcode, remote, filename, mode))
+
+	var transferError error
+	if opcode == tftpOpRRQ {
+		transferError = s.read(conn, remote, filename, options)
+	} else {
+		transferError = s.write(conn, remote, filename, options)
+	}
+
+	if transferError != nil {
+		logger.ERROR("TRANSFER_SERVER: TFTP transfer of file '" + filename + "' from '" +
+			remote.String() + "' failed by reason: " + transferError.Error())
+		return
+	}
+
+	logger.INFO("TRANSFER_SERVER: TFTP transfer of file '" + filename + "' from '" +
+		remote.String() + "' successful")
+}
+
+/*
+ * tftpServer.read
+ *
+ * Отдать устройству подготовленный файл
+ */
+func (s *tftpServer) read(conn *net.UDPConn, remote *net.UDPAddr, filename string, options map[string]string) error {
+
+	path, pathError := s.parent.stagePath(filename)
+	if pathError != nil {
+		tftpSendError(conn, remote, tftpErrAccess, pathError.Error())
+		return pathError
+	}
+
+	file, openError := os.Open(path)
+	if openError != nil {
+		tftpSendError(conn, remote, tftpErrNotFound, "File not found")
+		return openError
+	}
+	defer file.Close()
+
+	stat, statError := file.Stat()
+	if statError != nil {
+		tftpSendError(conn, remote, tftpErrUndefined, statError.Error())
+		return statError
+	}
+
+	// Согласование опций (RFC 2348, RFC 2349)
+	blockSize, accepted := tftpNegotiate(options)
+	if _, ok := options["tsize"]; ok {
+		accepted["tsize"] = strconv.FormatInt(stat.Size(), 10)
+	}
+	reply := make([]byte, tftpPacketSizeLimit)
+	if len(accepted) > 0 {
+		if exchangeError := tftpExchange(conn, remote, tftpOACK(accepted), reply, tftpIsAck(0)); exchangeError != nil {
+			return exchangeError
+		}
+	}
+
+	buffer := make([]byte, blockSize)
+	for block := uint16(1); ; block++ {
+		n, readError := io.ReadFull(file, buffer)
+		if readError != nil && readError != io.EOF && readError != io.ErrUnexpectedEOF {
+			tftpSendError(conn, remote, tftpErrUndefined, readError.Error())
+			return readError
+		}
+
+		if exchangeError := tftpExchange(conn, remote, tftpData(block, buffer[:n]), reply, tftpIsAck(block)); exchangeError != nil {
+			return exchangeError
+		}
+
+		// Последний блок меньше размера блока
+		if n < blockSize {
+			return nil
+		}
+	}
+}
+
+/*
+ * tftpServer.write
+ *
+ * Принять файл от устройства и сохранить его в директорию выводов
+ */
+func (s *tftpServer) write(conn *net.UDPConn, remote *net.UDPAddr, filename string, options map[string]string) error {
+
+	path, pathError := s.parent.uploadPath(filename)
+	if pathError != nil {
+		tftpSendError(conn, remote, tftpErrAccess, pathError.Error())
+		return pathError
+	}
+
+	file, createError := os.Create(path)
+	if createError != nil {
+		tftpSendError(conn, remote, tftpErrFileExists, createError.Error())
+		return createError
+	}
+	defer file.Close()
+
+	blockSize, accepted := tftpNegotiate(options)
+	if _, ok := options["tsize"]; ok {
+		accepted["tsize"] = options["tsize"]
+	}
+
+	// Первый ответ - OACK, если опции приняты, иначе ACK 0
+	reply := tftpAck(0)
+	if len(accepted) > 0 {
+		reply = tftpOACK(accepted)
+	}
+
+	buffer := make([]byte, tftpPacketSizeLimit)
+	for block := uint16(1); ; block++ {
+
+		var data []byte
+		exchangeError := tftpExchange(conn, remote, reply, buffer, func(packet []byte) bool {
+			if len(packet) < 4 || binary.BigEndian.Uint16(packet[:2]) != tftpOpDATA ||
+				binary.BigEndian.Uint16(packet[2:4]) != block {
+				return false
+			}
+			data = packet[4:]
+			return true
+		})
+		if exchangeError != nil {
+			return exchangeError
+		}
+
+		if _, writeError := file.Write(data); writeError != nil {
+			tftpSendError(conn, remote, tftpErrUndefined, writeError.Error())
+			return writeError
+		}
+
+		reply = tftpAck(block)
+
+		// Последний блок меньше размера блока - подтверждаем и завершаем
+		if len(data) < blockSize {
+			_, sendError := conn.WriteToUDP(reply, remote)
+			return sendError
+		}
+	}
+}
+
+/*
+ * tftpExchange
+ *
+ * Отправить пакет и дождаться ожидаемого ответа с повторной отправкой по таймауту
+ */
+func tftpExchange(conn *net.UDPConn, remote *net.UDPAddr, packet, buffer []byte, expected func([]byte) bool) error {
+
+	for attempt := 0; attempt < tftpRetries; attempt++ {
+		if _, sendError := conn.WriteToUDP(packet, remote); sendError != nil {
+			return sendError
+		}
+
+		deadline := time.Now().Add(tftpTimeout)
+		for time.Now().Before(deadline) {
+			conn.SetReadDeadline(deadline)
+			n, from, readError := conn.ReadFromUDP(buffer)
+			if readError != nil {
+				break
+			}
+
+			// Пакеты с чужого адреса/порта игнорируем
+			if !from.IP.Equal(remote.IP) || from.Port != remote.Port {
+				tftpSendError(conn, from, tftpErrUnknownTID, "Unknown transfer ID")
+				continue
+			}
+
+			if n >= 2 && binary.BigEndian.Uint16(buffer[:2]) == tftpOpERROR {
+				// Короткий пакет ERROR (без кода ошибки) - без текста сообщения
+				if n < 4 {
+					return errors.New("Remote side error")
+				}
+				return errors.New("Remote side error: " + strings.Trim(string(buffer[4:n]), "\x00"))
+			}
+
+			if expected(buffer[:n]) {
+				return nil
+			}
+		}
+	}
+
+	return errors.New("TFTP timeout")
+}
+
+func tftpIsAck(block uint16) func([]byte) bool {
+	return func(packet []byte) bool {
+		return len(packet) >= 4 && binary.BigEndian.Uint16(packet[:2]) == tftpOpACK &&
+			binary.BigEndian.Uint16(packet[2:4]) == block
+	}
+}
+
+/*
+ * tftpParseRequest
+ *
+ * Разбор запроса RRQ/WRQ: filename\0mode\0[option\0value\0]...
+ */
+func tftpParseRequest(payload []byte) (string, string, map[string]string, error) {
+	fields := strings.Split(string(payload), "\x00")
+	if len(fields) < 2 || len(fields[0]) <= 0 {
+		return "", "", nil, errors.New("Malformed request")
+	}
+
+	options := map[string]string{}
+	for idx := 2; idx+1 < len(fields); idx += 2 {
+		options[strings.ToLower(fields[idx])] = fields[idx+1]
+	}
+
+	return fields[0], strings.ToLower(fields[1]), options, nil
+}
+
+/*
+ * tftpNegotiate
+ *
+ * Согласование размера блока, возвращает размер блока и принятые опции
+ */
+func tftpNegotiate(options map[string]string) (int, map[string]string) {
+	accepted := map[string]string{}
+	blockSize := tftpBlockSize
+
+	if value, ok := options["blksize"]; ok {
+		if size, convError := strconv.Atoi(value); convError == nil && size >= 8 {
+			if size > tftpBlockSizeMax {
+				size = tftpBlockSizeMax
+			}
+			blockSize = size
+			accepted["blksize"] = strconv.Itoa(size)
+		}
+	}
+
+	return blockSize, accepted
+}
+
+func tftpData(block uint16, data []byte) []byte {
+	packet := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint16(packet[0:2], tftpOpDATA)
+	binary.BigEndian.PutUint16(packet[2:4], block)
+	copy(packet[4:], data)
+	return packet
+}
+
+func tftpAck(block uint16) []byte {
+	packet := make([]byte, 4)
+	binary.BigEndian.PutUint16(packet[0:2], tftpOpACK)
+	binary.BigEndian.PutUint16(packet[2:4], block)
+	return packet
+}
+
+func tftpOACK(options map[string]string) []byte {
+	var packet bytes.Buffer
+	binary.Write(&packet, binary.BigEndian, uint16(tftpOpOACK))
+	for name, value := range options {
+		packet.WriteString(name + "\x00" + value + "\x00")
+	}
+	return packet.Bytes()
+}
+
+func tftpSendError(conn *net.UDPConn, remote *net.UDPAddr, code uint16, message string) {
+	var packet bytes.Buffer
+	binary.Write(&packet, binary.BigEndian, uint16(tftpOpERROR))
+	binary.Write(&packet, binary.BigEndian, code)
+	packet.WriteString(message + "\x00")
+	conn.WriteToUDP(packet.Bytes(), remote)
+}
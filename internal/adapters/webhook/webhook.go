@@ -0,0 +1,286 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/andomize/network-automation-executor/internal/adapters/logger"
+	"github.com/andomize/network-automation-executor/internal/core/domains"
+	"github.com/andomize/network-automation-executor/internal/core/ports"
+)
+
+/*
+ * Notifier
+ *
+ * Отправка уведомлений о результатах выполнения заданий (JSON POST)
+ * Уведомление отправляется каждому webhook, подписанному на событие:
+ *  completed        - выполнение на хосте завершено (с любым статусом)
+ *  failed           - выполнение на хосте завершено с ошибкой
+ *  autotests-failed - автотесты задания не пройдены
+ *  task             - выполнена подзадача с именем из списка tasks
+ */
+type Notifier struct {
+	webhooks []domains.Webhook
+	client   *http.Client
+
+	// Уведомления отправляются в фоне, Close ожидает завершения отправки
+	pending sync.WaitGroup
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+/*
+ * Read
+ *
+ * Прочитать файл с настройками webhook
+ */
+func Read(filepath string) (*Notifier, error) {
+
+	logger.DEBUG("WEBHOOK_READ: Starting read file: '" + filepath + "'")
+
+	configContent, readError := ioutil.ReadFile(filepath)
+	if readError != nil {
+		logger.ERROR("WEBHOOK_READ: Cannot read file: '" + filepath + "'")
+		return nil, readError
+	}
+
+	var config domains.WebhookConfig
+	if unmarshalError := json.Unmarshal(configContent, &config); unmarshalError != nil {
+		logger.ERROR("WEBHOOK_READ: Cannot unmarshall file: '" + filepath + "'")
+		return nil, unmarshalError
+	}
+
+	return NewNotifier(config.Webhooks)
+}
+
+func NewNotifier(webhooks []domains.Webhook) (*Notifier, error) {
+
+	for index := range webhooks {
+		webhook := &webhooks[index]
+
+		if len(webhook.URL) <= 0 {
+			return nil, fmt.Errorf("Webhook #%v does not contain url", index+1)
+		}
+		for _, event := range webhook.Events {
+			switch event {
+			case ports.EVENT_COMPLETED, ports.EVENT_FAILED, ports.EVENT_AUTOTESTS_FAILED, ports.EVENT_TASK:
+			default:
+				return nil, errors.New("Webhook '" + webhook.URL + "' contains unknown event '" + event + "'")
+			}
+		}
+
+		// Без событий и имён подзадач webhook получает уведомления о завершении
+		if len(webhook.Events) <= 0 && len(webhook.Tasks) <= 0 {
+			webhook.Events = []string{ports.EVENT_COMPLETED}
+		}
+		if len(webhook.Tasks) > 0 && !contains(webhook.Events, ports.EVENT_TASK) {
+			webhook.Events = append(webhook.Events, ports.EVENT_TASK)
+		}
+
+		if webhook.Retries <= 0 {
+			webhook.Retries = ports.WEBHOOK_RETRIES
+		}
+		if webhook.RetryDelay <= 0 {
+			webhook.RetryDelay = ports.WEBHOOK_RETRY_DELAY
+		}
+		if webhook.Timeout <= 0 {
+			webhook.Timeout = ports.WEBHOOK_TIMEOUT
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Notifier{webhooks: webhooks, client: &http.Client{}, ctx: ctx, cancel: cancel}, nil
+}
+
+/*
+ * Notifier.Notify
+ *
+ * Отправить уведомления о результате выполнения задания на хосте
+ * Отправка выполняется в фоне и не задерживает выполнение заданий,
+ * завершения отправки ожидает Close
+ */
+func (n *Notifier) Notify(task domains.TaskPattern, result domains.HostResult) {
+
+	if n == nil || len(n.webhooks) <= 0 {
+		return
+	}
+
+	base := domains.Notification{
+		Name:       result.Name,
+		Host:       result.Host,
+		Status:     result.Status,
+		Error:      result.Error,
+		FailedTask: result.FailedTask,
+		Autotests:  result.Autotests,
+		Duration:   result.Duration,
+		Time:       time.Now().Format(time.RFC3339),
+	}
+
+	for _, webhook := range n.webhooks {
+
+		if contains(webhook.Events, ports.EVENT_COMPLETED) {
+			n.deliver(webhook, event(base, ports.EVENT_COMPLETED))
+		}
+		if contains(webhook.Events, ports.EVENT_FAILED) && result.Status == ports.PIPE_STATUS_FAIL {
+			n.deliver(webhook, event(base, ports.EVENT_FAILED))
+		}
+		if contains(webhook.Events, ports.EVENT_AUTOTESTS_FAILED) && result.Autotests == ports.PIPE_STATUS_FAIL {
+			n.deliver(webhook, event(base, ports.EVENT_AUTOTESTS_FAILED))
+		}
+
+		// Уведомления о выполненных подзадачах с указанными именами
+		if len(webhook.Tasks) > 0 {
+			walk(task.Tasks, func(t domains.Task) {
+				if len(t.Name) > 0 && len(t.Status) > 0 && contains(webhook.Tasks, t.Name) {
+					notification := event(base, ports.EVENT_TASK)
+					notification.Task, notification.TaskStatus = t.Name, t.Status
+					n.deliver(webhook, notification)
+				}
+			})
+		}
+	}
+}
+
+/*
+ * Notifier.Close
+ *
+ * Дождаться отправки уведомлений. При отмене контекста повторы отправки
+ * прекращаются, ожидаются только выполняемые запросы
+ */
+func (n *Notifier) Close(ctx context.Context) {
+
+	if n == nil {
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		n.pending.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		n.cancel()
+		<-done
+	}
+	n.cancel()
+}
+
+/*
+ * Notifier.deliver
+ *
+ * Отправить уведомление в фоне
+ */
+func (n *Notifier) deliver(webhook domains.Webhook, notification domains.Notification) {
+	n.pending.Add(1)
+	go func() {
+		defer n.pending.Done()
+		n.send(webhook, notification)
+	}()
+}
+
+/*
+ * Notifier.send
+ *
+ * Отправить уведомление с повторами при ошибке
+ * Ошибкой считается отсутствие ответа или код ответа, отличный от 2xx
+ * Ожидание перед повтором прерывается при закрытии Notifier
+ */
+func (n *Notifier) send(webhook domains.Webhook, notification domains.Notification) {
+
+	body, marshalError := json.Marshal(notification)
+	if marshalError != nil {
+		logger.ERROR("WEBHOOK_SEND: Cannot marshal notification: " + marshalError.Error())
+		return
+	}
+
+	for attempt := 1; attempt <= webhook.Retries+1; attempt++ {
+
+		sendError := n.post(webhook, body)
+		if sendError == nil {
+			logger.DEBUG("WEBHOOK_SEND: Notification '" + notification.Event + "' for host '" +
+				notification.Host + "' sent to '" + webhook.URL + "'")
+			return
+		}
+
+		logger.WARNING(fmt.Sprintf("WEBHOOK_SEND: Attempt %v of sending notification to '%s' failed by reason: %s",
+			attempt, webhook.URL, sendError.Error()))
+
+		if attempt <= webhook.Retries {
+			select {
+			case <-time.After(time.Duration(webhook.RetryDelay) * time.Second):
+			case <-n.ctx.Done():
+				logger.ERROR("WEBHOOK_SEND: Retries of notification '" + notification.Event + "' to '" +
+					webhook.URL + "' cancelled")
+				return
+			}
+		}
+	}
+
+	logger.ERROR("WEBHOOK_SEND: Cannot send notification '" + notification.Event + "' to '" + webhook.URL + "'")
+}
+
+func (n *Notifier) post(webhook domains.Webhook, body []byte) error {
+
+	request, requestError := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if requestError != nil {
+		return requestError
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "network-automation-executor/"+ports.VERSION)
+	for name, value := range webhook.Headers {
+		request.Header.Set(name, value)
+	}
+
+	client := *n.client
+	client.Timeout = time.Duration(webhook.Timeout) * time.Second
+
+	response, responseError := client.Do(request)
+	if responseError != nil {
+		return responseError
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return errors.New("Unexpected response status: " + response.Status)
+	}
+
+	return nil
+}
+
+func event(notification domains.Notification, name string) domains.Notification {
+	notification.Event = name
+	return notification
+}
+
+func walk(tasks *[]domains.Task, visit func(task domains.Task)) {
+	if tasks == nil {
+		return
+	}
+	for _, task := range *tasks {
+		visit(task)
+		walk(task.Tasks, visit)
+		walk(task.Block, visit)
+		walk(task.Rescue, visit)
+		walk(task.Always, visit)
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, item := range values {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -11,6 +11,9 @@ type TaskPattern struct {
 	Settings      *Setting          `json:"settings,omitempty"`
 	Variables     map[string]string `json:"variables,omitempty"`
 	Autotests     *[]When           `json:"autotests,omitempty"`
+
+	// Исходный файл задания содержал элементы include (раскрываются при чтении)
+	Includes bool `json:"-"`
 }
 
 type Setting struct {
@@ -18,12 +21,40 @@ type Setting struct {
 }
 
 type Task struct {
-	Command string  `json:"command,omitempty"`
-	Status  string  `json:"status,omitempty"`
-	Name    string  `json:"name,omitempty"`
-	Params  Param   `json:"params"`
-	Tasks   *[]Task `json:"tasks,omitempty"`
-	When    *[]When `json:"when,omitempty"`
+	Command  string  `json:"command,omitempty"`
+	Status   string  `json:"status,omitempty"`
+	Name     string  `json:"name,omitempty"`
+	Checksum string  `json:"checksum,omitempty"`
+	Params   Param   `json:"params"`
+	Tasks    *[]Task `json:"tasks,omitempty"`
+	When     *[]When `json:"when,omitempty"`
+
+	// Повторение задания для каждого элемента списка
+	Loop *Loop `json:"loop,omitempty"`
+
+	// Группа заданий с обработкой ошибок: rescue выполняется при ошибке
+	// задания из block, always - в любом случае
+	Block  *[]Task `json:"block,omitempty"`
+	Rescue *[]Task `json:"rescue,omitempty"`
+	Always *[]Task `json:"always,omitempty"`
+
+	// Подключение списка заданий из файла или библиотеки с параметрами
+	// (раскрывается при чтении файла задания)
+	Include   string            `json:"include,omitempty"`
+	Variables map[string]string `json:"variables,omitempty"`
+}
+
+type Loop struct {
+	// Source: inline list, numeric range "start..end", list variable
+	// (values separated by separator) or CSV file with header row
+	Items     []string `json:"items,omitempty"`
+	Range     string   `json:"range,omitempty"`
+	Variable  string   `json:"variable,omitempty"`
+	Separator string   `json:"separator,omitempty"`
+	CSV       string   `json:"csv,omitempty"`
+
+	// Variable name for element (default "item"), CSV binds header fields
+	As string `json:"as,omitempty"`
 }
 
 type Param struct {
@@ -34,6 +65,33 @@ type Param struct {
 	CommandRepeatAllowed bool   `json:"commandRepeatAllowed,string,omitempty"`
 	Filter               string `json:"filter,omitempty"`
 	FilterExclude        string `json:"filterExclude,omitempty"`
+
+	// Повторение команды, пока вывод не совпадёт с регулярным выражением until
+	// (не более retries попыток с паузой delay секунд)
+	Until   string `json:"until,omitempty"`
+	Retries int    `json:"retries,string,omitempty"`
+	Delay   int    `json:"delay,string,omitempty"`
+
+	// Сохранение групп регулярного выражения в переменные без подзаданий
+	// (first, last, join или count), разделитель для join
+	Register          string `json:"register,omitempty"`
+	RegisterMode      string `json:"registerMode,omitempty"`
+	RegisterSeparator string `json:"registerSeparator,omitempty"`
+
+	// Отправка команды без ожидания Prompt (reload, reboot и т.п.)
+	FireAndForget bool         `json:"fireAndForget,string,omitempty"`
+	WaitReturn    int          `json:"waitReturn,string,omitempty"`
+	Responders    *[]Responder `json:"responders,omitempty"`
+
+	// Передача файлов по SCP/SFTP вместо отправки команды
+	Transfer   string `json:"transfer,omitempty"`
+	RemoteFile string `json:"remoteFile,omitempty"`
+	LocalFile  string `json:"localFile,omitempty"`
+}
+
+type Responder struct {
+	Expect string `json:"expect,omitempty"`
+	Send   string `json:"send,omitempty"`
 }
 
 type When struct {
@@ -50,7 +108,161 @@ type When struct {
 	IfValue    string `json:"ifValue,omitempty"`
 	IfValueNot string `json:"ifValueNot,omitempty"`
 
+	// Software Version Comparison: version is captured from output of named
+	// task (extract or known version formats) or taken from variable
+	IfVersionGt  string `json:"ifVersionGt,omitempty"`
+	IfVersionGte string `json:"ifVersionGte,omitempty"`
+	IfVersionLt  string `json:"ifVersionLt,omitempty"`
+	IfVersionLte string `json:"ifVersionLte,omitempty"`
+
+	// Numeric Comparison: number is captured from output of named task
+	// (extract) or taken from variable, between is inclusive "min..max"
+	IfNumberEq      string `json:"ifNumberEq,omitempty"`
+	IfNumberGt      string `json:"ifNumberGt,omitempty"`
+	IfNumberGte     string `json:"ifNumberGte,omitempty"`
+	IfNumberLt      string `json:"ifNumberLt,omitempty"`
+	IfNumberLte     string `json:"ifNumberLte,omitempty"`
+	IfNumberBetween string `json:"ifNumberBetween,omitempty"`
+
+	// Regular expression capturing value from output of named task
+	// (first group or whole match)
+	Extract string `json:"extract,omitempty"`
+
+	// Logical Groups (nested conditions)
+	All *[]When `json:"all,omitempty"`
+	Any *[]When `json:"any,omitempty"`
+	Not *When   `json:"not,omitempty"`
+
 	// Actions
 	OnMove string `json:"onMove,omitempty"`
 	OnExit bool   `json:"onExit,string,omitempty"`
 }
+
+type Inventory struct {
+	Variables map[string]string          `json:"variables,omitempty"`
+	Hosts     map[string]*InventoryHost  `json:"hosts"`
+	Groups    map[string]*InventoryGroup `json:"groups,omitempty"`
+}
+
+type InventoryHost struct {
+	Host      string            `json:"host,omitempty"`
+	Variables map[string]string `json:"variables,omitempty"`
+}
+
+type InventoryGroup struct {
+	Hosts     []string          `json:"hosts"`
+	Variables map[string]string `json:"variables,omitempty"`
+}
+
+type Target struct {
+	Name      string            `json:"name"`
+	Host      string            `json:"host"`
+	Groups    []string          `json:"groups,omitempty"`
+	Variables map[string]string `json:"variables,omitempty"`
+}
+
+type HostResult struct {
+	Name       string `json:"name"`
+	Host       string `json:"host"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	ResultPath string `json:"result,omitempty"`
+	Duration   string `json:"duration,omitempty"`
+	Autotests  string `json:"autotests,omitempty"`
+	FailedTask string `json:"failedTask,omitempty"`
+	Wave       int    `json:"wave,omitempty"`
+}
+
+type JobRequest struct {
+	Task      *TaskPattern      `json:"task"`
+	Host      string            `json:"host,omitempty"`
+	Inventory string            `json:"inventory,omitempty"`
+	Variables map[string]string `json:"variables,omitempty"`
+}
+
+type Job struct {
+	ID        string            `json:"id"`
+	Status    string            `json:"status"`
+	Error     string            `json:"error,omitempty"`
+	Host      string            `json:"host,omitempty"`
+	Inventory string            `json:"inventory,omitempty"`
+	Variables map[string]string `json:"variables,omitempty"`
+	Created   string            `json:"created"`
+	Started   string            `json:"started,omitempty"`
+	Finished  string            `json:"finished,omitempty"`
+	Hosts     []HostResult      `json:"hosts,omitempty"`
+}
+
+type ScheduleConfig struct {
+	Schedules []Schedule `json:"schedules"`
+}
+
+type Schedule struct {
+	Name        string `json:"name"`
+	Cron        string `json:"cron"`
+	Task        string `json:"task"`
+	Output      string `json:"output"`
+	Inventory   string `json:"inventory,omitempty"`
+	Limit       string `json:"limit,omitempty"`
+	Workers     int    `json:"workers,string,omitempty"`
+	GroupLimits string `json:"groupLimits,omitempty"`
+	HostTimeout int    `json:"hostTimeout,string,omitempty"`
+}
+
+type ScheduleRun struct {
+	Schedule  string `json:"schedule"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	Started   string `json:"started"`
+	Finished  string `json:"finished,omitempty"`
+	Duration  string `json:"duration,omitempty"`
+	Directory string `json:"directory,omitempty"`
+	Hosts     int    `json:"hosts,omitempty"`
+	Failed    int    `json:"failed,omitempty"`
+}
+
+type WebhookConfig struct {
+	Webhooks []Webhook `json:"webhooks"`
+}
+
+type Webhook struct {
+	URL        string            `json:"url"`
+	Events     []string          `json:"events,omitempty"`
+	Tasks      []string          `json:"tasks,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Retries    int               `json:"retries,string,omitempty"`
+	RetryDelay int               `json:"retryDelay,string,omitempty"`
+	Timeout    int               `json:"timeout,string,omitempty"`
+}
+
+type Notification struct {
+	Event      string `json:"event"`
+	Name       string `json:"name,omitempty"`
+	Host       string `json:"host"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	FailedTask string `json:"failedTask,omitempty"`
+	Autotests  string `json:"autotests,omitempty"`
+	Duration   string `json:"duration,omitempty"`
+	Task       string `json:"task,omitempty"`
+	TaskStatus string `json:"taskStatus,omitempty"`
+	Time       string `json:"time"`
+}
+
+type Event struct {
+	Time           string `json:"time"`
+	Event          string `json:"event"`
+	Host           string `json:"host,omitempty"`
+	Task           string `json:"task,omitempty"`
+	Command        string `json:"command,omitempty"`
+	Depth          int    `json:"depth,omitempty"`
+	Status         string `json:"status,omitempty"`
+	Error          string `json:"error,omitempty"`
+	DurationMs     *int64 `json:"durationMs,omitempty"`
+	Vendor         string `json:"vendor,omitempty"`
+	Prompt         string `json:"prompt,omitempty"`
+	PreviousPrompt string `json:"previousPrompt,omitempty"`
+	Matched        *bool  `json:"matched,omitempty"`
+	Target         string `json:"target,omitempty"`
+	File           string `json:"file,omitempty"`
+}
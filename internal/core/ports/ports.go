@@ -6,11 +6,55 @@ const VERSION = "1.1.4"
 // Системное время по умолчанию для подключения к устройству - 30 секунд
 const SPAWN_TIMEOUT_SYSTEM = 20
 
+// Интервал опроса хоста после перезагрузки - 10 секунд
+const RELOAD_POLL_INTERVAL = 10
+
+// Максимальное время ожидания пропадания хоста после разрыва сессии - 120 секунд
+const RELOAD_DOWN_WINDOW = 120
+
+// Максимальное количество ответов на каждый из запросов подтверждения
+const RESPONDER_RETRIES = 5
+
+// Максимальное количество элементов диапазона цикла (loop.range)
+const LOOP_RANGE_LIMIT = 10000
+
+// Количество попыток и пауза между ними (в секундах) для until по умолчанию
+const UNTIL_RETRIES = 3
+const UNTIL_DELAY = 5
+
 // Возможные состояния задания
 const PIPE_STATUS_SUCCESS = "success"
 const PIPE_STATUS_FAIL = "fail"
 const PIPE_STATUS_SKIPPED = "skipped"
 
+// События уведомлений (webhook)
+const EVENT_COMPLETED = "completed"
+const EVENT_FAILED = "failed"
+const EVENT_AUTOTESTS_FAILED = "autotests-failed"
+const EVENT_TASK = "task"
+
+// События потока событий выполнения (JSONL)
+const STREAM_EVENT_CONNECT = "connect"
+const STREAM_EVENT_PROMPT_CHANGE = "prompt_change"
+const STREAM_EVENT_TASK_START = "task_start"
+const STREAM_EVENT_TASK_END = "task_end"
+const STREAM_EVENT_CONDITION = "condition"
+const STREAM_EVENT_GOTO = "goto"
+const STREAM_EVENT_FILE_SAVED = "file_saved"
+const STREAM_EVENT_EXIT = "exit"
+
+// Количество повторов и интервал между повторами отправки уведомления по умолчанию
+const WEBHOOK_RETRIES = 3
+const WEBHOOK_RETRY_DELAY = 5
+
+// Время ожидания ответа на уведомление по умолчанию - 10 секунд
+const WEBHOOK_TIMEOUT = 10
+
+// Возможные состояния задания в режиме сервера (кроме success и fail)
+const JOB_STATUS_QUEUED = "queued"
+const JOB_STATUS_RUNNING = "running"
+const JOB_STATUS_CANCELLED = "cancelled"
+
 // Ошибки при установлении сессии
 
 const ERROR_CONN_CLOSED = "connection-closed"
@@ -27,17 +71,54 @@ const ERROR_SEND_COMMAND = "spawner-command-send-error"
 const ERROR_PROMPT_TIMEOUT = "spawner-prompt-capture-timeout"
 const ERROR_PROMPT_CHANGED = "spawner-prompt-has-been-changed"
 const ERROR_PROMPT_DEFINE = "spawner-prompt-was-not-defined"
+const ERROR_RELOAD_NO_DISCONNECT = "spawner-reload-disconnect-not-detected"
+const ERROR_RELOAD_RETURN_TIMEOUT = "spawner-reload-host-return-timeout"
+const ERROR_RELOAD_SAVE_UNANSWERED = "spawner-reload-save-prompt-unanswered"
+const ERROR_HOST_TIMEOUT = "spawner-host-execution-timeout"
+const ERROR_CANCELLED = "execution-cancelled"
 
 // Ошибки расширенного функционала
 
 const ERROR_REGEX_VAR_NOT_EXIST = "spawner-regex-variable-not-exist"
 const ERROR_REGEX_GROUP_NE = "spawner-regex-group-val-count-not-equal"
+const ERROR_REGEX_COMPILE = "spawner-regex-compile-error"
 const ERROR_WHEN_CONDITION_DOUBLE_BASED = "spawner-when-condition-double-based"
+const ERROR_TRANSFER = "spawner-transfer-error"
+const ERROR_TRANSFER_METHOD = "spawner-transfer-method-unknown"
+const ERROR_TRANSFER_NO_FILE = "spawner-transfer-file-is-not-set"
+const ERROR_REGISTER_MODE = "spawner-register-mode-unknown"
+const ERROR_REGISTER_NO_GROUPS = "spawner-register-regex-has-no-groups"
+const ERROR_LOOP_SOURCE = "spawner-loop-must-contain-one-source"
+const ERROR_UNTIL_NOT_MATCHED = "spawner-until-condition-not-met"
+const ERROR_LOCAL_PATH_OUTSIDE = "spawner-local-file-outside-of-allowed-directory"
+
+// Ошибки поэтапного выполнения на хостах инвентаря
+
+const ERROR_ROLLOUT_HALTED = "rollout-halted-by-failure-threshold"
+const ERROR_ROLLOUT_CANCELLED = "rollout-cancelled-by-operator"
+
+// Ошибки заданий в режиме сервера
+
+const ERROR_JOB_NOT_FOUND = "job-not-found"
+const ERROR_JOB_NO_TARGET = "job-host-or-inventory-is-not-set"
+const ERROR_JOB_NO_INVENTORY = "job-inventory-is-not-configured"
+const ERROR_JOB_FINISHED = "job-already-finished"
+const ERROR_JOB_HOSTS_FAILED = "job-hosts-failed"
+
+// Ошибки запусков по расписанию
+
+const ERROR_SCHEDULE_OVERLAP = "schedule-previous-run-is-still-running"
 
 // Ошибки форматирования файла задания
 
 const ERROR_SYNTAX_NO_HOST = "syntax-host-is-not-set"
 const ERROR_SYNTAX_NO_TASKS = "syntax-no-tasks"
+const ERROR_SYNTAX_NO_CREDENTIALS = "syntax-credentials-are-not-set"
+const ERROR_SYNTAX_INCLUDE_NOT_FOUND = "syntax-include-file-not-found"
+const ERROR_SYNTAX_INCLUDE_CYCLE = "syntax-include-cycle"
+const ERROR_SYNTAX_INCLUDE_MIXED = "syntax-include-with-command-or-tasks"
+const ERROR_SYNTAX_BLOCK_MIXED = "syntax-block-with-command-or-tasks"
+const ERROR_SYNTAX_RESCUE_NO_BLOCK = "syntax-rescue-or-always-without-block"
 
 // Внутренние ошибки
 
@@ -46,3 +127,4 @@ const ERROR_INTERNAL_BUFFER = "internal-error-spawn-buffer-is-empty"
 const ERROR_INTERNAL_SSHHELLO = "internal-error-sshhello-yes-send"
 const ERROR_INTERNAL_CISCO_ENABLE = "internal-error-cisco-enable"
 const ERROR_INTERNAL_CISCO_MENU_EXIT = "internal-error-cisco-menu-exit"
+const ERROR_INTERNAL_PANIC = "internal-error-host-execution-panic"
@@ -1,33 +1,80 @@
 package controller
 
 import (
-	"github.com/andomize/network-automation-executor/internal/adapters/logger"
+	"fmt"
+	"regexp"
+
+	"github.com/andomize/network-automation-executor/internal/adapters/metrics"
 	"github.com/andomize/network-automation-executor/internal/core/domains"
 	"github.com/andomize/network-automation-executor/internal/core/ports"
+	"github.com/andomize/network-automation-executor/internal/core/services/spawner"
 )
 
 /*
  * Controller.Compile
  *
  * Сформировать поля задания на основе имеющихся данных
+ * Шаблоны подставляются во все строковые поля задания, кроме имени
+ * (по имени выполняются переходы onMove и условия when.name):
+ * команда, параметры, ответы responders и условия when
  */
-func (c *Controller) Compile(task *domains.Task, vars Artefacts) {
-	logger.DEBUG("CTRL_CLOSE: Starting compile task pattern")
+func (c *Controller) Compile(task *domains.Task, vars Artefacts) error {
+	c.Log.DEBUG("CTRL_CLOSE: Starting compile task pattern")
+
+	fields := []struct {
+		description string
+		value       *string
+	}{
+		{"command", &task.Command},
+		{"output filename", &task.Params.OutputFile},
+		{"filter", &task.Params.Filter},
+		{"filter exclude", &task.Params.FilterExclude},
+		{"until", &task.Params.Until},
+		{"register", &task.Params.Register},
+		{"register separator", &task.Params.RegisterSeparator},
+		{"transfer method", &task.Params.Transfer},
+		{"remote filename", &task.Params.RemoteFile},
+		{"local filename", &task.Params.LocalFile},
+	}
+
+	// Конвертируем переменные в полях задания на значения переменных
+	for _, field := range fields {
+		value, subError := c.RegExpConstructor(*field.value, vars)
+		if subError != nil {
+			c.Log.ERROR("CTRL_COMPILE: Fail to construct " + field.description +
+				" by reason: " + subError.Error())
+			return subError
+		}
+		*field.value = value
+	}
 
-	// Конвертируем переменные в команде на значения переменных
-	command, commandSubError := c.RegExpConstructor(task.Command, vars)
-	if commandSubError != nil {
-		logger.ERROR("CTRL_COMPILE: Fail to construct command" +
-			"by reason: " + commandSubError.Error())
-		c.ExitError(commandSubError.Error())
+	// Ответы и условия хранятся по указателю в исходном задании,
+	// поэтому шаблоны подставляются в их копии
+	if task.Params.Responders != nil {
+		responders := make([]domains.Responder, len(*task.Params.Responders))
+		for index, responder := range *task.Params.Responders {
+			expect, expectError := c.RegExpConstructor(responder.Expect, vars)
+			if expectError != nil {
+				c.Log.ERROR("CTRL_COMPILE: Fail to construct responder by reason: " + expectError.Error())
+				return expectError
+			}
+			send, sendError := c.RegExpConstructor(responder.Send, vars)
+			if sendError != nil {
+				c.Log.ERROR("CTRL_COMPILE: Fail to construct responder by reason: " + sendError.Error())
+				return sendError
+			}
+			responders[index] = domains.Responder{Expect: expect, Send: send}
+		}
+		task.Params.Responders = &responders
 	}
 
-	// Конвертируем переменные в имени файла вывода
-	outputFile, outputFileError := c.RegExpConstructor(task.Params.OutputFile, vars)
-	if outputFileError != nil {
-		logger.ERROR("CTRL_COMPILE: Fail to construct output filename" +
-			"by reason: " + outputFileError.Error())
-		c.ExitError(outputFileError.Error())
+	if task.When != nil {
+		when, whenError := c.CompileWhen(*task.When, vars)
+		if whenError != nil {
+			c.Log.ERROR("CTRL_COMPILE: Fail to construct condition by reason: " + whenError.Error())
+			return whenError
+		}
+		task.When = &when
 	}
 
 	// Проверяем установлен ли специфичный Timeout для задания
@@ -36,8 +83,62 @@ func (c *Controller) Compile(task *domains.Task, vars Artefacts) {
 		task.Params.Timeout = c.GetDefaultTimeout()
 	}
 
-	task.Command = command
-	task.Params.OutputFile = outputFile
+	return nil
+}
+
+/*
+ * Controller.CompileWhen
+ *
+ * Подставить шаблоны в строковые поля копии условий (включая вложенные
+ * группы all, any и not)
+ */
+func (c *Controller) CompileWhen(when []domains.When, vars Artefacts) ([]domains.When, error) {
+
+	result := make([]domains.When, len(when))
+
+	for index, condition := range when {
+		fields := []*string{
+			&condition.Name, &condition.IfStatus,
+			&condition.IfOutputContains, &condition.IfOutputNotContains,
+			&condition.IfOutputContainsRe, &condition.IfOutputNotContainsRe,
+			&condition.Variable, &condition.IfValue, &condition.IfValueNot,
+			&condition.Extract, &condition.IfVersionGt, &condition.IfVersionGte,
+			&condition.IfVersionLt, &condition.IfVersionLte,
+			&condition.IfNumberEq, &condition.IfNumberGt, &condition.IfNumberGte,
+			&condition.IfNumberLt, &condition.IfNumberLte, &condition.IfNumberBetween,
+			&condition.OnMove,
+		}
+		for _, field := range fields {
+			value, subError := c.RegExpConstructor(*field, vars)
+			if subError != nil {
+				return nil, subError
+			}
+			*field = value
+		}
+
+		for _, group := range []**[]domains.When{&condition.All, &condition.Any} {
+			if *group == nil {
+				continue
+			}
+			compiled, compileError := c.CompileWhen(**group, vars)
+			if compileError != nil {
+				return nil, compileError
+			}
+			*group = &compiled
+		}
+
+		if condition.Not != nil {
+			compiled, compileError := c.CompileWhen([]domains.When{*condition.Not}, vars)
+			if compileError != nil {
+				return nil, compileError
+			}
+			condition.Not = &compiled[0]
+		}
+
+		result[index] = condition
+	}
+
+	return result, nil
 }
 
 /*
@@ -46,21 +147,44 @@ func (c *Controller) Compile(task *domains.Task, vars Artefacts) {
  * Отправить команду на хост
  */
 func (c *Controller) Send(task *domains.Task) (string, error) {
-	logger.DEBUG("CTRL_SEND: Starting send task command: '" + task.Command + "'")
+	c.Log.DEBUG("CTRL_SEND: Starting send task command: '" + task.Command + "'")
 
-	commandSendOutput, commandSendError := c.Connection.Send(
-		task.Command, task.Params.Timeout, task.Params.PromptChangeAllowed)
+	var commandSendOutput string
+	var commandSendError error
 
-	if commandSendError == nil {
+	var previousPrompt string
+	if c.Connection.Prompt != nil {
+		previousPrompt = c.Connection.Prompt.Name
+	}
+
+	if len(task.Params.Transfer) > 0 {
+		// Вместо отправки команды выполняется передача файла по SCP/SFTP
+		commandSendOutput, commandSendError = c.Transfer(task)
+	} else if task.Params.FireAndForget {
+		// Команда закрывает сессию (reload, reboot) - ожидаем разрыв соединения
+		// и, если требуется, возвращение хоста
+		commandSendOutput, commandSendError = c.SendDetached(task)
+	} else {
+		commandSendOutput, commandSendError = c.Connection.Send(
+			task.Command, task.Params.Timeout, task.Params.PromptChangeAllowed)
+	}
+
+	if commandSendError == nil && c.Connection.Prompt != nil {
 		// Установим новое значение переменной prompt
 		c.Variables["prompt"] = c.Connection.Prompt.Name
+
+		if c.Connection.Prompt.Name != previousPrompt {
+			c.Emit(domains.Event{Event: ports.STREAM_EVENT_PROMPT_CHANGE, Task: task.Name,
+				Command: task.Command, Prompt: c.Connection.Prompt.Name,
+				PreviousPrompt: previousPrompt, Vendor: c.Connection.Prompt.Vendor})
+		}
 	}
 
 	// Проверяем присутствует ли поле <name> в теле задания
 	// Если данное поле присутствует, то есть вероятность, что информация из данного задания
 	// будет принимать участие при обработке условного оператора в следующем задании
 	if len(task.Name) > 0 {
-		logger.DEBUG("CTRL_SEND: Enriching a named task '" + task.Name + "' with output")
+		c.Log.DEBUG("CTRL_SEND: Enriching a named task '" + task.Name + "' with output")
 		if c.Names[task.Name] != nil {
 			c.Names[task.Name].Output = commandSendOutput
 		} else {
@@ -71,6 +195,56 @@ func (c *Controller) Send(task *domains.Task) (string, error) {
 	return commandSendOutput, commandSendError
 }
 
+/*
+ * Controller.SendDetached
+ *
+ * Отправить команду, после которой хост закрывает сессию, и при наличии параметра
+ * WaitReturn дождаться возвращения хоста и переподключиться к нему
+ */
+func (c *Controller) SendDetached(task *domains.Task) (string, error) {
+
+	// Преобразуем ответы на запросы подтверждения из задания
+	var responders = []spawner.Responder{}
+	if task.Params.Responders != nil {
+		for _, responder := range *task.Params.Responders {
+			compiledRegExp, compileError := regexp.Compile(responder.Expect)
+			if compileError != nil {
+				return "", compileError
+			}
+			responders = append(responders, spawner.Responder{
+				RegExp: compiledRegExp,
+				Answer: responder.Send,
+			})
+		}
+	}
+
+	output, sendError := c.Connection.SendDetached(task.Command, task.Params.Timeout, responders)
+	if sendError != nil {
+		return output, sendError
+	}
+
+	c.Log.INFO("CTRL_SEND: Host '" + c.Task.Host + "' closed the session as expected")
+
+	// Возвращение хоста не ожидается - задание завершено
+	if task.Params.WaitReturn <= 0 {
+		return output, nil
+	}
+
+	c.Log.INFO(fmt.Sprintf("CTRL_SEND: Waiting for host '%s' return, timeout: '%v' seconds",
+		c.Task.Host, task.Params.WaitReturn))
+
+	if reconnectError := c.Connection.Reconnect(c.ctx, task.Params.WaitReturn); reconnectError != nil {
+		return output, reconnectError
+	}
+
+	// Актуализируем информацию о хосте после повторного подключения
+	c.Variables["vendor"] = c.Connection.Prompt.Vendor
+	c.Task.Vendor = c.Connection.Prompt.Vendor
+
+	c.Log.INFO("CTRL_SEND: Reconnection to host '" + c.Task.Host + "' successful")
+	return output, nil
+}
+
 /*
  * Controller.SetTaskStatus
  *
@@ -78,12 +252,13 @@ func (c *Controller) Send(task *domains.Task) (string, error) {
  */
 func (c *Controller) SetTaskStatus(task *domains.Task, status string) {
 	task.Status = status
+	metrics.TaskStatus(c.Task.Vendor, status)
 
 	// Проверяем присутствует ли поле <name> в теле задания
 	// Если данное поле присутствует, то есть вероятность, что информация из данного задания
 	// будет принимать участие при обработке условного оператора в следующем задании
 	if len(task.Name) > 0 {
-		logger.DEBUG("CTRL_SEND: Enriching a named task '" + task.Name + "' with status '" + status + "'")
+		c.Log.DEBUG("CTRL_SEND: Enriching a named task '" + task.Name + "' with status '" + status + "'")
 		if c.Names[task.Name] != nil {
 			c.Names[task.Name].Status = status
 		} else {
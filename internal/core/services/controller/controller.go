@@ -1,11 +1,12 @@
 package controller
 
 import (
+	"context"
 	"errors"
-	"log"
-	"os"
+	"sync"
 	"time"
 
+	"github.com/andomize/network-automation-executor/internal/adapters/eventstream"
 	"github.com/andomize/network-automation-executor/internal/adapters/filestorage"
 	"github.com/andomize/network-automation-executor/internal/adapters/jsontask"
 	"github.com/andomize/network-automation-executor/internal/adapters/logger"
@@ -16,9 +17,15 @@ import (
 
 type Artefacts map[string]string
 
+// Переменная с ошибкой заданий block, доступная заданиям block, rescue и always
+const BlockErrorVariable = "block_error"
+
 type NamedTask struct {
 	Status string
 	Output string
+
+	// Выводы всех попыток задания с until (последний совпадает с Output)
+	Attempts []string
 }
 
 type Controller struct {
@@ -43,21 +50,46 @@ type Controller struct {
 
 	// Путь к файлу задания
 	TaskPath string
+
+	// Директория, за пределы которой не могут выходить локальные файлы
+	// задания (пустая - без ограничения)
+	LocalRoot string
+
+	// Путь для сохранения результата выполнения задания
+	ResultPath string
+
+	// Выполнение заданий завершено досрочно (действие onExit)
+	Stopped bool
+
+	// Результат автотестов (пустой - автотесты не выполнялись)
+	AutotestsStatus string
+
+	// Журнал контроллера
+	Log *logger.Logger
+
+	// Поток событий выполнения (может отсутствовать)
+	Events *eventstream.Stream
+
+	// Код ошибки, с которым выполнение было прервано извне
+	abortCode  string
+	abortMutex sync.Mutex
+
+	// Контекст выполнения, отменяется при прерывании (Abort)
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 /*
  * NewController
  *
- * Создаёт новый экземпляр Controller
+ * Создаёт новый экземпляр Controller на основе файла задания
+ * Результат выполнения записывается по пути jsontask.ResultPath
  */
 func NewController(taskPath, outputDirectory, user, pass string) (*Controller, error) {
 
 	logger.DEBUG("CTRL_NEW: Start creating new controller with task path: '" + taskPath +
 		"' and outputDirectory: '" + outputDirectory + "'")
 
-	// Контроллер пути с выходными файлами задания
-	outputStorage := filestorage.NewFileStorage(outputDirectory)
-
 	// Читаем задание из файловой системы в память контроллера
 	fsysTask, fsysTaskReadError := jsontask.Read(taskPath)
 
@@ -67,26 +99,54 @@ func NewController(taskPath, outputDirectory, user, pass string) (*Controller, e
 		return nil, fsysTaskReadError
 	}
 
+	return NewTaskController(context.Background(), *fsysTask, taskPath, jsontask.ResultPath(taskPath, *fsysTask), outputDirectory, nil, nil, user, pass)
+}
+
+/*
+ * NewTaskController
+ *
+ * Создаёт новый экземпляр Controller на основе прочитанного ранее задания
+ *  taskPath   - путь к исходному файлу задания
+ *  resultPath - путь, по которому будет сохранён результат выполнения
+ *  variables  - дополнительные переменные (например, из инвентаря), имеют
+ *               приоритет над переменными из задания
+ *  log        - журнал хоста (nil - общий журнал программы)
+ *
+ * Ошибка подключения или синтаксиса задания записывается в результат
+ * Отмена контекста прерывает подключение и выполнение задания
+ */
+func NewTaskController(
+	ctx context.Context,
+	task domains.TaskPattern,
+	taskPath, resultPath, outputDirectory string,
+	variables map[string]string,
+	log *logger.Logger,
+	user, pass string,
+) (*Controller, error) {
+
 	// Создаём экземпляр Controller
 	controller := &Controller{
-		Task:          *fsysTask,
-		OutputStorage: outputStorage,
+		Task:          task,
+		OutputStorage: filestorage.NewFileStorage(outputDirectory),
 		Names:         map[string]*NamedTask{},
 		NextTaskName:  "",
 		Variables:     Artefacts{},
 		TaskPath:      taskPath,
+		ResultPath:    resultPath,
+		Log:           log,
 	}
-
-	if connError := controller.connect(fsysTask.Host, user, pass); connError != nil {
-		controller.ExitError(connError.Error())
-	}
+	controller.ctx, controller.cancel = context.WithCancel(ctx)
 
 	// Базовая проверка файла задания
 	if len(controller.Task.Host) <= 0 {
-		controller.ExitError(ports.ERROR_SYNTAX_NO_HOST)
+		return controller, controller.ExitError(ports.ERROR_SYNTAX_NO_HOST)
 	}
 	if controller.Task.Tasks == nil || len(*controller.Task.Tasks) <= 0 {
-		controller.ExitError(ports.ERROR_SYNTAX_NO_TASKS)
+		return controller, controller.ExitError(ports.ERROR_SYNTAX_NO_TASKS)
+	}
+
+	if connError := controller.connect(controller.Task.Host, user, pass); connError != nil {
+		return controller, controller.ExitError(connError.Error())
 	}
 
 	// Добавляем системные переменные (предопределяются по умолчанию)
@@ -101,43 +161,124 @@ func NewController(taskPath, outputDirectory, user, pass string) (*Controller, e
 
 	// Переносим переменные из задания (если они есть) в память контроллера
 	for index, value := range controller.Task.Variables {
-		logger.DEBUG("CTRL_NEW: Adding new variable '" + index + "' = '" + value + "'")
+		controller.Log.DEBUG("CTRL_NEW: Adding new variable '" + index + "' = '" + value + "'")
+		controller.Variables[index] = value
+	}
+
+	// Переносим дополнительные переменные
+	for index, value := range variables {
+		controller.Log.DEBUG("CTRL_NEW: Adding new external variable '" + index + "' = '" + value + "'")
 		controller.Variables[index] = value
 	}
 
 	return controller, nil
 }
 
+/*
+ * NewDryRunController
+ *
+ * Создаёт экземпляр Controller без подключения к хосту для просмотра
+ * заданий (dry-run). Переменные vendor и prompt, известные только после
+ * подключения, заменяются на "<vendor>" и "<prompt>", если не переданы
+ * в variables
+ */
+func NewDryRunController(
+	task domains.TaskPattern,
+	taskPath string,
+	variables map[string]string,
+	log *logger.Logger,
+) *Controller {
+
+	controller := &Controller{
+		Task:          task,
+		OutputStorage: filestorage.NewFileStorage(""),
+		Names:         map[string]*NamedTask{},
+		Variables:     Artefacts{},
+		TaskPath:      taskPath,
+		Log:           log,
+	}
+	controller.ctx, controller.cancel = context.WithCancel(context.Background())
+
+	controller.Variables["host"] = controller.Task.Host
+	controller.Variables["date"] = time.Now().Format("2006-01-02")
+	controller.Variables["time"] = time.Now().Format("15-04-05")
+	controller.Variables["vendor"] = "<vendor>"
+	controller.Variables["prompt"] = "<prompt>"
+
+	for index, value := range controller.Task.Variables {
+		controller.Variables[index] = value
+	}
+	for index, value := range variables {
+		controller.Variables[index] = value
+	}
+
+	return controller
+}
+
 /*
  * Controller.ExitSuccess
  *
- * Записать успешный статус в задание, сохранить файл и выйти из программы
+ * Записать успешный статус в задание, сохранить файл и закрыть контроллер
  */
-func (c *Controller) ExitSuccess() {
-	logger.DEBUG("CTRL_EXIT_SUCCESS: Set new status for task: '" + ports.PIPE_STATUS_SUCCESS +
-		"' and program exiting")
+func (c *Controller) ExitSuccess() error {
+	c.Log.DEBUG("CTRL_EXIT_SUCCESS: Set new status for task: '" + ports.PIPE_STATUS_SUCCESS +
+		"' and closing controller")
 
 	// Устанавливаем новый статус для задания - УСПЕШНО
 	c.Task.Status = ports.PIPE_STATUS_SUCCESS
-	// Сохранение задания, закрытие служб, выход
+	// Сохранение задания, закрытие служб
 	c.Save()
 	c.Close()
-	os.Exit(0)
+	return nil
 }
 
 /*
  * Controller.ExitError
  *
- * Записать ошибку в задание, сохранить файл и выйти из программы
+ * Записать ошибку в задание, сохранить файл и закрыть контроллер
+ * Возвращает ошибку с кодом errorCode для передачи вызывающей стороне
  */
-func (c *Controller) ExitError(errorCode string) {
-	logger.DEBUG("CTRL_EXIT_ERROR: Set new error: '" + errorCode + "' and exiting")
+func (c *Controller) ExitError(errorCode string) error {
+	c.Log.DEBUG("CTRL_EXIT_ERROR: Set new error: '" + errorCode + "' and closing controller")
 
 	c.Task.Error = errorCode
 	c.Task.Status = ports.PIPE_STATUS_FAIL
 	c.Save()
 	c.Close()
-	log.Fatal(errors.New(errorCode))
+	return errors.New(errorCode)
+}
+
+/*
+ * Controller.Abort
+ *
+ * Прервать выполнение задания из другой горутины (например, по таймеру)
+ * Соединение закрывается, текущая команда завершается с ошибкой, а задание
+ * будет завершено с кодом errorCode
+ */
+func (c *Controller) Abort(errorCode string) {
+	c.Log.WARNING("CTRL_ABORT: Task execution aborted with error: '" + errorCode + "'")
+
+	c.abortMutex.Lock()
+	c.abortCode = errorCode
+	c.abortMutex.Unlock()
+
+	// Прерываем ожидание возвращения хоста и повторное подключение
+	c.cancel()
+
+	if c.Connection != nil {
+		c.Connection.Close()
+	}
+}
+
+/*
+ * Controller.Aborted
+ *
+ * Получить код ошибки, с которым выполнение было прервано (пустой - не прервано)
+ */
+func (c *Controller) Aborted() string {
+	c.abortMutex.Lock()
+	defer c.abortMutex.Unlock()
+	return c.abortCode
 }
 
 /*
@@ -148,10 +289,10 @@ func (c *Controller) ExitError(errorCode string) {
 func (c *Controller) connect(host, user, pass string) error {
 	// Открываем сессию с удалённым хостом. Процесс использует модуль GExpect
 	// для подключения к хосту, используя протоколы SSH1, SSH, Telnet
-	connection, connectionError := spawner.NewConnection(host, user, pass)
+	connection, connectionError := spawner.NewConnection(c.ctx, host, user, pass, c.Log)
 
 	if connectionError != nil {
-		logger.ERROR("CTRL_NEW: Connection to host '" + host + "' failed " +
+		c.Log.ERROR("CTRL_NEW: Connection to host '" + host + "' failed " +
 			"by reason: " + connectionError.Error())
 		return connectionError
 	}
@@ -166,18 +307,31 @@ func (c *Controller) connect(host, user, pass string) error {
  * Сохранить вывод в файл
  */
 func (c *Controller) SaveOutput(output, filename string) error {
-	logger.DEBUG("CTRL_SEND: Starting save output file: " + filename + "'")
+	c.Log.DEBUG("CTRL_SEND: Starting save output file: " + filename + "'")
 	return c.OutputStorage.Save([]byte(output), filename)
 }
 
+/*
+ * Controller.Emit
+ *
+ * Записать событие выполнения на хосте контроллера в поток событий
+ */
+func (c *Controller) Emit(event domains.Event) {
+	event.Host = c.Task.Host
+	c.Events.Emit(event)
+}
+
 /*
  * Controller.Save
  *
- * Сохранить файл задания
+ * Сохранить файл задания (если путь для сохранения результата задан)
  */
 func (c *Controller) Save() {
-	logger.DEBUG("CTRL_SAVE: Starting saving task file '" + c.TaskPath + "'")
-	jsontask.Write(c.TaskPath, c.Task)
+	if len(c.ResultPath) <= 0 {
+		return
+	}
+	c.Log.DEBUG("CTRL_SAVE: Starting saving task file '" + c.ResultPath + "'")
+	jsontask.Write(c.ResultPath, c.Task)
 }
 
 /*
@@ -186,7 +340,7 @@ func (c *Controller) Save() {
  * Закрыть все службы контроллера заданий
  */
 func (c *Controller) Close() {
-	logger.DEBUG("CTRL_CLOSE: Closing task controller")
+	c.Log.DEBUG("CTRL_CLOSE: Closing task controller")
 	if c.Connection != nil {
 		c.Connection.Close()
 	}
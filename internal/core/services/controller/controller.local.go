@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+
+	"github.com/andomize/network-automation-executor/internal/core/ports"
+)
+
+/*
+ * Controller.LocalPath
+ *
+ * Путь к локальному файлу задания: относительный путь отсчитывается от
+ * директории файла задания. Если задана LocalRoot, то файл должен
+ * находиться внутри этой директории
+ */
+func (c *Controller) LocalPath(path string) (string, error) {
+
+	localPath, pathError := ResolveLocalPath(path, c.TaskPath, c.LocalRoot)
+	if pathError != nil {
+		c.Log.ERROR("CTRL_LOCAL: File '" + path + "' is outside of directory '" + c.LocalRoot + "'")
+	}
+
+	return localPath, pathError
+}
+
+/*
+ * ResolveLocalPath
+ *
+ * Путь к локальному файлу задания taskPath (см. Controller.LocalPath)
+ * Используется также при проверке задания без подключения к хосту
+ */
+func ResolveLocalPath(path, taskPath, localRoot string) (string, error) {
+
+	if !filepath.IsAbs(path) && len(taskPath) > 0 {
+		path = filepath.Join(filepath.Dir(taskPath), path)
+	}
+
+	if len(localRoot) > 0 && !Within(localRoot, path) {
+		return "", errors.New(ports.ERROR_LOCAL_PATH_OUTSIDE)
+	}
+
+	return path, nil
+}
+
+/*
+ * Within
+ *
+ * Проверить, что путь находится внутри директории (с учётом ссылок "..")
+ */
+func Within(directory, path string) bool {
+
+	absoluteDirectory, directoryError := filepath.Abs(directory)
+	absolutePath, pathError := filepath.Abs(path)
+	if directoryError != nil || pathError != nil {
+		return false
+	}
+
+	relative, relError := filepath.Rel(absoluteDirectory, absolutePath)
+	if relError != nil {
+		return false
+	}
+
+	return relative != ".." && !strings.HasPrefix(relative, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,183 @@
+package controller
+
+import (
+	"encoding/csv"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/andomize/network-automation-executor/internal/core/domains"
+	"github.com/andomize/network-automation-executor/internal/core/ports"
+)
+
+// Имя переменной элемента цикла по умолчанию
+const LoopDefaultVariable = "item"
+
+/*
+ * Controller.LoopItems
+ *
+ * Сформировать переменные для каждого повторения задания с loop:
+ *  items     - элементы списка (шаблоны подставляются)
+ *  range     - целые числа "start..end" (границы включаются)
+ *  variable  - значение переменной, разделённое separator (по умолчанию ","),
+ *              пустые элементы пропускаются
+ *  csv       - строки CSV файла, переменные - имена столбцов из первой строки
+ * Элемент списка, числа и значения переменной доступны как {{item}}
+ * (или {{<as>}})
+ */
+func (c *Controller) LoopItems(loop domains.Loop, vars Artefacts) ([]Artefacts, error) {
+
+	sources := 0
+	for _, defined := range []bool{loop.Items != nil, len(loop.Range) > 0,
+		len(loop.Variable) > 0, len(loop.CSV) > 0} {
+		if defined {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return nil, errors.New(ports.ERROR_LOOP_SOURCE)
+	}
+
+	name := loop.As
+	if len(name) <= 0 {
+		name = LoopDefaultVariable
+	}
+
+	var values []string
+	switch {
+	case loop.Items != nil:
+		for _, item := range loop.Items {
+			value, itemError := c.RegExpConstructor(item, vars)
+			if itemError != nil {
+				return nil, itemError
+			}
+			values = append(values, value)
+		}
+
+	case len(loop.Range) > 0:
+		text, rangeError := c.RegExpConstructor(loop.Range, vars)
+		if rangeError != nil {
+			return nil, rangeError
+		}
+		start, end, rangeError := ParseLoopRange(text)
+		if rangeError != nil {
+			return nil, rangeError
+		}
+		for number := start; number <= end; number++ {
+			values = append(values, strconv.Itoa(number))
+		}
+
+	case len(loop.Variable) > 0:
+		value, exist := vars[loop.Variable]
+		if !exist {
+			return nil, errors.New("Loop variable '" + loop.Variable + "' does not exist")
+		}
+		separator := loop.Separator
+		if len(separator) <= 0 {
+			separator = ","
+		}
+		for _, item := range strings.Split(value, separator) {
+			if item = strings.TrimSpace(item); len(item) > 0 {
+				values = append(values, item)
+			}
+		}
+
+	case len(loop.CSV) > 0:
+		csvPath, pathError := c.RegExpConstructor(loop.CSV, vars)
+		if pathError != nil {
+			return nil, pathError
+		}
+		csvPath, pathError = c.LocalPath(csvPath)
+		if pathError != nil {
+			return nil, pathError
+		}
+		header, rows, csvError := ReadLoopCSV(csvPath)
+		if csvError != nil {
+			return nil, csvError
+		}
+		var iterations []Artefacts
+		for _, row := range rows {
+			iteration := Artefacts{}
+			for column, field := range header {
+				iteration[field] = row[column]
+			}
+			iterations = append(iterations, iteration)
+		}
+		return iterations, nil
+	}
+
+	iterations := make([]Artefacts, len(values))
+	for index, value := range values {
+		iterations[index] = Artefacts{name: value}
+	}
+
+	return iterations, nil
+}
+
+/*
+ * ParseLoopRange
+ *
+ * Диапазон цикла в формате "start..end" (целые числа, start <= end),
+ * не более ports.LOOP_RANGE_LIMIT элементов
+ */
+func ParseLoopRange(text string) (int, int, error) {
+
+	bounds := strings.Split(text, "..")
+	if len(bounds) != 2 {
+		return 0, 0, errors.New("loop range '" + text + "' must be in format 'start..end'")
+	}
+
+	start, startError := strconv.Atoi(strings.TrimSpace(bounds[0]))
+	end, endError := strconv.Atoi(strings.TrimSpace(bounds[1]))
+	if startError != nil || endError != nil {
+		return 0, 0, errors.New("loop range '" + text + "' must contain integers")
+	}
+	if start > end {
+		return 0, 0, errors.New("loop range '" + text + "' has start greater than end")
+	}
+
+	// Разность вычисляется без переполнения для любых границ
+	if uint64(end)-uint64(start) >= ports.LOOP_RANGE_LIMIT {
+		return 0, 0, errors.New("loop range '" + text + "' contains more than " +
+			strconv.Itoa(ports.LOOP_RANGE_LIMIT) + " elements")
+	}
+
+	return start, end, nil
+}
+
+/*
+ * ReadLoopCSV
+ *
+ * Прочитать CSV файл цикла: первая строка - имена столбцов (переменных)
+ */
+func ReadLoopCSV(csvPath string) ([]string, [][]string, error) {
+
+	file, openError := os.Open(csvPath)
+	if openError != nil {
+		return nil, nil, openError
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+
+	records, readError := reader.ReadAll()
+	if readError != nil {
+		return nil, nil, readError
+	}
+	if len(records) <= 0 {
+		return nil, nil, errors.New("CSV file '" + csvPath + "' does not contain header row")
+	}
+
+	header := records[0]
+	for index, field := range header {
+		header[index] = strings.TrimSpace(field)
+		if len(header[index]) <= 0 {
+			return nil, nil, errors.New("CSV file '" + csvPath + "' contains empty column name")
+		}
+	}
+
+	return header, records[1:], nil
+}
@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/andomize/network-automation-executor/internal/core/domains"
+)
+
+/*
+ * ParseNumber
+ *
+ * Число из значения вывода или переменной: пробелы по краям, знак "%"
+ * в конце и разделители разрядов "," не учитываются ("80%", "1,024")
+ */
+func ParseNumber(text string) (float64, error) {
+
+	cleaned := strings.TrimSpace(text)
+	cleaned = strings.TrimSpace(strings.TrimSuffix(cleaned, "%"))
+	cleaned = strings.ReplaceAll(cleaned, ",", "")
+
+	number, parseError := strconv.ParseFloat(cleaned, 64)
+	if parseError != nil {
+		return 0, errors.New("'" + text + "' is not a number")
+	}
+
+	return number, nil
+}
+
+/*
+ * ParseRange
+ *
+ * Диапазон ifNumberBetween в формате "min..max" (границы включаются)
+ */
+func ParseRange(text string) (float64, float64, error) {
+
+	bounds := strings.Split(text, "..")
+	if len(bounds) != 2 {
+		return 0, 0, errors.New("range '" + text + "' must be in format 'min..max'")
+	}
+
+	min, minError := ParseNumber(bounds[0])
+	if minError != nil {
+		return 0, 0, minError
+	}
+	max, maxError := ParseNumber(bounds[1])
+	if maxError != nil {
+		return 0, 0, maxError
+	}
+	if min > max {
+		return 0, 0, errors.New("range '" + text + "' has min greater than max")
+	}
+
+	return min, max, nil
+}
+
+// Заданы ли в условии числовые сравнения
+func hasNumberChecks(when domains.When) bool {
+	return len(when.IfNumberEq) > 0 || len(when.IfNumberGt) > 0 || len(when.IfNumberGte) > 0 ||
+		len(when.IfNumberLt) > 0 || len(when.IfNumberLte) > 0 || len(when.IfNumberBetween) > 0
+}
+
+/*
+ * MatchNumber
+ *
+ * Проверить условия ifNumber* для значения value
+ * Возвращает результат и описание невыполненной проверки
+ */
+func MatchNumber(when domains.When, value string) (bool, string) {
+
+	if !hasNumberChecks(when) {
+		return true, ""
+	}
+
+	number, parseError := ParseNumber(value)
+	if parseError != nil {
+		return false, parseError.Error()
+	}
+
+	checks := []struct {
+		name, want string
+		passed     func(number, want float64) bool
+	}{
+		{"ifNumberEq", when.IfNumberEq, func(number, want float64) bool { return number == want }},
+		{"ifNumberGt", when.IfNumberGt, func(number, want float64) bool { return number > want }},
+		{"ifNumberGte", when.IfNumberGte, func(number, want float64) bool { return number >= want }},
+		{"ifNumberLt", when.IfNumberLt, func(number, want float64) bool { return number < want }},
+		{"ifNumberLte", when.IfNumberLte, func(number, want float64) bool { return number <= want }},
+	}
+
+	for _, check := range checks {
+		if len(check.want) <= 0 {
+			continue
+		}
+		want, wantError := ParseNumber(check.want)
+		if wantError != nil {
+			return false, check.name + " " + wantError.Error()
+		}
+		if !check.passed(number, want) {
+			return false, check.name + " '" + check.want + "' fail"
+		}
+	}
+
+	// IfNumberBetween
+	if len(when.IfNumberBetween) > 0 {
+		min, max, rangeError := ParseRange(when.IfNumberBetween)
+		if rangeError != nil {
+			return false, "ifNumberBetween " + rangeError.Error()
+		}
+		if number < min || number > max {
+			return false, "ifNumberBetween '" + when.IfNumberBetween + "' fail"
+		}
+	}
+
+	return true, ""
+}
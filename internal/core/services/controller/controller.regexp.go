@@ -6,10 +6,9 @@ import (
 	"math"
 	"regexp"
 	"strconv"
-	"strings"
 
-	"github.com/andomize/network-automation-executor/internal/adapters/logger"
 	"github.com/andomize/network-automation-executor/internal/core/ports"
+	"github.com/andomize/network-automation-executor/internal/core/services/template"
 )
 
 /*
@@ -21,11 +20,16 @@ import (
 func (c *Controller) RegExpMatch(
 	output, incRegex, excRegex string) (map[string][]string, int, error) {
 
-	logger.DEBUG("CTRL_REGIT: Include RegExp: '" + incRegex + "'")
-	logger.DEBUG("CTRL_REGIT: Exclude RegExp: '" + excRegex + "'")
+	c.Log.DEBUG("CTRL_REGIT: Include RegExp: '" + incRegex + "'")
+	c.Log.DEBUG("CTRL_REGIT: Exclude RegExp: '" + excRegex + "'")
 
 	// Компилируем полученное регулярное выражение
-	compiledRegExp := regexp.MustCompile(incRegex)
+	compiledRegExp, compileError := regexp.Compile(incRegex)
+	if compileError != nil {
+		c.Log.ERROR(ports.ERROR_REGEX_COMPILE)
+		return nil, -1, errors.New("Include RegExp '" + incRegex + "' is incorrect: " +
+			compileError.Error())
+	}
 
 	// SubexpNames возвращает имена заключенных в скобки подвыражений
 	// в этом регулярном выражении. Имя для первого подвыражения — name[1],
@@ -97,7 +101,7 @@ func (c *Controller) RegExpMatch(
 	// их на подзадание будет невозможно
 	if get_min(result) != get_max(result) {
 		// Если кол-во элементов в группах не совпадает, то ошибка
-		logger.ERROR(ports.ERROR_REGEX_GROUP_NE)
+		c.Log.ERROR(ports.ERROR_REGEX_GROUP_NE)
 		return nil, -1, errors.New("Count of element in different groups is not equal, max: " +
 			strconv.Itoa(get_max(result)) + ", min: " + strconv.Itoa(get_min(result)) + ", st 1")
 	}
@@ -105,7 +109,12 @@ func (c *Controller) RegExpMatch(
 	// Проверяем были ли переданы выражения, по которым нужно исключить результаты
 	// из найденных групп в прошлом блоке кода
 	if len(excRegex) > 0 {
-		excludeRegExp := regexp.MustCompile(excRegex)
+		excludeRegExp, compileError := regexp.Compile(excRegex)
+		if compileError != nil {
+			c.Log.ERROR(ports.ERROR_REGEX_COMPILE)
+			return nil, -1, errors.New("Exclude RegExp '" + excRegex + "' is incorrect: " +
+				compileError.Error())
+		}
 
 		// Записываем все индексы (номера элементов), которые нужно удалить из каждой группы
 		var removingValueIndexPerGroups = []int{}
@@ -120,7 +129,7 @@ func (c *Controller) RegExpMatch(
 				// которое мы получили в переменной "excludeRegExp". Если совпадает, то все элементы
 				// во всех группах с этим индексом будут удалены
 				if excludeRegExp.MatchString(value) {
-					logger.DEBUG("CTRL_REGIT: Removing index '" + strconv.Itoa(valueIndex) +
+					c.Log.DEBUG("CTRL_REGIT: Removing index '" + strconv.Itoa(valueIndex) +
 						"' is planed")
 					removingValueIndexPerGroups = append(removingValueIndexPerGroups, valueIndex)
 				}
@@ -128,7 +137,7 @@ func (c *Controller) RegExpMatch(
 		}
 
 		if len(removingValueIndexPerGroups) > 0 {
-			logger.DEBUG("CTRL_REGIT: Group ready to removing: " + fmt.Sprint(result))
+			c.Log.DEBUG("CTRL_REGIT: Group ready to removing: " + fmt.Sprint(result))
 
 			// Исключаем те элементы, которые должны быть исключены
 			for groupIdx, group := range result {
@@ -146,7 +155,7 @@ func (c *Controller) RegExpMatch(
 					for _, removingValueIndex := range removingValueIndexPerGroups {
 						// Если индекс совпадает - не добавляем элемент в результат
 						if valueIndex == removingValueIndex {
-							logger.DEBUG("CTRL_REGIT: Removing item '" + value +
+							c.Log.DEBUG("CTRL_REGIT: Removing item '" + value +
 								"' from slice, index: '" + strconv.Itoa(valueIndex) + "'" +
 								" using result by  death slice")
 							allowToAppend = false
@@ -154,7 +163,7 @@ func (c *Controller) RegExpMatch(
 					}
 
 					if allowToAppend {
-						logger.DEBUG("CTRL_REGIT: Item '" + value +
+						c.Log.DEBUG("CTRL_REGIT: Item '" + value +
 							"' from slice, index: '" + strconv.Itoa(valueIndex) + "is still alive")
 						survivors = append(survivors, value)
 					}
@@ -169,44 +178,33 @@ func (c *Controller) RegExpMatch(
 	// Сравним кол-во элементов в группах и определим не потерялось ли чего
 	if get_min(result) != get_max(result) {
 		// Если кол-во элементов в группах не совпадает, то ошибка
-		logger.ERROR(ports.ERROR_REGEX_GROUP_NE)
+		c.Log.ERROR(ports.ERROR_REGEX_GROUP_NE)
 		return nil, -1, errors.New("Count of element in different groups is not equal, max: " +
 			strconv.Itoa(get_max(result)) + ", min: " + strconv.Itoa(get_min(result)) + ", st 2")
 	}
 
-	logger.DEBUG("CTRL_REGIT: Result group: " + fmt.Sprint(result))
+	c.Log.DEBUG("CTRL_REGIT: Result group: " + fmt.Sprint(result))
 	return result, get_min(result), nil
 }
 
 /*
  * Controller.RegExpConstructor
  *
- * Метод преобразует переменные, указанные в JSON строке в формате {{name}} в
- * соответтсвующие этой переменной значению. Если такой переменной нету - ошибка
+ * Метод преобразует шаблон, указанный в JSON строке (переменные в формате
+ * {{name}}, фильтры и условия, см. пакет template), в текст со значениями
+ * переменных. Если переменной нету и не задано значение по умолчанию - ошибка
+ * Пустое значение переменной допустимо
  *
  * Пример работы:
  *  строка:      show ip route vrf {{vrfname}}
  *  артефакты:   map[vrfname: big-data]
  *  результат:   show ip route vrf big-data
+ *
+ *  строка:      {{ iface | lower | sanitize }}.txt
+ *  артефакты:   map[iface: Gi0/1]
+ *  результат:   gi0_1.txt
  */
 func (c *Controller) RegExpConstructor(text string, variables Artefacts) (string, error) {
 
-	// Компилируем искомое регулярное выражение, которое мы хотим найти в тексте
-	compiledRegExp := regexp.MustCompile(`{{(?P<variable>[^\s\t]+?)}}`)
-
-	// Пройдёмся по всем найденным подстрокам и заменим их соответствующим значением
-	for _, match := range compiledRegExp.FindAllStringSubmatch(text, -1) {
-
-		// Если в артефактах существует значение с найденным в тексте ключом
-		if newValue := variables[match[1]]; newValue != "" {
-			// Заменяем всё подвыражение {{name}} на значение переменной
-			text = strings.Replace(text, match[0], newValue, -1)
-		} else {
-			logger.ERROR(ports.ERROR_REGEX_VAR_NOT_EXIST)
-			return text, errors.New(fmt.Sprintf("Text contains variable '%s', but"+
-				" suited variable do not exist", match[0]))
-		}
-	}
-
-	return text, nil
+	return template.Render(text, variables)
 }
@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/andomize/network-automation-executor/internal/core/domains"
+	"github.com/andomize/network-automation-executor/internal/core/ports"
+)
+
+// Способы сохранения найденных значений параметром register
+const (
+	RegisterFirst = "first"
+	RegisterLast  = "last"
+	RegisterJoin  = "join"
+	RegisterCount = "count"
+)
+
+/*
+ * Capture
+ *
+ * Найти значения групп регулярного выражения register в выводе команды
+ * Имя переменной - имя группы (?P<name>...) или её номер
+ *  first  - значение из первого совпадения (по умолчанию)
+ *  last   - значение из последнего совпадения
+ *  join   - значения всех совпадений через разделитель (по умолчанию ",")
+ *  count  - количество совпадений
+ * Для first и last без совпадений переменные не устанавливаются
+ */
+func Capture(output string, params domains.Param) (map[string]string, error) {
+
+	expression, compileError := regexp.Compile(params.Register)
+	if compileError != nil {
+		return nil, compileError
+	}
+	if expression.NumSubexp() <= 0 {
+		return nil, errors.New(ports.ERROR_REGISTER_NO_GROUPS)
+	}
+
+	separator := params.RegisterSeparator
+	if len(separator) <= 0 {
+		separator = ","
+	}
+
+	matches := expression.FindAllStringSubmatch(output, -1)
+	result := map[string]string{}
+
+	for groupIndex, name := range expression.SubexpNames() {
+		if groupIndex <= 0 {
+			continue
+		}
+		if len(name) <= 0 {
+			name = strconv.Itoa(groupIndex)
+		}
+
+		switch params.RegisterMode {
+		case "", RegisterFirst:
+			if len(matches) > 0 {
+				result[name] = matches[0][groupIndex]
+			}
+		case RegisterLast:
+			if len(matches) > 0 {
+				result[name] = matches[len(matches)-1][groupIndex]
+			}
+		case RegisterJoin:
+			values := make([]string, len(matches))
+			for matchIndex, match := range matches {
+				values[matchIndex] = match[groupIndex]
+			}
+			result[name] = strings.Join(values, separator)
+		case RegisterCount:
+			result[name] = strconv.Itoa(len(matches))
+		default:
+			return nil, errors.New(ports.ERROR_REGISTER_MODE)
+		}
+	}
+
+	return result, nil
+}
+
+/*
+ * Controller.Register
+ *
+ * Сохранить значения из вывода команды в переменные vars: они доступны
+ * следующим заданиям текущего уровня и их подзаданиям. На корневом уровне
+ * vars - переменные контроллера, доступные также автотестам; значения из
+ * подзаданий и повторений цикла за пределы своего уровня не выходят
+ */
+func (c *Controller) Register(output string, params domains.Param, vars Artefacts) error {
+
+	captured, captureError := Capture(output, params)
+	if captureError != nil {
+		return captureError
+	}
+
+	if len(captured) <= 0 {
+		c.Log.INFO("CTRL_REGISTER: Regular expression returns zero values, variables are not set")
+		return nil
+	}
+
+	for name, value := range captured {
+		c.Log.DEBUG(fmt.Sprintf("CTRL_REGISTER: Set variable '%s' = '%s'", name, value))
+		vars[name] = value
+	}
+
+	return nil
+}
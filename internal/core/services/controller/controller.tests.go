@@ -4,8 +4,8 @@ import (
 	"errors"
 	"fmt"
 
-	"github.com/andomize/network-automation-executor/internal/adapters/logger"
 	"github.com/andomize/network-automation-executor/internal/core/domains"
+	"github.com/andomize/network-automation-executor/internal/core/ports"
 )
 
 /*
@@ -18,30 +18,40 @@ func (c *Controller) Autotests() error {
 	var testsResult error = nil
 
 	if c.Task.Autotests != nil && len(*c.Task.Autotests) > 0 {
-		logger.INFO("CTRL_AUTOTESTS: Start conditions autotesting")
+		c.Log.INFO("CTRL_AUTOTESTS: Start conditions autotesting")
 
 		for testIdx, test := range *c.Task.Autotests {
 
-			testPassed, testError := c.WhenMatcher(&[]domains.When{test}, c.Variables)
+			// Шаблоны в условиях теста подставляются из переменных задания
+			compiled, testError := c.CompileWhen([]domains.When{test}, c.Variables)
+			var testPassed bool
+			if testError == nil {
+				testPassed, testError = c.WhenMatcher(&compiled, c.Variables)
+			}
 
 			if testError != nil {
-				logger.INFO(fmt.Sprintf("CTRL_AUTOTESTS: TEST[%v] FAIL: %v", testIdx, testError))
+				c.Log.INFO(fmt.Sprintf("CTRL_AUTOTESTS: TEST[%v] FAIL: %v", testIdx, testError))
 				testsResult = errors.New(fmt.Sprintf("TEST[%v] FAIL: %v", testIdx, testError))
 				continue
 			}
 
 			if !testPassed {
-				logger.INFO(fmt.Sprintf("CTRL_AUTOTESTS: TEST[%v] FAIL: Condition failed", testIdx))
+				c.Log.INFO(fmt.Sprintf("CTRL_AUTOTESTS: TEST[%v] FAIL: Condition failed", testIdx))
 				testsResult = errors.New(fmt.Sprintf("TEST[%v] FAIL: %v", testIdx, testError))
 				continue
 			}
 
 			if testPassed {
-				logger.INFO(fmt.Sprintf("CTRL_AUTOTESTS: TEST[%v] PASSED", testIdx))
+				c.Log.INFO(fmt.Sprintf("CTRL_AUTOTESTS: TEST[%v] PASSED", testIdx))
 				continue
 			}
 
-			logger.INFO(fmt.Sprintf("CTRL_AUTOTESTS: TEST[%v] Unknown tests error", testIdx))
+			c.Log.INFO(fmt.Sprintf("CTRL_AUTOTESTS: TEST[%v] Unknown tests error", testIdx))
+		}
+
+		c.AutotestsStatus = ports.PIPE_STATUS_SUCCESS
+		if testsResult != nil {
+			c.AutotestsStatus = ports.PIPE_STATUS_FAIL
 		}
 	}
 
@@ -0,0 +1,115 @@
+package controller
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/andomize/network-automation-executor/internal/adapters/filestorage"
+	"github.com/andomize/network-automation-executor/internal/adapters/transferserver"
+	"github.com/andomize/network-automation-executor/internal/core/domains"
+	"github.com/andomize/network-automation-executor/internal/core/ports"
+	"github.com/andomize/network-automation-executor/internal/core/services/spawner"
+)
+
+/*
+ * Controller.Transfer
+ *
+ * Передать файл между хостом и локальной файловой системой по SCP/SFTP
+ * Загружаемые с хоста файлы сохраняются в директорию выводов, контрольная
+ * сумма переданного файла записывается в задание
+ */
+func (c *Controller) Transfer(task *domains.Task) (string, error) {
+	c.Log.DEBUG("CTRL_TRANSFER: Starting transfer '" + task.Params.Transfer +
+		"' of remote file: '" + task.Params.RemoteFile + "'")
+
+	if len(task.Params.RemoteFile) <= 0 {
+		return "", errors.New(ports.ERROR_TRANSFER_NO_FILE)
+	}
+
+	var localPath string
+
+	switch task.Params.Transfer {
+
+	// Загрузка файла с хоста в директорию выводов
+	case spawner.TransferSCPGet, spawner.TransferSFTPGet:
+		localFile := task.Params.LocalFile
+		if len(localFile) <= 0 {
+			// Имя файла по умолчанию - последний элемент удалённого пути,
+			// например "nvram:startup-config" -> "startup-config"
+			localFile = task.Params.RemoteFile[strings.LastIndexAny(task.Params.RemoteFile, "/:")+1:]
+		}
+
+		path, pathError := c.OutputStorage.Path(localFile)
+		if pathError != nil {
+			return "", pathError
+		}
+		localPath = path
+
+	// Отправка локального файла на хост
+	// Относительный путь отсчитывается от директории файла задания
+	case spawner.TransferSCPPut, spawner.TransferSFTPPut:
+		if len(task.Params.LocalFile) <= 0 {
+			return "", errors.New(ports.ERROR_TRANSFER_NO_FILE)
+		}
+
+		path, pathError := c.LocalPath(task.Params.LocalFile)
+		if pathError != nil {
+			return "", pathError
+		}
+		localPath = path
+
+	default:
+		return "", errors.New(ports.ERROR_TRANSFER_METHOD)
+	}
+
+	output, transferError := c.Connection.Transfer(
+		task.Params.Transfer, task.Params.RemoteFile, localPath, task.Params.Timeout)
+	if transferError != nil {
+		return output, transferError
+	}
+
+	checksum, checksumError := filestorage.Checksum(localPath)
+	if checksumError != nil {
+		return output, checksumError
+	}
+	task.Checksum = checksum
+
+	c.Log.INFO("CTRL_TRANSFER: File '" + localPath + "' transferred, checksum: '" + checksum + "'")
+	return output, nil
+}
+
+/*
+ * Controller.UseTransferServer
+ *
+ * Добавить переменные встроенного сервера передачи файлов:
+ *  {{transferServer}}     - IP адрес сервера, доступный для хоста
+ *  {{transferServerHttp}} - IP адрес и порт HTTP сервера
+ * Если адрес не указан явно, то он определяется по таблице маршрутизации
+ * Переменные из файла задания имеют приоритет
+ */
+func (c *Controller) UseTransferServer(server *transferserver.TransferServer, address string) error {
+
+	if len(address) <= 0 {
+		advertiseAddress, advertiseError := transferserver.AdvertiseAddress(c.Task.Host)
+		if advertiseError != nil {
+			return advertiseError
+		}
+		address = advertiseAddress
+	}
+
+	var variables = Artefacts{"transferServer": address}
+	if httpAddr := server.HTTPAddr(); httpAddr != nil {
+		variables["transferServerHttp"] = net.JoinHostPort(address, strconv.Itoa(httpAddr.Port))
+	}
+
+	for index, value := range variables {
+		if _, exist := c.Task.Variables[index]; !exist {
+			c.Log.DEBUG("CTRL_TRANSFER: Adding new variable '" + index + "' = '" + value + "'")
+			c.Variables[index] = value
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/andomize/network-automation-executor/internal/core/domains"
+	"github.com/andomize/network-automation-executor/internal/core/ports"
+)
+
+/*
+ * Controller.SendUntil
+ *
+ * Отправить команду задания, а при наличии параметра until повторять её,
+ * пока вывод не совпадёт с регулярным выражением (после reload, failover,
+ * clear bgp и т.п.):
+ *  - retries - количество попыток (по умолчанию UNTIL_RETRIES)
+ *  - delay   - пауза между попытками в секундах (по умолчанию UNTIL_DELAY)
+ * Выводы всех попыток сохраняются в данных именованного задания (Attempts)
+ * Если совпадение не найдено, возвращается вывод последней попытки и ошибка
+ */
+func (c *Controller) SendUntil(task *domains.Task) (string, error) {
+
+	if len(task.Params.Until) <= 0 {
+		return c.Send(task)
+	}
+
+	until, compileError := regexp.Compile(task.Params.Until)
+	if compileError != nil {
+		c.Log.ERROR("CTRL_UNTIL: Regular expression is fail by reason: " + compileError.Error())
+		return "", compileError
+	}
+
+	retries := task.Params.Retries
+	if retries <= 0 {
+		retries = ports.UNTIL_RETRIES
+	}
+	delay := task.Params.Delay
+	if delay <= 0 {
+		delay = ports.UNTIL_DELAY
+	}
+
+	if len(task.Name) > 0 && c.Names[task.Name] != nil {
+		c.Names[task.Name].Attempts = nil
+	}
+
+	var output string
+	for attempt := 1; attempt <= retries; attempt++ {
+
+		var sendError error
+		if output, sendError = c.Send(task); sendError != nil {
+			return output, sendError
+		}
+
+		if len(task.Name) > 0 {
+			c.Names[task.Name].Attempts = append(c.Names[task.Name].Attempts, output)
+		}
+
+		if until.MatchString(output) {
+			c.Log.INFO(fmt.Sprintf("CTRL_UNTIL: Output of command '%s' matches until, attempt %d/%d",
+				task.Command, attempt, retries))
+			return output, nil
+		}
+
+		c.Log.INFO(fmt.Sprintf("CTRL_UNTIL: Output of command '%s' does not match until, attempt %d/%d",
+			task.Command, attempt, retries))
+
+		if attempt < retries {
+			if abortCode := c.wait(time.Duration(delay) * time.Second); len(abortCode) > 0 {
+				return output, errors.New(abortCode)
+			}
+		}
+	}
+
+	return output, errors.New(ports.ERROR_UNTIL_NOT_MATCHED)
+}
+
+/*
+ * Controller.wait
+ *
+ * Пауза с проверкой прерывания выполнения извне, возвращает код прерывания
+ */
+func (c *Controller) wait(duration time.Duration) string {
+
+	deadline := time.Now().Add(duration)
+	for time.Now().Before(deadline) {
+		if abortCode := c.Aborted(); len(abortCode) > 0 {
+			return abortCode
+		}
+		step := time.Until(deadline)
+		if step > time.Second {
+			step = time.Second
+		}
+		time.Sleep(step)
+	}
+
+	return c.Aborted()
+}
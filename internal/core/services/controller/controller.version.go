@@ -0,0 +1,177 @@
+package controller
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Сегменты версии: числа и буквенные части
+var versionSegmentRegExp = regexp.MustCompile(`\d+|[A-Za-z]+`)
+
+/*
+ * Версии ПО в выводах show version / display version (порядок важен):
+ *  NX-OS   "NXOS: version 9.3(8)", "system:    version 7.0(3)I7(9)"
+ *  Huawei  "VRP (R) software, Version 5.170 (S5720 V200R019C10SPC500)"
+ *  ASA     "Cisco Adaptive Security Appliance Software Version 9.12(4)30"
+ *  IOS     "Cisco IOS Software, ... Version 15.2(4)E10, RELEASE SOFTWARE"
+ *  F5      "Version     15.1.8.2"
+ */
+var versionRegExps = []*regexp.Regexp{
+	regexp.MustCompile(`NXOS: version (\S+)`),
+	regexp.MustCompile(`system:\s+version\s+(\S+)`),
+	regexp.MustCompile(`\b(V\d{3}R\d{3}C\d{2}\w*)`),
+	regexp.MustCompile(`Software Version (\S+)`),
+	regexp.MustCompile(`Version\s+(\d[^\s,]*)`),
+}
+
+/*
+ * CompareVersions
+ *
+ * Сравнить версии ПО, возвращает -1 (a < b), 0 (a = b) или 1 (a > b)
+ * Версия разбивается на числовые и буквенные сегменты, разделители
+ * ".", "(", ")" и т.п. не учитываются:
+ *  Cisco IOS  15.2(4)E10         -> 15 2 4 E 10
+ *  NX-OS      9.3(8)             -> 9 3 8
+ *  ASA        9.12(4)30          -> 9 12 4 30
+ *  Huawei     V200R019C10SPC500  -> V 200 R 19 C 10 SPC 500
+ *  F5         15.1.8.2           -> 15 1 8 2
+ * Числа сравниваются как числа, буквы - без учёта регистра. Отсутствующий
+ * сегмент равен 0 для чисел и меньше любого буквенного сегмента
+ * (15.2(4) < 15.2(4)E10, 9.3 = 9.3.0)
+ */
+func CompareVersions(a, b string) int {
+
+	segmentsA := versionSegmentRegExp.FindAllString(a, -1)
+	segmentsB := versionSegmentRegExp.FindAllString(b, -1)
+
+	for index := 0; index < len(segmentsA) || index < len(segmentsB); index++ {
+
+		var segmentA, segmentB string
+		if index < len(segmentsA) {
+			segmentA = segmentsA[index]
+		}
+		if index < len(segmentsB) {
+			segmentB = segmentsB[index]
+		}
+
+		if result := compareSegments(segmentA, segmentB); result != 0 {
+			return result
+		}
+	}
+
+	return 0
+}
+
+func compareSegments(a, b string) int {
+
+	numberA, numberB := isNumber(a), isNumber(b)
+
+	// Отсутствующий сегмент
+	if len(a) <= 0 && numberB || len(b) <= 0 && numberA {
+		return compareNumbers(orZero(a), orZero(b))
+	}
+	if len(a) <= 0 || len(b) <= 0 {
+		return compareStrings(len(a), len(b))
+	}
+
+	switch {
+	case numberA && numberB:
+		return compareNumbers(a, b)
+	case numberA:
+		return 1
+	case numberB:
+		return -1
+	}
+
+	return strings.Compare(strings.ToUpper(a), strings.ToUpper(b))
+}
+
+// Сравнение чисел произвольной длины
+func compareNumbers(a, b string) int {
+	a = strings.TrimLeft(a, "0")
+	b = strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		return compareStrings(len(a), len(b))
+	}
+	return strings.Compare(a, b)
+}
+
+func compareStrings(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func isNumber(text string) bool {
+	return len(text) > 0 && text[0] >= '0' && text[0] <= '9'
+}
+
+func orZero(text string) string {
+	if len(text) <= 0 {
+		return "0"
+	}
+	return text
+}
+
+/*
+ * ExtractValue
+ *
+ * Извлечь значение из вывода регулярным выражением: первая группа или
+ * всё совпадение, если групп нет. Без выражения (extract) ищется версия ПО
+ * в известных форматах вывода
+ */
+func ExtractValue(output, extract string) (string, bool, error) {
+
+	expressions := versionRegExps
+	if len(extract) > 0 {
+		compiled, compileError := regexp.Compile(extract)
+		if compileError != nil {
+			return "", false, compileError
+		}
+		expressions = []*regexp.Regexp{compiled}
+	}
+
+	for _, expression := range expressions {
+		match := expression.FindStringSubmatch(output)
+		if match == nil {
+			continue
+		}
+		if len(match) > 1 {
+			return match[1], true, nil
+		}
+		return match[0], true, nil
+	}
+
+	return "", false, nil
+}
+
+/*
+ * MatchVersion
+ *
+ * Проверить условия ifVersion* для версии value
+ * Возвращает результат и описание невыполненной проверки
+ */
+func MatchVersion(gt, gte, lt, lte, value string) (bool, string) {
+
+	checks := []struct {
+		name, want string
+		passed     func(result int) bool
+	}{
+		{"ifVersionGt", gt, func(result int) bool { return result > 0 }},
+		{"ifVersionGte", gte, func(result int) bool { return result >= 0 }},
+		{"ifVersionLt", lt, func(result int) bool { return result < 0 }},
+		{"ifVersionLte", lte, func(result int) bool { return result <= 0 }},
+	}
+
+	for _, check := range checks {
+		if len(check.want) > 0 && !check.passed(CompareVersions(value, check.want)) {
+			return false, check.name + " '" + check.want + "' fail"
+		}
+	}
+
+	return true, ""
+}
@@ -6,7 +6,6 @@ import (
 	"regexp"
 	"strings"
 
-	"github.com/andomize/network-automation-executor/internal/adapters/logger"
 	"github.com/andomize/network-automation-executor/internal/core/domains"
 	"github.com/andomize/network-automation-executor/internal/core/ports"
 )
@@ -14,135 +13,275 @@ import (
 /*
  * Controller.WhenMatcher
  *
- * Метод проверяет наличие условных выражений в задании и проверяет их корректность
+ * Метод проверяет условные выражения задания
+ *  - Элементы массива when объединяются по "и" (неявная группа all)
+ *  - Группы all (все условия), any (хотя бы одно) и not (отрицание)
+ *    вкладываются друг в друга без ограничений
+ *  - Каждый лист - проверка по имени ранее выполненного задания (name) или
+ *    по переменной (variable); элемент с листом и группами истинен, если
+ *    истинны все его части
+ * Действия (onMove, onExit) элементов массива выполняются, только если
+ * результат всего условия - истина. Ход вычисления записывается в журнал
  */
 func (c *Controller) WhenMatcher(when *[]domains.When, vars Artefacts) (bool, error) {
 
-	// Если условие есть, то необъодимо проверить что все требования соответствуют
-	// и только после этого выполнять задание
-	if when != nil && len(*when) > 0 {
-
-		logger.DEBUG("WHEN_MATCH: Founded <when> field in task")
-		logger.DEBUG("WHEN_MATCH: Starting match condition")
-
-		// Условие существует - выполяем перебор всех условий и проверяем их
-		for whenIdx, when := range *when {
-
-			logger.DEBUG(fmt.Sprintf("WHEN_MATCH: Processing condition Idx: '%v'", whenIdx))
-
-			// Проверяем что бы в условии не было одновременно несколько типов проверок
-			if len(when.Name) > 0 && len(when.Variable) > 0 {
-				logger.ERROR("WHEN_MATCH: WHEN::Name && WHEN::Variable is not allowed")
-				return false, errors.New(ports.ERROR_WHEN_CONDITION_DOUBLE_BASED)
-			}
-
-			// Проверяем что требуется выполнить проверку по имени ранее выполненной задачи
-			if len(when.Name) > 0 {
-
-				logger.DEBUG("WHEN_MATCH: Condition have 'name' field, verifying")
-
-				// Проверяем что имя задания существует в памяти
-				if c.Names[when.Name] == nil || len(c.Names[when.Name].Status) <= 0 {
-					logger.WARNING("WHEN_MATCH: WHEN::NAME Name '" + when.Name + "' does not exist in memory, skipping...")
-					return false, nil
-				}
-
-				// IfStatus
-				if len(when.IfStatus) > 0 {
-					if c.Names[when.Name].Status != when.IfStatus {
-						logger.WARNING("WHEN_MATCH: WHEN::NAME::IfStatus condition fail, want: '" + when.IfStatus + "', have: '" + c.Names[when.Name].Status + "'")
-						return false, nil
-					}
-				}
-
-				// IfOutputContains
-				if len(when.IfOutputContains) > 0 {
-					if !strings.Contains(c.Names[when.Name].Output, when.IfOutputContains) {
-						logger.WARNING("WHEN_MATCH: WHEN::NAME::IfOutputContains condition fail, searched string: '" + when.IfOutputContains + "' in task name '" + when.Name + "'")
-						return false, nil
-					}
-				}
-
-				// IfOutputNotContains
-				if len(when.IfOutputNotContains) > 0 {
-					if strings.Contains(c.Names[when.Name].Output, when.IfOutputNotContains) {
-						logger.WARNING("WHEN_MATCH: WHEN::NAME::IfOutputNotContains condition fail, searched string: '" + when.IfOutputNotContains + "' in task name '" + when.Name + "'")
-						return false, nil
-					}
-				}
-
-				// IfOutputNotContains
-				if len(when.IfOutputContainsRe) > 0 {
-					match, matchError := regexp.MatchString(when.IfOutputContainsRe, c.Names[when.Name].Output)
-					if !match || matchError != nil {
-						logger.WARNING("WHEN_MATCH: WHEN::NAME::IfOutputContainsRe condition fail, searched regexp: '" + when.IfOutputContainsRe + "' in task name '" + when.Name + "'")
-						return false, nil
-					}
-				}
-
-				// IfOutputNotContains
-				if len(when.IfOutputNotContainsRe) > 0 {
-					match, matchError := regexp.MatchString(when.IfOutputNotContainsRe, c.Names[when.Name].Output)
-					if match && matchError != nil {
-						logger.WARNING("WHEN_MATCH: WHEN::NAME::IfOutputNotContainsRe condition fail, searched regexp: '" + when.IfOutputNotContainsRe + "' in task name '" + when.Name + "'")
-						return false, nil
-					}
-				}
-
-				// Actions
-				if actionError := c.WhenActions(&when); actionError != nil {
-					return false, actionError
-				}
-
-				// Все условия успешно пройдены - выход
-				logger.DEBUG("WHEN_MATCH: WHEN::NAME all condition stage is successful")
-				return true, nil
-			}
-
-			// Проверяем что требуется выполнить проверку по одной из переменных
-			if len(when.Variable) > 0 {
-
-				logger.DEBUG("WHEN_MATCH: Condition have 'variables' field, verifying")
-
-				// Проверяем что имя переменной существует в артефактах
-				if len(vars[when.Variable]) <= 0 {
-					logger.WARNING("WHEN_MATCH: WHEN::VARIABLE Variable '" + when.Variable + "' does not exist in memory, skipping...")
-					return false, nil
-				}
-
-				// IfValue
-				if len(when.IfValue) > 0 {
-					if vars[when.Variable] != when.IfValue {
-						logger.WARNING("WHEN_MATCH: WHEN::VARIABLE::IfValue condition fail, want: '" + when.IfValue + "', have: '" + vars[when.Variable] + "'")
-						return false, nil
-					}
-				}
-
-				// IfValueNot
-				if len(when.IfValueNot) > 0 {
-					if vars[when.Variable] == when.IfValueNot {
-						logger.WARNING("WHEN_MATCH: WHEN::VARIABLE::IfValueNot condition fail: '" + when.IfValueNot + "' same as: '" + vars[when.Variable] + "'")
-						return false, nil
-					}
-				}
-
-				// Actions
-				if actionError := c.WhenActions(&when); actionError != nil {
-					return false, actionError
-				}
-
-				// Все условия успешно пройдены - выход
-				logger.DEBUG("WHEN_MATCH: WHEN::VARIABLE all condition stage is successful")
-				return true, nil
-			}
+	// Условных выражений в задании нету - всё ОК
+	if when == nil || len(*when) <= 0 {
+		c.Log.DEBUG("WHEN_MATCH: Skipped match condition, no <when> field in task")
+		return true, nil
+	}
+
+	c.Log.DEBUG("WHEN_MATCH: Founded <when> field in task")
+	c.Log.DEBUG("WHEN_MATCH: Starting match condition")
+
+	var trace []string
+	matched, matchError := c.whenGroup("all", *when, vars, 0, "", &trace)
+	if matchError != nil {
+		c.Log.ERROR("WHEN_MATCH: " + matchError.Error())
+		return false, matchError
+	}
+
+	// Ход вычисления: при невыполненном условии видно, почему задание пропущено
+	for _, line := range trace {
+		if matched {
+			c.Log.DEBUG("WHEN_TRACE: " + line)
+		} else {
+			c.Log.INFO("WHEN_TRACE: " + line)
 		}
 	}
 
-	// Условных выражений в задании нету - всё ОК
-	logger.DEBUG("WHEN_MATCH: Skipped match condition, no <when> field in task")
+	if !matched {
+		return false, nil
+	}
+
+	// Actions
+	for whenIdx := range *when {
+		if actionError := c.WhenActions(&(*when)[whenIdx]); actionError != nil {
+			return false, actionError
+		}
+	}
+
+	c.Log.DEBUG("WHEN_MATCH: All condition stage is successful")
 	return true, nil
 }
 
+/*
+ * Controller.whenGroup
+ *
+ * Вычислить группу условий all или any
+ * Вычисляются все условия группы, чтобы ход вычисления был полным
+ */
+func (c *Controller) whenGroup(kind string, when []domains.When, vars Artefacts, depth int, label string, trace *[]string) (bool, error) {
+
+	line := len(*trace)
+	*trace = append(*trace, "")
+
+	matched := kind == "all"
+	for whenIdx, condition := range when {
+		result, matchError := c.whenNode(condition, vars, depth+1, fmt.Sprintf("[%v] ", whenIdx), trace)
+		if matchError != nil {
+			return false, matchError
+		}
+		if kind == "all" {
+			matched = matched && result
+		} else {
+			matched = matched || result
+		}
+	}
+
+	(*trace)[line] = fmt.Sprintf("%s%s%s: %v", strings.Repeat("  ", depth), label, kind, matched)
+	return matched, nil
+}
+
+/*
+ * Controller.whenNode
+ *
+ * Вычислить элемент условия: лист и вложенные группы
+ */
+func (c *Controller) whenNode(when domains.When, vars Artefacts, depth int, label string, trace *[]string) (bool, error) {
+
+	indent := strings.Repeat("  ", depth)
+	matched := true
+	empty := true
+
+	// Проверяем что бы в условии не было одновременно несколько типов проверок
+	if len(when.Name) > 0 && len(when.Variable) > 0 {
+		c.Log.ERROR("WHEN_MATCH: WHEN::Name && WHEN::Variable is not allowed")
+		return false, errors.New(ports.ERROR_WHEN_CONDITION_DOUBLE_BASED)
+	}
+
+	if len(when.Name) > 0 || len(when.Variable) > 0 {
+		empty = false
+		var result bool
+		var description string
+		if len(when.Name) > 0 {
+			result, description = c.whenName(when)
+		} else {
+			result, description = MatchVariable(when, vars)
+		}
+		*trace = append(*trace, fmt.Sprintf("%s%s%s: %v", indent, label, description, result))
+		matched = matched && result
+	}
+
+	if when.All != nil {
+		empty = false
+		result, matchError := c.whenGroup("all", *when.All, vars, depth, label, trace)
+		if matchError != nil {
+			return false, matchError
+		}
+		matched = matched && result
+	}
+
+	if when.Any != nil {
+		empty = false
+		result, matchError := c.whenGroup("any", *when.Any, vars, depth, label, trace)
+		if matchError != nil {
+			return false, matchError
+		}
+		matched = matched && result
+	}
+
+	if when.Not != nil {
+		empty = false
+		line := len(*trace)
+		*trace = append(*trace, "")
+		result, matchError := c.whenNode(*when.Not, vars, depth+1, "", trace)
+		if matchError != nil {
+			return false, matchError
+		}
+		(*trace)[line] = fmt.Sprintf("%s%snot: %v", indent, label, !result)
+		matched = matched && !result
+	}
+
+	// Элемент без проверок (например, только действия) не влияет на результат
+	if empty {
+		*trace = append(*trace, fmt.Sprintf("%s%sno checks: true", indent, label))
+	}
+
+	return matched, nil
+}
+
+/*
+ * Controller.whenName
+ *
+ * Проверка по имени ранее выполненного задания
+ * Возвращает результат и описание проверки для журнала
+ */
+func (c *Controller) whenName(when domains.When) (bool, string) {
+
+	description := "name '" + when.Name + "'"
+
+	// Проверяем что имя задания существует в памяти
+	named := c.Names[when.Name]
+	if named == nil || len(named.Status) <= 0 {
+		return false, description + " was not executed"
+	}
+
+	// IfStatus
+	if len(when.IfStatus) > 0 && named.Status != when.IfStatus {
+		return false, fmt.Sprintf("%s ifStatus want '%s', have '%s'", description, when.IfStatus, named.Status)
+	}
+
+	// IfOutputContains
+	if len(when.IfOutputContains) > 0 && !strings.Contains(named.Output, when.IfOutputContains) {
+		return false, description + " ifOutputContains '" + when.IfOutputContains + "' not found"
+	}
+
+	// IfOutputNotContains
+	if len(when.IfOutputNotContains) > 0 && strings.Contains(named.Output, when.IfOutputNotContains) {
+		return false, description + " ifOutputNotContains '" + when.IfOutputNotContains + "' found"
+	}
+
+	// IfOutputContainsRe
+	if len(when.IfOutputContainsRe) > 0 {
+		match, matchError := regexp.MatchString(when.IfOutputContainsRe, named.Output)
+		if !match || matchError != nil {
+			return false, description + " ifOutputContainsRe '" + when.IfOutputContainsRe + "' not found"
+		}
+	}
+
+	// IfOutputNotContainsRe
+	if len(when.IfOutputNotContainsRe) > 0 {
+		match, matchError := regexp.MatchString(when.IfOutputNotContainsRe, named.Output)
+		if match && matchError == nil {
+			return false, description + " ifOutputNotContainsRe '" + when.IfOutputNotContainsRe + "' found"
+		}
+	}
+
+	description += " status '" + named.Status + "'"
+
+	// Значение из вывода задания для сравнения версий и чисел
+	if hasVersionChecks(when) || hasNumberChecks(when) || len(when.Extract) > 0 {
+		if hasNumberChecks(when) && len(when.Extract) <= 0 {
+			return false, description + ", ifNumber* require extract"
+		}
+		value, found, extractError := ExtractValue(named.Output, when.Extract)
+		if extractError != nil || !found {
+			return false, description + ", value is not found in output"
+		}
+		description += ", value '" + value + "'"
+
+		if matched, reason := MatchVersion(when.IfVersionGt, when.IfVersionGte,
+			when.IfVersionLt, when.IfVersionLte, value); !matched {
+			return false, description + " " + reason
+		}
+
+		if matched, reason := MatchNumber(when, value); !matched {
+			return false, description + " " + reason
+		}
+	}
+
+	return true, description
+}
+
+// Заданы ли в условии сравнения версий
+func hasVersionChecks(when domains.When) bool {
+	return len(when.IfVersionGt) > 0 || len(when.IfVersionGte) > 0 ||
+		len(when.IfVersionLt) > 0 || len(when.IfVersionLte) > 0
+}
+
+/*
+ * MatchVariable
+ *
+ * Проверка по значению переменной
+ * Возвращает результат и описание проверки для журнала
+ */
+func MatchVariable(when domains.When, vars Artefacts) (bool, string) {
+
+	value := vars[when.Variable]
+	description := "variable '" + when.Variable + "'"
+
+	// Проверяем что имя переменной существует в артефактах
+	if len(value) <= 0 {
+		return false, description + " is empty or does not exist"
+	}
+
+	// IfValue
+	if len(when.IfValue) > 0 && value != when.IfValue {
+		return false, fmt.Sprintf("%s ifValue want '%s', have '%s'", description, when.IfValue, value)
+	}
+
+	// IfValueNot
+	if len(when.IfValueNot) > 0 && value == when.IfValueNot {
+		return false, fmt.Sprintf("%s ifValueNot '%s' same as value", description, when.IfValueNot)
+	}
+
+	description += " = '" + value + "'"
+
+	// IfVersion*
+	if matched, reason := MatchVersion(when.IfVersionGt, when.IfVersionGte,
+		when.IfVersionLt, when.IfVersionLte, value); !matched {
+		return false, description + " " + reason
+	}
+
+	// IfNumber*
+	if matched, reason := MatchNumber(when, value); !matched {
+		return false, description + " " + reason
+	}
+
+	return true, description
+}
+
 /*
  * Controller.WhenActions
  *
@@ -157,14 +296,15 @@ func (c *Controller) WhenActions(when *domains.When) error {
 
 	// OnExit
 	if when.OnExit {
-		logger.INFO("WHEN_ACTION: WHEN::OnExit is set, exiting...")
-		c.ExitSuccess()
+		c.Log.INFO("WHEN_ACTION: WHEN::OnExit is set, exiting...")
+		c.Stopped = true
 	}
 
 	// OnMove
 	if len(when.OnMove) > 0 {
-		logger.INFO("WHEN_ACTION: WHEN::OnMove is set, next task name is '" + when.OnMove + "'")
+		c.Log.INFO("WHEN_ACTION: WHEN::OnMove is set, next task name is '" + when.OnMove + "'")
 		c.NextTaskName = when.OnMove
+		c.Emit(domains.Event{Event: ports.STREAM_EVENT_GOTO, Target: when.OnMove})
 	}
 
 	return nil
@@ -0,0 +1,467 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andomize/network-automation-executor/internal/adapters/filestorage"
+	"github.com/andomize/network-automation-executor/internal/adapters/inventory"
+	"github.com/andomize/network-automation-executor/internal/adapters/jsontask"
+	"github.com/andomize/network-automation-executor/internal/adapters/logger"
+	"github.com/andomize/network-automation-executor/internal/core/domains"
+	"github.com/andomize/network-automation-executor/internal/core/ports"
+	"github.com/andomize/network-automation-executor/internal/core/services/controller"
+	"github.com/andomize/network-automation-executor/internal/core/services/runner"
+)
+
+// Имя файла исходного задания в директории задания сервера
+const TaskFilename = "task.json"
+
+/*
+ * Manager
+ *
+ * Очередь заданий режима сервера
+ * Каждое задание хранится в отдельной директории <directory>/<id>/:
+ *  - job.json  - состояние задания
+ *  - task.json - исходное задание
+ *  - outputs/  - выводы и результаты хостов (<outputs>/<имя хоста>/)
+ */
+type Manager struct {
+	// Директория для хранения заданий
+	directory string
+
+	// Файл инвентаря для заданий с выбором хостов инвентаря (может отсутствовать)
+	inventoryPath string
+
+	// Параметры выполнения заданий
+	options runner.Options
+
+	// Ограничение количества одновременно выполняемых заданий
+	slots chan struct{}
+
+	// Контекст сервера: при его отмене выполняемые задания прерываются, но
+	// сохраняют состояние "running" и будут запущены повторно после перезапуска
+	ctx context.Context
+
+	mutex   sync.Mutex
+	jobs    map[string]*domains.Job
+	cancels map[string]context.CancelFunc
+}
+
+/*
+ * NewManager
+ *
+ * Создать очередь заданий и восстановить задания, сохранённые ранее
+ * Незавершённые задания (queued, running) ставятся в очередь повторно,
+ * выполнение продолжается с сохранённого результата хоста (команды, уже
+ * выполненные на хосте, повторно не отправляются)
+ */
+func NewManager(
+	ctx context.Context,
+	directory, inventoryPath string,
+	concurrency int,
+	options runner.Options,
+) (*Manager, error) {
+
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	m := &Manager{
+		directory:     directory,
+		inventoryPath: inventoryPath,
+		options:       options,
+		slots:         make(chan struct{}, concurrency),
+		ctx:           ctx,
+		jobs:          map[string]*domains.Job{},
+		cancels:       map[string]context.CancelFunc{},
+	}
+
+	stored, loadError := m.load()
+	if loadError != nil {
+		return nil, loadError
+	}
+
+	for _, job := range stored {
+		m.jobs[job.ID] = job
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, job := range m.sorted() {
+		if job.Status == ports.JOB_STATUS_QUEUED || job.Status == ports.JOB_STATUS_RUNNING {
+			logger.INFO("JOBS: Job '" + job.ID + "' was not finished, queued again")
+			job.Status = ports.JOB_STATUS_QUEUED
+			m.save(job)
+			m.start(job.ID)
+		}
+	}
+
+	return m, nil
+}
+
+/*
+ * Manager.Submit
+ *
+ * Поставить задание в очередь
+ * Задание выполняется на хосте (request.Host или host из задания) или на
+ * хостах инвентаря, выбранных выражением request.Inventory
+ */
+func (m *Manager) Submit(request domains.JobRequest) (domains.Job, error) {
+
+	if request.Task == nil || request.Task.Tasks == nil || len(*request.Task.Tasks) <= 0 {
+		return domains.Job{}, errors.New(ports.ERROR_SYNTAX_NO_TASKS)
+	}
+
+	job := &domains.Job{
+		ID:        newID(),
+		Status:    ports.JOB_STATUS_QUEUED,
+		Host:      request.Host,
+		Inventory: request.Inventory,
+		Variables: request.Variables,
+		Created:   time.Now().Format(time.RFC3339),
+	}
+
+	if len(job.Inventory) > 0 && len(m.inventoryPath) <= 0 {
+		return domains.Job{}, errors.New(ports.ERROR_JOB_NO_INVENTORY)
+	}
+	if len(job.Inventory) <= 0 && len(job.Host) <= 0 {
+		job.Host = request.Task.Host
+	}
+	if len(job.Inventory) <= 0 && len(job.Host) <= 0 {
+		return domains.Job{}, errors.New(ports.ERROR_JOB_NO_TARGET)
+	}
+
+	// Подключаемые файлы должны находиться в директории задания или в библиотеке
+	if includeError := m.checkIncludes(request.Task.Tasks, m.taskPath(job.ID)); includeError != nil {
+		return domains.Job{}, includeError
+	}
+
+	// Задание сохраняется на диск до постановки в очередь
+	if writeError := jsontask.Write(m.taskPath(job.ID), *request.Task); writeError != nil {
+		return domains.Job{}, writeError
+	}
+
+	m.mutex.Lock()
+	m.jobs[job.ID] = job
+	m.save(job)
+	result := copyJob(job)
+	m.mutex.Unlock()
+
+	logger.INFO("JOBS: Job '" + job.ID + "' submitted")
+	m.start(job.ID)
+
+	return result, nil
+}
+
+/*
+ * Manager.List
+ *
+ * Получить все задания в порядке создания
+ */
+func (m *Manager) List() []domains.Job {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var result = []domains.Job{}
+	for _, job := range m.sorted() {
+		result = append(result, copyJob(job))
+	}
+	return result
+}
+
+/*
+ * Manager.Get
+ *
+ * Получить задание по идентификатору
+ */
+func (m *Manager) Get(id string) (domains.Job, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	job, exist := m.jobs[id]
+	if !exist {
+		return domains.Job{}, errors.New(ports.ERROR_JOB_NOT_FOUND)
+	}
+	return copyJob(job), nil
+}
+
+/*
+ * Manager.Cancel
+ *
+ * Отменить задание: задание из очереди не будет запущено, у выполняемого
+ * задания закрываются соединения с хостами
+ */
+func (m *Manager) Cancel(id string) (domains.Job, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	job, exist := m.jobs[id]
+	if !exist {
+		return domains.Job{}, errors.New(ports.ERROR_JOB_NOT_FOUND)
+	}
+
+	if job.Status != ports.JOB_STATUS_QUEUED && job.Status != ports.JOB_STATUS_RUNNING {
+		return copyJob(job), errors.New(ports.ERROR_JOB_FINISHED)
+	}
+
+	logger.INFO("JOBS: Job '" + id + "' cancelled")
+
+	job.Status = ports.JOB_STATUS_CANCELLED
+	job.Error = ports.ERROR_CANCELLED
+	if cancel := m.cancels[id]; cancel != nil {
+		cancel()
+	} else {
+		job.Finished = time.Now().Format(time.RFC3339)
+	}
+	m.save(job)
+
+	return copyJob(job), nil
+}
+
+/*
+ * Manager.ResultPath
+ *
+ * Путь к результату выполнения задания на хосте
+ * Имя хоста может быть пустым, если задание выполнялось на одном хосте
+ */
+func (m *Manager) ResultPath(id, host string) (string, error) {
+	job, jobError := m.Get(id)
+	if jobError != nil {
+		return "", jobError
+	}
+
+	for _, result := range job.Hosts {
+		if (len(host) <= 0 && len(job.Hosts) == 1) || result.Name == host {
+			if len(result.ResultPath) <= 0 {
+				break
+			}
+			return result.ResultPath, nil
+		}
+	}
+
+	return "", fmt.Errorf("Result for host '%s' of job '%s' is not found", host, id)
+}
+
+/*
+ * Manager.OutputDirectory
+ *
+ * Директория выводов задания
+ */
+func (m *Manager) OutputDirectory(id string) string {
+	return filepath.Join(m.directory, id, "outputs")
+}
+
+/*
+ * Manager.start
+ *
+ * Запустить выполнение задания после освобождения места в очереди
+ */
+func (m *Manager) start(id string) {
+	go func() {
+		select {
+		case m.slots <- struct{}{}:
+		case <-m.ctx.Done():
+			return
+		}
+		defer func() { <-m.slots }()
+
+		m.run(id)
+	}()
+}
+
+/*
+ * Manager.run
+ *
+ * Выполнить задание и сохранить его итоговое состояние
+ */
+func (m *Manager) run(id string) {
+
+	m.mutex.Lock()
+	job := m.jobs[id]
+	if job == nil || job.Status != ports.JOB_STATUS_QUEUED {
+		m.mutex.Unlock()
+		return
+	}
+
+	ctx, cancel := context.WithCancel(m.ctx)
+	defer cancel()
+
+	job.Status = ports.JOB_STATUS_RUNNING
+	job.Started = time.Now().Format(time.RFC3339)
+	m.cancels[id] = cancel
+	m.save(job)
+	request := copyJob(job)
+	m.mutex.Unlock()
+
+	logger.INFO("JOBS: Job '" + id + "' started")
+
+	results, runError := m.execute(ctx, request)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.cancels, id)
+
+	// Сервер останавливается - задание будет запущено повторно после перезапуска
+	if m.ctx.Err() != nil {
+		logger.WARNING("JOBS: Job '" + id + "' interrupted by server shutdown")
+		return
+	}
+
+	job.Hosts = results
+	job.Finished = time.Now().Format(time.RFC3339)
+
+	switch {
+	case job.Status == ports.JOB_STATUS_CANCELLED:
+	case runError != nil:
+		job.Status, job.Error = ports.PIPE_STATUS_FAIL, runError.Error()
+	default:
+		job.Status, job.Error = jobStatus(results)
+	}
+
+	m.save(job)
+	logger.INFO("JOBS: Job '" + id + "' finished with status '" + job.Status + "'")
+}
+
+/*
+ * Manager.execute
+ *
+ * Выполнить задание на хосте или на выбранных хостах инвентаря
+ */
+func (m *Manager) execute(ctx context.Context, job domains.Job) ([]domains.HostResult, error) {
+
+	var targets []domains.Target
+
+	if len(job.Inventory) > 0 {
+		inventoryData, inventoryError := inventory.Read(m.inventoryPath)
+		if inventoryError != nil {
+			return nil, inventoryError
+		}
+
+		selected, selectError := inventory.Select(inventoryData, job.Inventory)
+		if selectError != nil {
+			return nil, selectError
+		}
+		targets = selected
+	} else {
+		targets = []domains.Target{{
+			Name:      filestorage.NewFileStorage("").NameNormalization(job.Host),
+			Host:      job.Host,
+			Variables: map[string]string{},
+		}}
+	}
+
+	// Переменные запроса имеют наивысший приоритет
+	for index := range targets {
+		for name, value := range job.Variables {
+			targets[index].Variables[name] = value
+		}
+	}
+
+	// Локальные файлы задания ограничены директорией задания
+	options := m.options
+	options.LocalRoot = filepath.Dir(m.taskPath(job.ID))
+
+	return runner.ExecuteInventory(ctx, m.taskPath(job.ID), m.OutputDirectory(job.ID), targets, options), nil
+}
+
+/*
+ * jobStatus
+ *
+ * Итоговый статус задания по результатам хостов
+ */
+func jobStatus(results []domains.HostResult) (string, string) {
+	failed, _ := runner.FailedCount(results)
+
+	for _, result := range results {
+		if result.Status == ports.PIPE_STATUS_SKIPPED {
+			failed++
+		}
+	}
+
+	if failed <= 0 {
+		return ports.PIPE_STATUS_SUCCESS, ""
+	}
+	if len(results) == 1 {
+		return ports.PIPE_STATUS_FAIL, results[0].Error
+	}
+	return ports.PIPE_STATUS_FAIL, ports.ERROR_JOB_HOSTS_FAILED
+}
+
+/*
+ * Manager.sorted
+ *
+ * Задания в порядке создания (вызывается при захваченном mutex)
+ */
+func (m *Manager) sorted() []*domains.Job {
+	var result = []*domains.Job{}
+	for _, job := range m.jobs {
+		result = append(result, job)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Created == result[j].Created {
+			return result[i].ID < result[j].ID
+		}
+		return result[i].Created < result[j].Created
+	})
+	return result
+}
+
+/*
+ * Manager.checkIncludes
+ *
+ * Проверить, что элементы include задания ссылаются на библиотеку заданий
+ * ("@<name>") или на файлы внутри директории задания
+ */
+func (m *Manager) checkIncludes(tasks *[]domains.Task, taskPath string) error {
+
+	if tasks == nil {
+		return nil
+	}
+
+	for _, task := range *tasks {
+		if len(task.Include) > 0 && !strings.HasPrefix(task.Include, jsontask.LibraryPrefix) &&
+			(filepath.IsAbs(task.Include) ||
+				!controller.Within(filepath.Dir(taskPath), filepath.Join(filepath.Dir(taskPath), task.Include))) {
+			return errors.New(ports.ERROR_LOCAL_PATH_OUTSIDE + ": include '" + task.Include + "'")
+		}
+		for _, nested := range []*[]domains.Task{task.Tasks, task.Block, task.Rescue, task.Always} {
+			if nestedError := m.checkIncludes(nested, taskPath); nestedError != nil {
+				return nestedError
+			}
+		}
+	}
+
+	return nil
+}
+
+func (m *Manager) taskPath(id string) string {
+	return filepath.Join(m.directory, id, TaskFilename)
+}
+
+func copyJob(job *domains.Job) domains.Job {
+	result := *job
+	result.Hosts = append([]domains.HostResult{}, job.Hosts...)
+	return result
+}
+
+/*
+ * newID
+ *
+ * Уникальный идентификатор задания: время создания и случайный суффикс
+ */
+func newID() string {
+	suffix := make([]byte, 3)
+	rand.Read(suffix)
+	return time.Now().Format("20060102-150405") + "-" + hex.EncodeToString(suffix)
+}
@@ -0,0 +1,89 @@
+package jobs
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/andomize/network-automation-executor/internal/adapters/logger"
+	"github.com/andomize/network-automation-executor/internal/core/domains"
+)
+
+// Имя файла состояния задания в директории задания сервера
+const JobFilename = "job.json"
+
+/*
+ * Manager.load
+ *
+ * Прочитать состояние всех заданий из директории сервера
+ * Директории без корректного файла состояния пропускаются
+ */
+func (m *Manager) load() ([]*domains.Job, error) {
+
+	if mkdirError := os.MkdirAll(m.directory, os.ModePerm); mkdirError != nil {
+		return nil, mkdirError
+	}
+
+	entries, readError := ioutil.ReadDir(m.directory)
+	if readError != nil {
+		return nil, readError
+	}
+
+	var result = []*domains.Job{}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		jobPath := filepath.Join(m.directory, entry.Name(), JobFilename)
+		jobContent, jobReadError := ioutil.ReadFile(jobPath)
+		if jobReadError != nil {
+			logger.WARNING("JOBS_LOAD: Cannot read file: '" + jobPath + "', skipping...")
+			continue
+		}
+
+		var job domains.Job
+		if unmarshalError := json.Unmarshal(jobContent, &job); unmarshalError != nil || job.ID != entry.Name() {
+			logger.WARNING("JOBS_LOAD: Cannot unmarshall file: '" + jobPath + "', skipping...")
+			continue
+		}
+
+		result = append(result, &job)
+	}
+
+	return result, nil
+}
+
+/*
+ * Manager.save
+ *
+ * Сохранить состояние задания (вызывается при захваченном mutex)
+ * Файл записывается во временный файл и переименовывается, что бы при
+ * аварийном завершении не оставалось частично записанного состояния
+ */
+func (m *Manager) save(job *domains.Job) {
+
+	jobPath := filepath.Join(m.directory, job.ID, JobFilename)
+
+	jobJSON, marshalError := json.MarshalIndent(job, "", "    ")
+	if marshalError != nil {
+		logger.ERROR("JOBS_SAVE: Cannot marshal job '" + job.ID + "'")
+		return
+	}
+
+	if mkdirError := os.MkdirAll(filepath.Dir(jobPath), os.ModePerm); mkdirError != nil {
+		logger.ERROR("JOBS_SAVE: Cannot create directory for job '" + job.ID + "'")
+		return
+	}
+
+	if writeError := ioutil.WriteFile(jobPath+".tmp", jobJSON, 0644); writeError != nil {
+		logger.ERROR("JOBS_SAVE: Cannot write file: '" + jobPath + "'")
+		return
+	}
+
+	if renameError := os.Rename(jobPath+".tmp", jobPath); renameError != nil {
+		logger.ERROR("JOBS_SAVE: Cannot write file: '" + jobPath + "'")
+	}
+}
@@ -0,0 +1,533 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/andomize/network-automation-executor/internal/core/domains"
+	"github.com/andomize/network-automation-executor/internal/core/ports"
+	"github.com/andomize/network-automation-executor/internal/core/services/controller"
+)
+
+type dryRun struct {
+	ctrl *controller.Controller
+	out  io.Writer
+
+	// Директория с примерами выводов команд для подзаданий filter
+	samples string
+}
+
+/*
+ * DryRun
+ *
+ * Показать задание без подключения к хосту: каждое задание компилируется
+ * так же, как при выполнении, и выводятся команды, файлы выводов и условия
+ *  - Условия по имени задания зависят от результата выполнения и отмечаются
+ *    как runtime, условия по переменным вычисляются
+ *  - Для заданий с filter вывод команды берётся из директории samples
+ *    (файл с именем outputFile задания или <name>.txt), без примера
+ *    подзадания показываются один раз с группами в виде "<группа>"
+ *
+ * Возвращает ошибку компиляции задания (например, неизвестная переменная)
+ */
+func DryRun(task domains.TaskPattern, taskPath string, variables map[string]string, samples string, out io.Writer) error {
+
+	if task.Tasks == nil || len(*task.Tasks) <= 0 {
+		return errors.New(ports.ERROR_SYNTAX_NO_TASKS)
+	}
+
+	d := &dryRun{
+		ctrl:    controller.NewDryRunController(task, taskPath, variables, nil),
+		out:     out,
+		samples: samples,
+	}
+
+	fmt.Fprintf(out, "DRY-RUN: host '%s'\n", task.Host)
+	return d.run(task.Tasks, d.ctrl.Variables, 0, "")
+}
+
+func (d *dryRun) run(tasks *[]domains.Task, variables controller.Artefacts, depthLevel int, number string) error {
+
+	for taskIdx, task := range *tasks {
+
+		taskNumber := fmt.Sprintf("%s%d", number, taskIdx+1)
+
+		if task.Loop != nil {
+			if loopError := d.loop(task, variables, depthLevel, taskNumber); loopError != nil {
+				return loopError
+			}
+			continue
+		}
+
+		if taskError := d.task(task, variables, depthLevel, taskNumber); taskError != nil {
+			return taskError
+		}
+	}
+
+	return nil
+}
+
+/*
+ * dryRun.loop
+ *
+ * Показать повторения задания с loop, каждое - с номером "N[i]"
+ */
+func (d *dryRun) loop(task domains.Task, variables controller.Artefacts, depthLevel int, taskNumber string) error {
+
+	indent := strings.Repeat("    ", depthLevel+1)
+
+	iterations, loopError := d.ctrl.LoopItems(*task.Loop, variables)
+	if loopError != nil {
+		fmt.Fprintf(d.out, "%s%s. ERROR: loop: %v\n", indent, taskNumber, loopError)
+		return loopError
+	}
+
+	fmt.Fprintf(d.out, "%s%s. loop: %d element(s)\n", indent, taskNumber, len(iterations))
+
+	for iterationIdx, iteration := range iterations {
+		loopArtefacts := copyArtefacts(variables)
+		for name, value := range iteration {
+			loopArtefacts[name] = value
+		}
+
+		iterationTask := task
+		iterationTask.Loop = nil
+		if taskError := d.task(iterationTask, loopArtefacts, depthLevel,
+			fmt.Sprintf("%s[%d]", taskNumber, iterationIdx+1)); taskError != nil {
+			return taskError
+		}
+	}
+
+	return nil
+}
+
+/*
+ * dryRun.task
+ *
+ * Показать задание и его подзадания
+ */
+func (d *dryRun) task(task domains.Task, variables controller.Artefacts, depthLevel int, taskNumber string) error {
+
+	indent := strings.Repeat("    ", depthLevel+1)
+
+	if compileError := d.ctrl.Compile(&task, variables); compileError != nil {
+		fmt.Fprintf(d.out, "%s%s. ERROR: %v\n", indent, taskNumber, compileError)
+		return compileError
+	}
+
+	line := fmt.Sprintf("%s%s. %s", indent, taskNumber, describeCommand(task))
+	if len(task.Name) > 0 {
+		line += fmt.Sprintf(" [name: %s]", task.Name)
+	}
+	if len(task.Params.OutputFile) > 0 {
+		line += " -> " + task.Params.OutputFile
+	}
+	fmt.Fprintln(d.out, line)
+
+	// Задание уже выполнялось и будет пропущено
+	if len(task.Status) > 0 && !task.Params.CommandRepeatAllowed && depthLevel == 0 {
+		fmt.Fprintf(d.out, "%s    skipped: already executed with status '%s'\n", indent, task.Status)
+		return nil
+	}
+
+	if task.When != nil && len(*task.When) > 0 {
+		fmt.Fprintf(d.out, "%s    when: %s\n", indent, describeWhen(*task.When, variables))
+	}
+
+	if task.Block != nil {
+		return d.block(task, variables, depthLevel, taskNumber)
+	}
+
+	if len(task.Params.Until) > 0 {
+		retries, delay := task.Params.Retries, task.Params.Delay
+		if retries <= 0 {
+			retries = ports.UNTIL_RETRIES
+		}
+		if delay <= 0 {
+			delay = ports.UNTIL_DELAY
+		}
+		fmt.Fprintf(d.out, "%s    until: '%s' (retries %d, delay %ds)\n", indent, task.Params.Until, retries, delay)
+	}
+
+	if len(task.Params.Register) > 0 {
+		if registerError := d.register(task, variables, indent); registerError != nil {
+			return registerError
+		}
+	}
+
+	if len(task.Params.Filter) <= 0 {
+		return nil
+	}
+
+	if task.Tasks == nil {
+		fmt.Fprintf(d.out, "%s    filter: '%s' (no subtasks)\n", indent, task.Params.Filter)
+		return nil
+	}
+
+	// RegExpMatch не проверяет регулярные выражения
+	filter, filterError := regexp.Compile(task.Params.Filter)
+	if filterError == nil && len(task.Params.FilterExclude) > 0 {
+		_, filterError = regexp.Compile(task.Params.FilterExclude)
+	}
+	if filterError != nil {
+		fmt.Fprintf(d.out, "%s    filter: ERROR: %v\n", indent, filterError)
+		return filterError
+	}
+
+	output, samplePath := d.sample(task)
+	if len(samplePath) <= 0 {
+		// Вывод команды неизвестен: подзадания с группами в виде "<группа>"
+		fmt.Fprintf(d.out, "%s    filter: '%s' (no sample output, groups are shown as <name>)\n",
+			indent, task.Params.Filter)
+
+		subTaskArtefacts := copyArtefacts(variables)
+		for _, name := range filterGroups(filter) {
+			subTaskArtefacts[name] = "<" + name + ">"
+		}
+		return d.run(task.Tasks, subTaskArtefacts, depthLevel+1, taskNumber+".")
+	}
+
+	regMap, regCount, regError := d.ctrl.RegExpMatch(output, task.Params.Filter, task.Params.FilterExclude)
+	if regError != nil {
+		fmt.Fprintf(d.out, "%s    filter: ERROR: %v\n", indent, regError)
+		return regError
+	}
+
+	fmt.Fprintf(d.out, "%s    filter: '%s', sample '%s' returns %d value(s)\n",
+		indent, task.Params.Filter, samplePath, regCount)
+
+	for subTaskOrder := 0; subTaskOrder < regCount; subTaskOrder++ {
+		subTaskArtefacts := copyArtefacts(variables)
+
+		var values []string
+		for _, name := range sortedGroups(regMap) {
+			subTaskArtefacts[name] = regMap[name][subTaskOrder]
+			values = append(values, name+"="+regMap[name][subTaskOrder])
+		}
+
+		fmt.Fprintf(d.out, "%s    values: %s\n", indent, strings.Join(values, ", "))
+		if runError := d.run(task.Tasks, subTaskArtefacts, depthLevel+1,
+			fmt.Sprintf("%s.%d.", taskNumber, subTaskOrder+1)); runError != nil {
+			return runError
+		}
+	}
+
+	return nil
+}
+
+/*
+ * dryRun.block
+ *
+ * Показать задания block, rescue (номера "N.r<i>") и always ("N.a<i>"),
+ * ошибка в rescue и always показывается как "<block_error>"
+ */
+func (d *dryRun) block(task domains.Task, variables controller.Artefacts, depthLevel int, taskNumber string) error {
+
+	indent := strings.Repeat("    ", depthLevel+1)
+
+	blockArtefacts := controller.Artefacts{}
+	for index, value := range variables {
+		blockArtefacts[index] = value
+	}
+
+	if runError := d.run(task.Block, blockArtefacts, depthLevel+1, taskNumber+"."); runError != nil {
+		return runError
+	}
+
+	blockArtefacts[controller.BlockErrorVariable] = "<" + controller.BlockErrorVariable + ">"
+
+	for _, handlers := range []struct {
+		name   string
+		prefix string
+		tasks  *[]domains.Task
+	}{{"rescue", ".r", task.Rescue}, {"always", ".a", task.Always}} {
+		if handlers.tasks == nil {
+			continue
+		}
+		fmt.Fprintf(d.out, "%s    %s:\n", indent, handlers.name)
+		if runError := d.run(handlers.tasks, blockArtefacts, depthLevel+1, taskNumber+handlers.prefix); runError != nil {
+			return runError
+		}
+	}
+
+	return nil
+}
+
+/*
+ * dryRun.register
+ *
+ * Сохранить значения register из примера вывода в переменные,
+ * без примера переменные принимают значения вида "<группа>"
+ */
+func (d *dryRun) register(task domains.Task, variables controller.Artefacts, indent string) error {
+
+	expression, compileError := regexp.Compile(task.Params.Register)
+	if compileError != nil {
+		fmt.Fprintf(d.out, "%s    register: ERROR: %v\n", indent, compileError)
+		return compileError
+	}
+
+	output, samplePath := d.sample(task)
+	if len(samplePath) <= 0 {
+		fmt.Fprintf(d.out, "%s    register: '%s' (no sample output, values are shown as <name>)\n",
+			indent, task.Params.Register)
+		for _, name := range filterGroups(expression) {
+			variables[name] = "<" + name + ">"
+		}
+		return nil
+	}
+
+	captured, captureError := controller.Capture(output, task.Params)
+	if captureError != nil {
+		fmt.Fprintf(d.out, "%s    register: ERROR: %v\n", indent, captureError)
+		return captureError
+	}
+
+	var values []string
+	for _, name := range filterGroups(expression) {
+		value, exist := captured[name]
+		if !exist {
+			continue
+		}
+		variables[name] = value
+		values = append(values, name+"="+value)
+	}
+	if len(values) <= 0 {
+		fmt.Fprintf(d.out, "%s    register: sample '%s' returns no values\n", indent, samplePath)
+		return nil
+	}
+	fmt.Fprintf(d.out, "%s    register: sample '%s' sets %s\n", indent, samplePath, strings.Join(values, ", "))
+
+	return nil
+}
+
+/*
+ * dryRun.sample
+ *
+ * Прочитать пример вывода команды задания из директории примеров
+ */
+func (d *dryRun) sample(task domains.Task) (string, string) {
+
+	if len(d.samples) <= 0 {
+		return "", ""
+	}
+
+	var candidates []string
+	if len(task.Params.OutputFile) > 0 {
+		candidates = append(candidates, task.Params.OutputFile)
+	}
+	if len(task.Name) > 0 {
+		candidates = append(candidates, task.Name+".txt")
+	}
+
+	for _, candidate := range candidates {
+		samplePath := filepath.Join(d.samples, candidate)
+		if content, readError := ioutil.ReadFile(samplePath); readError == nil {
+			return string(content), samplePath
+		}
+	}
+
+	return "", ""
+}
+
+func describeCommand(task domains.Task) string {
+	switch {
+	case task.Block != nil:
+		return "block"
+	case len(task.Params.Transfer) > 0:
+		return fmt.Sprintf("transfer %s remote '%s' local '%s'",
+			task.Params.Transfer, task.Params.RemoteFile, task.Params.LocalFile)
+	case task.Params.FireAndForget && task.Params.WaitReturn > 0:
+		return fmt.Sprintf("%s (session closes, wait return %vs)", task.Command, task.Params.WaitReturn)
+	case task.Params.FireAndForget:
+		return fmt.Sprintf("%s (session closes)", task.Command)
+	}
+	return task.Command
+}
+
+// Результат условия в режиме dry-run
+const (
+	whenFalse = iota
+	whenTrue
+	whenRuntime
+)
+
+/*
+ * describeWhen
+ *
+ * Описание условий задания: как и при выполнении, элементы массива
+ * объединяются по "и", условия по переменным вычисляются, условия по имени
+ * задания зависят от результата выполнения
+ */
+func describeWhen(when []domains.When, variables controller.Artefacts) string {
+
+	result, description := describeWhenGroup("all", when, variables)
+	if len(when) == 1 {
+		result, description = describeWhenNode(when[0], variables)
+	}
+
+	switch result {
+	case whenFalse:
+		return description + " - false, task will be skipped"
+	case whenRuntime:
+		description += " - depends on runtime result"
+	default:
+		description += " - true"
+	}
+
+	for _, condition := range when {
+		if len(condition.OnMove) > 0 {
+			description += ", onMove -> '" + condition.OnMove + "'"
+		}
+		if condition.OnExit {
+			description += ", onExit"
+		}
+	}
+
+	return description
+}
+
+func describeWhenGroup(kind string, when []domains.When, variables controller.Artefacts) (int, string) {
+
+	var parts []string
+	var hasFalse, hasTrue, hasRuntime bool
+
+	for _, condition := range when {
+		result, description := describeWhenNode(condition, variables)
+		parts = append(parts, description)
+		hasFalse = hasFalse || result == whenFalse
+		hasTrue = hasTrue || result == whenTrue
+		hasRuntime = hasRuntime || result == whenRuntime
+	}
+
+	description := kind + "(" + strings.Join(parts, ", ") + ")"
+	switch {
+	case kind == "all" && hasFalse, kind == "any" && !hasTrue && !hasRuntime:
+		return whenFalse, description
+	case kind == "any" && hasTrue, kind == "all" && !hasRuntime:
+		return whenTrue, description
+	}
+	return whenRuntime, description
+}
+
+func describeWhenNode(when domains.When, variables controller.Artefacts) (int, string) {
+
+	var parts []string
+	var results []int
+
+	if len(when.Name) > 0 {
+		description := "task '" + when.Name + "'"
+		for _, check := range [][2]string{{"ifStatus", when.IfStatus},
+			{"ifOutputContains", when.IfOutputContains}, {"ifOutputNotContains", when.IfOutputNotContains},
+			{"ifOutputContainsRe", when.IfOutputContainsRe}, {"ifOutputNotContainsRe", when.IfOutputNotContainsRe},
+			{"extract", when.Extract}, {"ifVersionGt", when.IfVersionGt}, {"ifVersionGte", when.IfVersionGte},
+			{"ifVersionLt", when.IfVersionLt}, {"ifVersionLte", when.IfVersionLte},
+			{"ifNumberEq", when.IfNumberEq}, {"ifNumberGt", when.IfNumberGt}, {"ifNumberGte", when.IfNumberGte},
+			{"ifNumberLt", when.IfNumberLt}, {"ifNumberLte", when.IfNumberLte},
+			{"ifNumberBetween", when.IfNumberBetween}} {
+			if len(check[1]) > 0 {
+				description += " " + check[0] + " '" + check[1] + "'"
+			}
+		}
+		parts = append(parts, description)
+		results = append(results, whenRuntime)
+	}
+
+	if len(when.Variable) > 0 {
+		result, description := controller.MatchVariable(when, variables)
+		parts = append(parts, description)
+		if isPlaceholder(variables[when.Variable]) {
+			// Значение группы без примера вывода известно только при выполнении
+			results = append(results, whenRuntime)
+		} else if result {
+			results = append(results, whenTrue)
+		} else {
+			results = append(results, whenFalse)
+		}
+	}
+
+	if when.All != nil {
+		result, description := describeWhenGroup("all", *when.All, variables)
+		parts = append(parts, description)
+		results = append(results, result)
+	}
+
+	if when.Any != nil {
+		result, description := describeWhenGroup("any", *when.Any, variables)
+		parts = append(parts, description)
+		results = append(results, result)
+	}
+
+	if when.Not != nil {
+		result, description := describeWhenNode(*when.Not, variables)
+		parts = append(parts, "not("+description+")")
+		switch result {
+		case whenTrue:
+			results = append(results, whenFalse)
+		case whenFalse:
+			results = append(results, whenTrue)
+		default:
+			results = append(results, whenRuntime)
+		}
+	}
+
+	if len(parts) <= 0 {
+		return whenTrue, "no checks"
+	}
+
+	// Части элемента объединяются по "и"
+	combined := whenTrue
+	for _, result := range results {
+		if result == whenFalse {
+			combined = whenFalse
+			break
+		}
+		if result == whenRuntime {
+			combined = whenRuntime
+		}
+	}
+
+	return combined, strings.Join(parts, " and ")
+}
+
+// Значение вида "<группа>" вместо неизвестного без примера вывода значения
+func isPlaceholder(value string) bool {
+	return len(value) > 2 && strings.HasPrefix(value, "<") && strings.HasSuffix(value, ">")
+}
+
+func filterGroups(filter *regexp.Regexp) []string {
+	var names []string
+	for index, name := range filter.SubexpNames() {
+		if index <= 0 {
+			continue
+		}
+		if len(name) <= 0 {
+			name = fmt.Sprint(index)
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func sortedGroups(regMap map[string][]string) []string {
+	names := make([]string, 0, len(regMap))
+	for name := range regMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func copyArtefacts(variables controller.Artefacts) controller.Artefacts {
+	result := controller.Artefacts{}
+	for index, value := range variables {
+		result[index] = value
+	}
+	return result
+}
@@ -0,0 +1,296 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/andomize/network-automation-executor/internal/adapters/eventstream"
+	"github.com/andomize/network-automation-executor/internal/adapters/filestorage"
+	"github.com/andomize/network-automation-executor/internal/adapters/jsontask"
+	"github.com/andomize/network-automation-executor/internal/adapters/logger"
+	"github.com/andomize/network-automation-executor/internal/adapters/metrics"
+	"github.com/andomize/network-automation-executor/internal/adapters/transferserver"
+	"github.com/andomize/network-automation-executor/internal/adapters/webhook"
+	"github.com/andomize/network-automation-executor/internal/core/domains"
+	"github.com/andomize/network-automation-executor/internal/core/ports"
+	"github.com/andomize/network-automation-executor/internal/core/services/controller"
+	"github.com/andomize/network-automation-executor/internal/core/services/spawner"
+)
+
+type Options struct {
+	// Учётные данные для подключения к хостам
+	Username string
+	Password string
+
+	// Встроенный сервер передачи файлов (может отсутствовать)
+	TransferServer  *transferserver.TransferServer
+	TransferAddress string
+
+	// Количество хостов инвентаря, обрабатываемых одновременно (0 - по одному)
+	Workers int
+
+	// Ограничения количества одновременно обрабатываемых хостов для групп
+	// инвентаря, например {"core": 1}
+	GroupLimits map[string]int
+
+	// Максимальное время выполнения задания на одном хосте в секундах,
+	// включая подключение к нему (0 - без ограничения)
+	HostTimeout int
+
+	// Уведомления о результатах выполнения на хостах (может отсутствовать)
+	Notifier *webhook.Notifier
+
+	// Поток событий выполнения (может отсутствовать)
+	Events *eventstream.Stream
+
+	// Директория, за пределы которой не могут выходить локальные файлы
+	// заданий: scp-put, loop.csv (пустая - без ограничения)
+	LocalRoot string
+}
+
+/*
+ * cancelledResult
+ *
+ * Результат хоста, выполнение на котором не начиналось из-за отмены
+ */
+func cancelledResult(target domains.Target) domains.HostResult {
+	return domains.HostResult{Name: target.Name, Host: target.Host,
+		Status: ports.PIPE_STATUS_SKIPPED, Error: ports.ERROR_CANCELLED}
+}
+
+/*
+ * Execute
+ *
+ * Выполнить все задания контроллера и автотесты, записать результат
+ * Возвращает ошибку выполнения (код ошибки записан в результат задания)
+ */
+func Execute(ctrl *controller.Controller) error {
+
+	// Запускаем поочерёдное выполнение заданий
+	runError := Run(ctrl, ctrl.Task.Tasks, ctrl.Variables, 0)
+
+	// Ошибка прерывания выполнения имеет приоритет над ошибкой отправки команды,
+	// вызванной закрытием соединения
+	if abortCode := ctrl.Aborted(); len(abortCode) > 0 {
+		return ctrl.ExitError(abortCode)
+	}
+	if runError != nil {
+		return ctrl.ExitError(runError.Error())
+	}
+
+	// Если выполнение не было завершено досрочно и необходимо тестирование
+	// задания, то выполняем тестирование
+	if !ctrl.Stopped {
+		if autotestsError := ctrl.Autotests(); autotestsError != nil {
+			return ctrl.ExitError(autotestsError.Error())
+		}
+	}
+
+	if abortCode := ctrl.Aborted(); len(abortCode) > 0 {
+		return ctrl.ExitError(abortCode)
+	}
+
+	return ctrl.ExitSuccess()
+}
+
+/*
+ * ExecuteTarget
+ *
+ * Выполнить задание на одном хосте инвентаря
+ * Выводы и результат сохраняются в поддиректорию хоста <output>/<name>/
+ * Если результат предыдущего запуска существует, то он используется вместо
+ * исходного файла задания (защита от повторного выполнения команд)
+ * Журнал хоста дополнительно сохраняется в <output>/<name>/execution.log
+ * По завершении отправляются уведомления (options.Notifier)
+ */
+func ExecuteTarget(ctx context.Context, taskPath, outputDirectory string, target domains.Target, options Options) domains.HostResult {
+
+	hostDirectory, resultPath := TargetPaths(taskPath, outputDirectory, target)
+
+	taskSource := taskPath
+	if _, statError := os.Stat(resultPath); statError == nil {
+		logger.INFO("RUN: Host '" + target.Name + "' has result of previous run, using it")
+		taskSource = resultPath
+	}
+
+	task, taskReadError := jsontask.Read(taskSource)
+	if taskReadError != nil {
+		result := domains.HostResult{Name: target.Name, Host: target.Host,
+			Status: ports.PIPE_STATUS_FAIL, Error: taskReadError.Error()}
+		options.Notifier.Notify(domains.TaskPattern{}, result)
+		return result
+	}
+
+	task.Host = target.Host
+
+	// Журнал хоста: сообщения с префиксом имени хоста и отдельный файл
+	var logFile *os.File
+	if mkdirError := os.MkdirAll(hostDirectory, os.ModePerm); mkdirError == nil {
+		logFile, _ = os.OpenFile(filepath.Join(hostDirectory, "execution.log"),
+			os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	}
+	if logFile != nil {
+		defer logFile.Close()
+	} else {
+		logger.WARNING("RUN: Cannot create log file for host '" + target.Name + "'")
+	}
+
+	var hostLog *logger.Logger
+	if logFile != nil {
+		hostLog = logger.New(target.Name, logFile)
+	} else {
+		hostLog = logger.New(target.Name, nil)
+	}
+
+	resultTask, result := ExecuteTask(ctx, *task, taskPath, resultPath, hostDirectory, target.Variables, hostLog, options)
+	result.Name = target.Name
+	options.Notifier.Notify(resultTask, result)
+	return result
+}
+
+/*
+ * TargetPaths
+ *
+ * Директория выводов хоста инвентаря <output>/<name>/ и путь к результату
+ * выполнения задания на хосте <output>/<name>/<файл задания>
+ */
+func TargetPaths(taskPath, outputDirectory string, target domains.Target) (string, string) {
+	hostDirectory := filepath.Join(outputDirectory,
+		filestorage.NewFileStorage(outputDirectory).NameNormalization(target.Name))
+	return hostDirectory, filepath.Join(hostDirectory, filepath.Base(taskPath))
+}
+
+/*
+ * ExecuteTask
+ *
+ * Создать контроллер, выполнить задание и сформировать результат для хоста
+ * Возвращает задание со статусами выполнения и результат хоста
+ *  resultPath - путь для сохранения результата (пустой - результат не сохраняется)
+ *  log        - журнал хоста (nil - общий журнал программы)
+ *
+ * Отмена контекста прерывает выполнение (соединение закрывается), задание
+ * завершается с ошибкой "execution-cancelled" или, при истечении срока
+ * контекста, "spawner-host-execution-timeout"
+ */
+func ExecuteTask(
+	ctx context.Context,
+	task domains.TaskPattern,
+	taskPath, resultPath, outputDirectory string,
+	variables map[string]string,
+	log *logger.Logger,
+	options Options,
+) (domains.TaskPattern, domains.HostResult) {
+
+	startTime := time.Now()
+
+	// Выполнение отменено до подключения к хосту
+	if ctx.Err() != nil {
+		task.Status, task.Error = ports.PIPE_STATUS_FAIL, spawner.ContextErrorCode(ctx)
+		return task, domains.HostResult{Host: task.Host, Status: task.Status, Error: task.Error}
+	}
+
+	// Ограничиваем время выполнения задания на хосте, включая подключение
+	hostCtx, cancel := ctx, context.CancelFunc(func() {})
+	if options.HostTimeout > 0 {
+		hostCtx, cancel = context.WithTimeout(ctx, time.Duration(options.HostTimeout)*time.Second)
+	}
+	defer cancel()
+
+	ctrl, ctrlError := controller.NewTaskController(hostCtx, task, taskPath, resultPath,
+		outputDirectory, variables, log, options.Username, options.Password)
+	ctrl.Events = options.Events
+	ctrl.LocalRoot = options.LocalRoot
+
+	// Ошибки синтаксиса задания обнаруживаются до подключения
+	if ctrlError == nil || !strings.HasPrefix(ctrlError.Error(), "syntax-") {
+		connectEvent := domains.Event{Event: ports.STREAM_EVENT_CONNECT, Status: sendStatus(ctrlError),
+			DurationMs: eventstream.Milliseconds(time.Since(startTime))}
+		if ctrlError != nil {
+			connectEvent.Error = ctrlError.Error()
+		} else {
+			connectEvent.Vendor, connectEvent.Prompt = ctrl.Connection.Prompt.Vendor, ctrl.Connection.Prompt.Name
+		}
+		ctrl.Emit(connectEvent)
+	}
+
+	if ctrlError == nil {
+		log.INFO("Connection to host '" + ctrl.Task.Host + "' successful")
+
+		// Прерываем выполнение при отмене контекста
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			select {
+			case <-hostCtx.Done():
+				ctrl.Abort(spawner.ContextErrorCode(hostCtx))
+			case <-done:
+			}
+		}()
+
+		// Добавляем переменные сервера передачи файлов
+		if options.TransferServer != nil {
+			if useError := ctrl.UseTransferServer(options.TransferServer, options.TransferAddress); useError != nil {
+				log.ERROR("Cannot define transfer server address by reason: " + useError.Error())
+				ctrlError = ctrl.ExitError(useError.Error())
+			}
+		}
+	}
+
+	if ctrlError == nil {
+		Execute(ctrl)
+	}
+
+	metrics.HostStatus(ctrl.Task.Status)
+
+	ctrl.Emit(domains.Event{Event: ports.STREAM_EVENT_EXIT, Status: ctrl.Task.Status, Error: ctrl.Task.Error,
+		Vendor: ctrl.Task.Vendor, DurationMs: eventstream.Milliseconds(time.Since(startTime))})
+
+	return ctrl.Task, domains.HostResult{
+		Host:       ctrl.Task.Host,
+		Status:     ctrl.Task.Status,
+		Error:      ctrl.Task.Error,
+		ResultPath: resultPath,
+		Duration:   time.Since(startTime).Round(time.Millisecond).String(),
+		Autotests:  ctrl.AutotestsStatus,
+		FailedTask: failedTask(ctrl.Task),
+	}
+}
+
+/*
+ * failedTask
+ *
+ * Имя (или команда) последней подзадачи, завершённой с ошибкой
+ * Пустая строка, если задание выполнено успешно
+ */
+func failedTask(task domains.TaskPattern) string {
+
+	if task.Status != ports.PIPE_STATUS_FAIL {
+		return ""
+	}
+
+	var failed string
+	var walk func(tasks *[]domains.Task)
+	walk = func(tasks *[]domains.Task) {
+		if tasks == nil {
+			return
+		}
+		for _, t := range *tasks {
+			if t.Status == ports.PIPE_STATUS_FAIL && (len(t.Name) > 0 || len(t.Command) > 0) {
+				failed = t.Name
+				if len(failed) <= 0 {
+					failed = t.Command
+				}
+			}
+			walk(t.Tasks)
+			walk(t.Block)
+			walk(t.Rescue)
+			walk(t.Always)
+		}
+	}
+	walk(task.Tasks)
+
+	return failed
+}
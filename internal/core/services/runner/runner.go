@@ -0,0 +1,565 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/andomize/network-automation-executor/internal/adapters/eventstream"
+	"github.com/andomize/network-automation-executor/internal/core/domains"
+	"github.com/andomize/network-automation-executor/internal/core/ports"
+	"github.com/andomize/network-automation-executor/internal/core/services/controller"
+)
+
+/*
+ * Run
+ *
+ * Передаём на исполнение все задания текущего уровня
+ * Задания будут передататься рекурсивно до тех пор, пока не будет достугнут
+ * конечный уровень вложенности
+ */
+func Run(
+	ctrl *controller.Controller,
+	tasks *[]domains.Task,
+	variables controller.Artefacts,
+	depthLevel int,
+) error {
+
+	ctrl.Log.DEBUG(fmt.Sprintf("RUN: Starting tasks execution, depth: %v, taskCount: %v",
+		depthLevel, len(*tasks)))
+
+	// Выполняем поочерёдно все задания текущего уровня
+	// Текущим уровнем может быть и корневой уровень в том числе, если подзаданий не существует
+	// у любого из заданий корневого уровня, то по истечению данного цикла (выполнению всех
+	// подзадач текущего (корневого) уровня), будет осуществлён выход из программы
+	for taskIdx, task := range *tasks {
+
+		// Выполнение прервано извне (например, истекло время выполнения на хосте)
+		if abortCode := ctrl.Aborted(); len(abortCode) > 0 {
+			return errors.New(abortCode)
+		}
+
+		ctrl.Log.DEBUG(fmt.Sprintf("RUN: Processing task '%v' with command '%v'", taskIdx, task.Command))
+
+		// Задание с циклом выполняется для каждого элемента списка
+		if task.Loop != nil {
+			ctrl.Emit(domains.Event{Event: ports.STREAM_EVENT_TASK_START, Task: task.Name,
+				Command: task.Command, Depth: depthLevel})
+			loopTime := time.Now()
+			loopError := runLoop(ctrl, tasks, taskIdx, variables, depthLevel)
+			emitTaskEnd(ctrl, task, depthLevel, (*tasks)[taskIdx].Status, loopError, time.Since(loopTime))
+			if loopError != nil {
+				return loopError
+			}
+			if ctrl.Stopped {
+				return nil
+			}
+			continue
+		}
+
+		// Компилируем задание
+		//  - Вместо имён всех переменных подставляются их значения
+		//  - Вычисляется Timeout для ожидания ответа на основе данных в задании
+		if compileError := ctrl.Compile(&task, variables); compileError != nil {
+			return compileError
+		}
+
+		// Проверяем установлено ли имя следующего задания
+		if len(ctrl.NextTaskName) > 0 {
+			// Если установлено имя следующего задания, то все задания, не соответствующие
+			// искомому - будут пропускаться
+			if task.Name != ctrl.NextTaskName {
+				ctrl.Log.WARNING("RUN: Command: '" + task.Command + "' was skipped by GOTO action")
+				ctrl.SetTaskStatus(&(*tasks)[taskIdx], ports.PIPE_STATUS_SKIPPED)
+				emitTaskEnd(ctrl, task, depthLevel, ports.PIPE_STATUS_SKIPPED, nil, 0)
+				continue
+			}
+			// Обнуляем имя следующего задания, т.к. оно было достигнуто
+			// далее задания будут выполняться в нормальном порядке
+			ctrl.NextTaskName = ""
+		}
+
+		// Проверяем что задание не выполнялось ранее
+		// Если у задания в поле "status" уже есть результат выполнения, то это означает,
+		// что данный файл задания уже запускался ранее и необходимо защитить удалённых хост
+		// от повторного выполнения команд, если это не разрешено в явном виде
+		if len(task.Status) > 0 {
+			// Данное задание уже выполнялось
+			// Проверим разрешено ли повторное исполнение выполнение текущего задания
+			// Если задание - это подзадание n-го уровня, то ошибки быть не должно т.к.
+			// для подзадач свойственнен множественный запуск
+			if !task.Params.CommandRepeatAllowed && depthLevel == 0 {
+				// Разрешение на повторное выполнение отсутствует и это корневое задание
+				// Устанавливаем новый статус задания - SKIPPED
+				// Выполняем переход к следующему заданию
+				ctrl.Log.WARNING("RUN: Command: '" + task.Command + "' has already been executed")
+				ctrl.SetTaskStatus(&(*tasks)[taskIdx], ports.PIPE_STATUS_SKIPPED)
+				emitTaskEnd(ctrl, task, depthLevel, ports.PIPE_STATUS_SKIPPED, nil, 0)
+				continue
+			} else {
+				// Разрешение на повторное выполнение присутствует
+				//ctrl.Log.INFO("RUN: Command: '" + task.Command + "' has already been executed," +
+				//	" but CommandRepeatAllowed is true, continue...")
+			}
+		}
+
+		// Проверяем еть ли в задании условия и выполняются ли они
+		conditionsSuccess, conditionsError := ctrl.WhenMatcher(task.When, variables)
+
+		if task.When != nil && len(*task.When) > 0 && conditionsError == nil {
+			ctrl.Emit(domains.Event{Event: ports.STREAM_EVENT_CONDITION, Task: task.Name,
+				Command: task.Command, Depth: depthLevel, Matched: &conditionsSuccess})
+		}
+
+		if conditionsError != nil {
+			// Если была выявлена ошибка на логическом/программном уровне - завершнние работы
+			ctrl.Log.ERROR("RUN: Command '" + task.Command + "' conditions is fail, error")
+			return conditionsError
+
+		} else {
+			// Если условное выражение из задания неуспешно, то пропускаем (Условие не выполнено)
+			if !conditionsSuccess {
+				ctrl.Log.INFO("RUN: Command '" + task.Command + "' conditions is fail, continue...")
+				ctrl.SetTaskStatus(&(*tasks)[taskIdx], ports.PIPE_STATUS_SKIPPED)
+				emitTaskEnd(ctrl, task, depthLevel, ports.PIPE_STATUS_SKIPPED, nil, 0)
+				continue
+			}
+		}
+
+		// Условие завершило выполнение заданий досрочно (действие onExit)
+		if ctrl.Stopped {
+			return nil
+		}
+
+		// Группа заданий с обработкой ошибок: вместо команды выполняются задания block
+		if task.Block != nil {
+			ctrl.Emit(domains.Event{Event: ports.STREAM_EVENT_TASK_START, Task: task.Name, Depth: depthLevel})
+			blockTime := time.Now()
+			blockError := runBlock(ctrl, tasks, taskIdx, task, variables, depthLevel)
+			emitTaskEnd(ctrl, task, depthLevel, (*tasks)[taskIdx].Status, blockError, time.Since(blockTime))
+			if blockError != nil {
+				return blockError
+			}
+			if ctrl.Stopped {
+				return nil
+			}
+			continue
+		}
+
+		// Выполняем отправку команды на удалённое устройство
+		ctrl.Emit(domains.Event{Event: ports.STREAM_EVENT_TASK_START, Task: task.Name,
+			Command: task.Command, Depth: depthLevel})
+		sendTime := time.Now()
+		output, commandSendError := ctrl.SendUntil(&task)
+
+		if commandSendError != nil {
+			// Команда была отправлена с ошибками
+			ctrl.Log.DEBUG("RUN: Send command: '" + task.Command + "' failed")
+			ctrl.SetTaskStatus(&(*tasks)[taskIdx], ports.PIPE_STATUS_FAIL)
+			emitTaskEnd(ctrl, task, depthLevel, ports.PIPE_STATUS_FAIL, commandSendError, time.Since(sendTime))
+
+			// Аргумент OnErrorContinue разрешает продолжение выполнения команд даже если
+			// текущая команда была выполнена с ошибкой. Проверяем установлен ли данный флаг
+			if task.Params.OnErrorContinue {
+
+				// Команда не была выполнена - Продолжение разрешено
+				// Вывод, подзадания и register неудачной команды не обрабатываются
+				ctrl.Log.WARNING("RUN: Send command: '" + task.Command + "' failed," +
+					" but OnErrorContinue is true, continue...")
+				continue
+			}
+
+			// Команда не была выполнена - Продолжение недоступно - выход
+			ctrl.Log.ERROR(fmt.Sprintf("RUN: Send command: '%s' failed by reason: '%v'",
+				task.Command, commandSendError))
+			return commandSendError
+		}
+
+		// Команда была отправлена успешно
+		ctrl.Log.INFO("RUN: Send command: '" + task.Command + "' successful")
+
+		// Контрольная сумма переданного по SCP/SFTP файла
+		if len(task.Checksum) > 0 {
+			(*tasks)[taskIdx].Checksum = task.Checksum
+		}
+
+		// Итоговый статус задания определяется после обработки вывода: ошибка
+		// сохранения, register или filter - fail, filter без значений или без
+		// подзаданий - skipped. Событие task_end отправляется с итоговым статусом
+		taskStatus := ports.PIPE_STATUS_SUCCESS
+		var taskError error
+
+		// Флаг OutputFile (если он не пустой) говорит о том, что необходимо выполнить сохранение
+		// вывода от текущей команды в файл, имя которого указано в данной переменной
+		if len(task.Params.OutputFile) > 0 {
+			// Сохраняем в файл полученный вывод после выполнения команды
+			if taskError = ctrl.SaveOutput(output, task.Params.OutputFile); taskError != nil {
+				ctrl.Log.ERROR("Saving output file is fail by reason: " + taskError.Error())
+			} else {
+				ctrl.Log.INFO("RUN: Save output to file: '" + task.Params.OutputFile + "' successful")
+				ctrl.Emit(domains.Event{Event: ports.STREAM_EVENT_FILE_SAVED, Task: task.Name,
+					Command: task.Command, Depth: depthLevel, File: task.Params.OutputFile})
+			}
+		}
+
+		// Параметр Register сохраняет значения групп регулярного выражения из вывода
+		// в переменные для следующих заданий, условий и автотестов (без подзаданий)
+		if len(task.Params.Register) > 0 && taskError == nil {
+			if taskError = ctrl.Register(output, task.Params, variables); taskError != nil {
+				ctrl.Log.ERROR("RUN: Register is fail by reason: " + taskError.Error())
+			}
+		}
+
+		// Распарсим вывод на основе регулярного выражения
+		// task.Params.Filter - Регулярное выражение по которму будет получен срез
+		//     найденных в выводе значений для дальнейшей подстановки в задания
+		// task.Params.FilterExclude - Регулярное выражение по которому полученные
+		//     ранее значения будут исключены из среза
+		var regMap map[string][]string
+		var regCount int
+		if len(task.Params.Filter) > 0 && taskError == nil {
+			regMap, regCount, taskError = ctrl.RegExpMatch(
+				output, task.Params.Filter, task.Params.FilterExclude)
+
+			// Если не удалось распарсить вывод, используя заложенное регулярное выражение,
+			// то подзадания выполняться не будут, а для задания будет установлено ошибочное
+			// состояние
+			// Если количество результатов нулевое или подзаданий нет, то продолжать нет
+			// смысла - следующее задание
+			switch {
+			case taskError != nil:
+				ctrl.Log.ERROR("RUN: Regular expression is fail by reason: " + taskError.Error())
+			case regCount <= 0:
+				ctrl.Log.INFO("RUN: Regular expression returns zero values, skipping...")
+				taskStatus = ports.PIPE_STATUS_SKIPPED
+			case task.Tasks == nil:
+				ctrl.Log.WARNING("RUN: Task contains regular expression," +
+					" but not contains subtasks, skipping...")
+				taskStatus = ports.PIPE_STATUS_SKIPPED
+			}
+		}
+
+		if taskError != nil {
+			taskStatus = ports.PIPE_STATUS_FAIL
+		}
+		ctrl.SetTaskStatus(&(*tasks)[taskIdx], taskStatus)
+		emitTaskEnd(ctrl, task, depthLevel, taskStatus, taskError, time.Since(sendTime))
+
+		if taskError != nil {
+			return taskError
+		}
+
+		// Выполняем проверку на наличие параметра "Filter" в задании
+		// Если данный параметр существует, то необходимо сгенерировать подзадания
+		// с подстановкой в качестве заданных переменных (например, {{1}} или {{name}})
+		// найденных в результате парсинга вывода значений
+		if len(task.Params.Filter) > 0 && taskStatus == ports.PIPE_STATUS_SUCCESS {
+
+			// В зависимоти от количества найденных регулярным выражением значений из вывода,
+			// необходимо запустить подзадание соответтсвующее количество раз, подставляя в
+			// набор артефактов новые значения, которые были получены по регулярному выражению
+			//
+			// Изначальный формат:
+			//
+			//	|-------------------------------------------|
+			//	| Команда: show vdc detail                  |
+			//	|-------------------------------------------|
+			//	    |
+			//	    |    |----------------------------------|
+			//	    |--> | Команда: switchto vdc {{vdc}}    |
+			//	    |    |----------------------------------|
+			//	    |--> | Команда: show vrf detail         |
+			//	    |    |----------------------------------|
+			//	             |
+			//	             |    |-------------------------------------|
+			//	             |    | Команда: show ip route vrf {{vrf}}  |
+			//	             |    |-------------------------------------|
+			//	             |--> | Команда: show ip arp vrf {{vrf}}    |
+			//	                  |-------------------------------------|
+			//
+			// Сформированные задания:
+			//
+			//	 artefacts = {vdc: [L3-CORE, AGG]}
+			//	|-------------------------------------------|
+			//	| Команда: show vdc detail                  |
+			//	|-------------------------------------------|
+			//	    |
+			//	    |     artefacts = {vdc : L3-CORE, vrf: [mgmt]}
+			//	    |    |-----------------------------------|
+			//	    |--> | Команда: switchto vdc L3-CORE     |
+			//	    |    |-----------------------------------|
+			//	    |
+			//	    |     artefacts = {vdc : L3-CORE, vrf: [big-data, inside]}
+			//	    |    |-----------------------------------|
+			//	    |--> | Команда: show vrf detail          |
+			//	    |    |-----------------------------------|
+			//	    |        |
+			//	    |        |     artefacts = {vdc: L3-CORE, vrf: big-data}
+			//	    |        |    |------------------------------------------|
+			//	    |        |    | Команда: show ip route vrf big-data      |
+			//	    |        |    |------------------------------------------|
+			//	    |        |--> | Команда: show ip arp vrf big-data        |
+			//	    |        |    |------------------------------------------|
+			//	    |        |
+			//	    |        |     artefacts = {vdc: L3-CORE, vrf: inside}
+			//	    |        |    |------------------------------------------|
+			//	    |        |    | Команда: show ip route vrf inside        |
+			//	    |        |    |------------------------------------------|
+			//	    |        |--> | Команда: show ip arp vrf inside          |
+			//	    |             |------------------------------------------|
+			//	    |
+			//	    |     artefacts = {vdc : AGG, vrf: [mgmt]}
+			//	    |    |-----------------------------------|
+			//	    |--> | Команда: switchto vdc AGG         |
+			//	    |    |-----------------------------------|
+			//	    |
+			//	    |     artefacts = {vdc : AGG, vrf: [mgmt]}
+			//	    |    |-----------------------------------|
+			//	    |--> | Команда: show vrf detail          |
+			//	         |-----------------------------------|
+			//	             |
+			//	             |     artefacts = {vdc: AGG, vrf: mgmt}
+			//	             |    |------------------------------------------|
+			//	             |    | Команда: show ip route vrf mgmt          |
+			//	             |    |------------------------------------------|
+			//	             |--> | Команда: show ip arp vrf mgmt            |
+			//	                  |------------------------------------------|
+			//
+			ctrl.Log.DEBUG(fmt.Sprintf("RUN: Task has subtasks and regular expression"+
+				" return '%d' values in any groups, map: '%v', default artefacts is: '%v'",
+				regCount, regMap, variables))
+
+			// В зависимоти от числа полученных артефактов, подзадания нужно запустить
+			// в аналогичном объёме, подставляя в каждое подзадание новое значение
+			// артефакта из массива артефактов
+			for subTaskOrder := 0; subTaskOrder < regCount; subTaskOrder++ {
+
+				// Определим новую переменную, которая будет хранить в себе артефакты
+				// только для конкретного подзадания. Это используется для того, что бы
+				// данные из подзаданий не могли переноситься между смежными подзаданиями
+				// или даже в следующее родительское задание
+				var subTaskArtefacts = map[string]string{}
+				for index, value := range variables {
+					subTaskArtefacts[index] = value
+				}
+
+				// Добавляем новые артефакты для следующего задания
+				// Если ранее существовали артефакты с идентичными идентификаторами,
+				// то такие артефакты будут перезаписаны на новое значение, при чём
+				// во время возврата к предыдущему заданию будут доступны прежние значения
+				for index, values := range regMap {
+					subTaskArtefacts[index] = values[subTaskOrder]
+
+					ctrl.Log.DEBUG(fmt.Sprintf("RUN: Set new value for artefact id"+
+						" '%s' = '%s'", index, values[subTaskOrder]))
+				}
+
+				ctrl.Log.DEBUG("RUN: Starting new subtask using artefacts: '" +
+					fmt.Sprint(subTaskArtefacts))
+
+				// Рекурсивно апускаем выполнение следующего задания
+				if runError := Run(ctrl, (*tasks)[taskIdx].Tasks, subTaskArtefacts, depthLevel+1); runError != nil {
+					return runError
+				}
+
+				// Выполнение заданий завершено досрочно
+				if ctrl.Stopped {
+					return nil
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+/*
+ * runLoop
+ *
+ * Выполнить задание с loop для каждого элемента: каждое повторение получает
+ * свою копию артефактов с переменными элемента (как подзадания filter)
+ * Статус задания - fail, если хотя бы одно повторение завершилось ошибкой,
+ * success, если хотя бы одно выполнено, иначе skipped
+ */
+func runLoop(
+	ctrl *controller.Controller,
+	tasks *[]domains.Task,
+	taskIdx int,
+	variables controller.Artefacts,
+	depthLevel int,
+) error {
+
+	task := (*tasks)[taskIdx]
+
+	iterations, loopError := ctrl.LoopItems(*task.Loop, variables)
+	if loopError != nil {
+		ctrl.Log.ERROR("RUN: Loop of command '" + task.Command + "' is fail by reason: " + loopError.Error())
+		ctrl.SetTaskStatus(&(*tasks)[taskIdx], ports.PIPE_STATUS_FAIL)
+		return loopError
+	}
+
+	ctrl.Log.DEBUG(fmt.Sprintf("RUN: Loop of command '%s' contains %d element(s)", task.Command, len(iterations)))
+
+	var hasSuccess, hasFail bool
+	var runError error
+
+	for _, iteration := range iterations {
+
+		loopArtefacts := controller.Artefacts{}
+		for index, value := range variables {
+			loopArtefacts[index] = value
+		}
+		for index, value := range iteration {
+			loopArtefacts[index] = value
+		}
+
+		ctrl.Log.DEBUG("RUN: Starting loop iteration using variables: '" + fmt.Sprint(iteration) + "'")
+
+		// Повторение выполняется как отдельное задание без цикла, подзадания общие
+		iterationTask := task
+		iterationTask.Loop = nil
+		iterationTasks := []domains.Task{iterationTask}
+
+		runError = Run(ctrl, &iterationTasks, loopArtefacts, depthLevel)
+
+		hasSuccess = hasSuccess || iterationTasks[0].Status == ports.PIPE_STATUS_SUCCESS
+		hasFail = hasFail || iterationTasks[0].Status == ports.PIPE_STATUS_FAIL
+		if iterationTasks[0].Checksum != task.Checksum {
+			(*tasks)[taskIdx].Checksum = iterationTasks[0].Checksum
+		}
+
+		if runError != nil || ctrl.Stopped {
+			break
+		}
+	}
+
+	switch {
+	case hasFail:
+		ctrl.SetTaskStatus(&(*tasks)[taskIdx], ports.PIPE_STATUS_FAIL)
+	case hasSuccess:
+		ctrl.SetTaskStatus(&(*tasks)[taskIdx], ports.PIPE_STATUS_SUCCESS)
+	default:
+		if len(iterations) <= 0 {
+			ctrl.Log.INFO("RUN: Loop of command '" + task.Command + "' contains zero elements, skipping...")
+		}
+		ctrl.SetTaskStatus(&(*tasks)[taskIdx], ports.PIPE_STATUS_SKIPPED)
+	}
+
+	return runError
+}
+
+/*
+ * runBlock
+ *
+ * Выполнить группу заданий block:
+ *  - если задание из block завершилось ошибкой (без onErrorContinue), выполняются
+ *    задания rescue; успешный rescue отменяет ошибку, и выполнение продолжается
+ *  - задания always выполняются в любом случае, в том числе после onExit и
+ *    ошибки rescue
+ * Текст ошибки доступен заданиям rescue и always в переменной block_error
+ * (пустая, если ошибки нет)
+ * Значения register из block, rescue и always доступны следующим заданиям
+ * вызывающего уровня, block_error - только внутри группы
+ * Статус группы - fail, если группа завершилась ошибкой (ошибка block без
+ * успешного rescue или ошибка rescue, always)
+ */
+func runBlock(
+	ctrl *controller.Controller,
+	tasks *[]domains.Task,
+	taskIdx int,
+	task domains.Task,
+	variables controller.Artefacts,
+	depthLevel int,
+) error {
+
+	// block, rescue и always используют общую копию переменных: block_error
+	// не попадает в переменные вызывающего уровня, значения register
+	// переносятся в них по завершении группы
+	blockArtefacts := controller.Artefacts{}
+	for index, value := range variables {
+		blockArtefacts[index] = value
+	}
+	defer func() {
+		for index, value := range blockArtefacts {
+			if index != controller.BlockErrorVariable {
+				variables[index] = value
+			}
+		}
+	}()
+
+	blockError := Run(ctrl, task.Block, blockArtefacts, depthLevel)
+
+	// Выполнение прервано извне - соединение закрыто, rescue и always невозможны
+	if abortCode := ctrl.Aborted(); len(abortCode) > 0 {
+		ctrl.SetTaskStatus(&(*tasks)[taskIdx], ports.PIPE_STATUS_FAIL)
+		return errors.New(abortCode)
+	}
+
+	resultError := blockError
+	blockArtefacts[controller.BlockErrorVariable] = ""
+
+	if blockError != nil {
+		blockArtefacts[controller.BlockErrorVariable] = blockError.Error()
+		ctrl.Log.WARNING("RUN: Block failed by reason: '" + blockError.Error() + "'")
+
+		if task.Rescue != nil {
+			ctrl.Log.INFO("RUN: Starting rescue tasks")
+			if resultError = runHandlers(ctrl, task.Rescue, blockArtefacts, depthLevel); resultError == nil {
+				ctrl.Log.INFO("RUN: Block error was rescued, continue...")
+			}
+		}
+	}
+
+	if task.Always != nil {
+		ctrl.Log.INFO("RUN: Starting always tasks")
+		if alwaysError := runHandlers(ctrl, task.Always, blockArtefacts, depthLevel); alwaysError != nil {
+			resultError = alwaysError
+		}
+	}
+
+	// Статус группы соответствует итоговой ошибке: успешный rescue - success
+	ctrl.SetTaskStatus(&(*tasks)[taskIdx], sendStatus(resultError))
+	return resultError
+}
+
+/*
+ * runHandlers
+ *
+ * Выполнить задания rescue или always: переход onMove и выход onExit,
+ * установленные в block, не пропускают их и восстанавливаются после
+ */
+func runHandlers(ctrl *controller.Controller, tasks *[]domains.Task, variables controller.Artefacts, depthLevel int) error {
+
+	nextTaskName, stopped := ctrl.NextTaskName, ctrl.Stopped
+	ctrl.NextTaskName, ctrl.Stopped = "", false
+
+	runError := Run(ctrl, tasks, variables, depthLevel)
+
+	if len(ctrl.NextTaskName) <= 0 {
+		ctrl.NextTaskName = nextTaskName
+	}
+	ctrl.Stopped = ctrl.Stopped || stopped
+
+	return runError
+}
+
+/*
+ * emitTaskEnd
+ *
+ * Записать событие завершения задания в поток событий
+ */
+func emitTaskEnd(ctrl *controller.Controller, task domains.Task, depthLevel int, status string, err error, duration time.Duration) {
+	event := domains.Event{Event: ports.STREAM_EVENT_TASK_END, Task: task.Name, Command: task.Command,
+		Depth: depthLevel, Status: status, DurationMs: eventstream.Milliseconds(duration)}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	ctrl.Emit(event)
+}
+
+func sendStatus(err error) string {
+	if err != nil {
+		return ports.PIPE_STATUS_FAIL
+	}
+	return ports.PIPE_STATUS_SUCCESS
+}
@@ -0,0 +1,260 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andomize/network-automation-executor/internal/adapters/inventory"
+	"github.com/andomize/network-automation-executor/internal/adapters/logger"
+	"github.com/andomize/network-automation-executor/internal/core/domains"
+	"github.com/andomize/network-automation-executor/internal/core/ports"
+)
+
+/*
+ * pool
+ *
+ * Ограниченный пул исполнителей для хостов инвентаря
+ * Учитывает общее ограничение количества исполнителей и ограничения групп
+ */
+type pool struct {
+	mutex sync.Mutex
+	cond  *sync.Cond
+
+	// Максимальное количество одновременно обрабатываемых хостов
+	workers int
+
+	// Ограничения групп и текущее количество обрабатываемых хостов в группах
+	groupLimits map[string]int
+	groupActive map[string]int
+
+	// Текущее количество обрабатываемых хостов
+	active int
+}
+
+func newPool(workers int, groupLimits map[string]int) *pool {
+	if workers <= 0 {
+		workers = 1
+	}
+
+	p := &pool{
+		workers:     workers,
+		groupLimits: groupLimits,
+		groupActive: map[string]int{},
+	}
+	p.cond = sync.NewCond(&p.mutex)
+	return p
+}
+
+/*
+ * pool.available
+ *
+ * Проверить, может ли хост быть запущен без превышения ограничений
+ * Вызывается при захваченном mutex
+ */
+func (p *pool) available(target domains.Target) bool {
+	if p.active >= p.workers {
+		return false
+	}
+	for _, group := range target.Groups {
+		if limit, exist := p.groupLimits[group]; exist && limit > 0 && p.groupActive[group] >= limit {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+ * pool.acquire
+ *
+ * Дождаться свободного исполнителя и выбрать первый из ожидающих хостов,
+ * который может быть запущен. Хост, ограниченный группой, не блокирует
+ * запуск остальных хостов
+ */
+func (p *pool) acquire(targets []domains.Target, pending []int) int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for {
+		for index, targetIndex := range pending {
+			target := targets[targetIndex]
+			if p.available(target) {
+				p.active++
+				for _, group := range target.Groups {
+					p.groupActive[group]++
+				}
+				return index
+			}
+		}
+		p.cond.Wait()
+	}
+}
+
+/*
+ * pool.release
+ *
+ * Освободить исполнителя после завершения обработки хоста
+ */
+func (p *pool) release(target domains.Target) {
+	p.mutex.Lock()
+	p.active--
+	for _, group := range target.Groups {
+		p.groupActive[group]--
+	}
+	p.mutex.Unlock()
+	p.cond.Broadcast()
+}
+
+/*
+ * ParseGroupLimits
+ *
+ * Разобрать ограничения групп инвентаря в формате "group=limit,group=limit"
+ */
+func ParseGroupLimits(value string) (map[string]int, error) {
+
+	var limits = map[string]int{}
+
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) <= 0 {
+			continue
+		}
+
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 {
+			return nil, errors.New("Group limit '" + item + "' must be in format 'group=limit'")
+		}
+
+		limit, limitError := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if limitError != nil || limit <= 0 {
+			return nil, errors.New("Group limit '" + item + "' must be a positive number")
+		}
+
+		limits[strings.TrimSpace(parts[0])] = limit
+	}
+
+	return limits, nil
+}
+
+/*
+ * ExecuteInventory
+ *
+ * Выполнить задание на всех выбранных хостах инвентаря
+ * Количество одновременно обрабатываемых хостов ограничено options.Workers
+ * и options.GroupLimits. Ошибка на одном хосте не прерывает выполнение на
+ * остальных. Сводный результат сохраняется в <output>/summary.json
+ */
+func ExecuteInventory(ctx context.Context, taskPath, outputDirectory string, targets []domains.Target, options Options) []domains.HostResult {
+	return executeTargets(ctx, taskPath, outputDirectory, targets, options, 0, nil)
+}
+
+/*
+ * executeTargets
+ *
+ * Выполнить задание на группе хостов с использованием пула исполнителей
+ * Результаты хостов помечаются номером волны (0 - без разделения на волны),
+ * результаты предыдущих волн (previous) добавляются в сводный результат
+ * После отмены контекста новые хосты не запускаются и получают статус "skipped"
+ */
+func executeTargets(
+	ctx context.Context,
+	taskPath, outputDirectory string,
+	targets []domains.Target,
+	options Options,
+	wave int,
+	previous []domains.HostResult,
+) []domains.HostResult {
+
+	var results = make([]domains.HostResult, len(targets))
+	var finished = make([]bool, len(targets))
+	var resultsMutex sync.Mutex
+	var wait sync.WaitGroup
+
+	workers := newPool(options.Workers, options.GroupLimits)
+
+	// Индексы хостов, ожидающих запуска
+	var pending = make([]int, len(targets))
+	for index := range targets {
+		pending[index] = index
+	}
+
+	for len(pending) > 0 {
+		selected := workers.acquire(targets, pending)
+		targetIndex := pending[selected]
+		target := targets[targetIndex]
+
+		// Выполнение отменено - оставшиеся хосты не запускаются
+		if ctx.Err() != nil {
+			workers.release(target)
+			resultsMutex.Lock()
+			for _, index := range pending {
+				results[index] = cancelledResult(targets[index])
+				results[index].Wave = wave
+				finished[index] = true
+			}
+			resultsMutex.Unlock()
+			break
+		}
+
+		pending = append(pending[:selected], pending[selected+1:]...)
+
+		wait.Add(1)
+		go func(target domains.Target, targetIndex int) {
+			defer wait.Done()
+			defer workers.release(target)
+
+			logger.INFO("RUN: Starting host '" + target.Name + "' (" + target.Host + ")")
+
+			result := executeSafe(ctx, taskPath, outputDirectory, target, options)
+			result.Wave = wave
+
+			logger.INFO("RUN: Host '" + target.Name + "' finished with status '" + result.Status + "'")
+
+			resultsMutex.Lock()
+			defer resultsMutex.Unlock()
+
+			results[targetIndex] = result
+			finished[targetIndex] = true
+
+			// Сохраняем сводный результат после каждого хоста, что бы он был
+			// доступен даже при аварийном завершении программы
+			// Ошибка сохранения сводного результата не влияет на выполнение заданий
+			var report = append([]domains.HostResult{}, previous...)
+			for index := range results {
+				if finished[index] {
+					report = append(report, results[index])
+				}
+			}
+			inventory.WriteReport(filepath.Join(outputDirectory, "summary.json"), report)
+		}(target, targetIndex)
+	}
+
+	wait.Wait()
+
+	return results
+}
+
+/*
+ * executeSafe
+ *
+ * Выполнить задание на хосте, перехватывая аварийное завершение (panic),
+ * что бы ошибка одного хоста не завершала выполнение на остальных
+ */
+func executeSafe(ctx context.Context, taskPath, outputDirectory string, target domains.Target, options Options) (result domains.HostResult) {
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			logger.ERROR(fmt.Sprintf("RUN: Host '%s' execution panic: %v\n%s",
+				target.Name, recovered, debug.Stack()))
+			result = domains.HostResult{Name: target.Name, Host: target.Host,
+				Status: ports.PIPE_STATUS_FAIL, Error: ports.ERROR_INTERNAL_PANIC}
+		}
+	}()
+
+	return ExecuteTarget(ctx, taskPath, outputDirectory, target, options)
+}
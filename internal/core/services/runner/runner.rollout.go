@@ -0,0 +1,203 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/andomize/network-automation-executor/internal/adapters/inventory"
+	"github.com/andomize/network-automation-executor/internal/adapters/logger"
+	"github.com/andomize/network-automation-executor/internal/core/domains"
+	"github.com/andomize/network-automation-executor/internal/core/ports"
+)
+
+type Rollout struct {
+	// Имена хостов первой (канареечной) волны
+	Canary []string
+
+	// Размер последующих волн: количество хостов ("10") или процент от
+	// общего числа хостов ("25%"). Пустое значение - все оставшиеся хосты
+	WaveSize string
+
+	// Допустимая доля неуспешных хостов в процентах, считается по всем
+	// выполненным волнам. Хост неуспешен, если задание или автотесты
+	// завершились с ошибкой. 0 - остановка после первого неуспешного хоста,
+	// 100 - порог не проверяется
+	MaxFailRate float64
+
+	// Подтверждение оператора перед запуском следующей волны
+	// (nil - волны запускаются без подтверждения)
+	Confirm func(wave int, targets []domains.Target, results []domains.HostResult) bool
+}
+
+/*
+ * PlanWaves
+ *
+ * Разделить хосты на волны: канареечные хосты (если есть) составляют
+ * первую волну, остальные хосты делятся на волны размера rollout.WaveSize
+ */
+func PlanWaves(targets []domains.Target, rollout Rollout) ([][]domains.Target, error) {
+
+	var waves = [][]domains.Target{}
+	var canary = []domains.Target{}
+	var rest = []domains.Target{}
+
+	var canaryNames = map[string]bool{}
+	for _, name := range rollout.Canary {
+		canaryNames[name] = true
+	}
+
+	for _, target := range targets {
+		if canaryNames[target.Name] {
+			canary = append(canary, target)
+		} else {
+			rest = append(rest, target)
+		}
+	}
+
+	if len(canary) > 0 {
+		waves = append(waves, canary)
+	} else if len(rollout.Canary) > 0 {
+		logger.WARNING("RUN_ROLLOUT: Canary hosts are not selected for execution, canary wave skipped")
+	}
+
+	waveSize, waveSizeError := parseWaveSize(rollout.WaveSize, len(targets))
+	if waveSizeError != nil {
+		return nil, waveSizeError
+	}
+	if waveSize <= 0 {
+		waveSize = len(rest)
+	}
+
+	for start := 0; start < len(rest); start += waveSize {
+		end := start + waveSize
+		if end > len(rest) {
+			end = len(rest)
+		}
+		waves = append(waves, rest[start:end])
+	}
+
+	return waves, nil
+}
+
+/*
+ * ExecuteRollout
+ *
+ * Поэтапно выполнить задание на хостах инвентаря
+ * Волны выполняются последовательно, хосты внутри волны - с использованием
+ * пула исполнителей. Если после волны доля неуспешных хостов превышает
+ * rollout.MaxFailRate или оператор не подтвердил продолжение, то оставшиеся
+ * хосты не обрабатываются и получают статус "skipped"
+ */
+func ExecuteRollout(
+	ctx context.Context,
+	taskPath, outputDirectory string,
+	waves [][]domains.Target,
+	rollout Rollout,
+	options Options,
+) []domains.HostResult {
+
+	var results = []domains.HostResult{}
+	var haltError string
+
+	for waveIdx, wave := range waves {
+
+		if len(haltError) <= 0 && ctx.Err() != nil {
+			haltError = ports.ERROR_CANCELLED
+		}
+
+		if len(haltError) > 0 {
+			for _, target := range wave {
+				results = append(results, domains.HostResult{Name: target.Name, Host: target.Host,
+					Status: ports.PIPE_STATUS_SKIPPED, Error: haltError, Wave: waveIdx + 1})
+			}
+			continue
+		}
+
+		// Подтверждение оператора перед каждой волной, кроме первой
+		if waveIdx > 0 && rollout.Confirm != nil && !rollout.Confirm(waveIdx+1, wave, results) {
+			logger.WARNING(fmt.Sprintf("RUN_ROLLOUT: Wave %v was cancelled by operator", waveIdx+1))
+			haltError = ports.ERROR_ROLLOUT_CANCELLED
+			for _, target := range wave {
+				results = append(results, domains.HostResult{Name: target.Name, Host: target.Host,
+					Status: ports.PIPE_STATUS_SKIPPED, Error: haltError, Wave: waveIdx + 1})
+			}
+			continue
+		}
+
+		logger.INFO(fmt.Sprintf("RUN_ROLLOUT: Starting wave %v of %v, hosts: %v",
+			waveIdx+1, len(waves), len(wave)))
+
+		results = append(results, executeTargets(ctx, taskPath, outputDirectory, wave, options, waveIdx+1, results)...)
+
+		failed, finished := FailedCount(results)
+		if finished <= 0 {
+			continue
+		}
+		failRate := float64(failed) * 100 / float64(finished)
+
+		logger.INFO(fmt.Sprintf("RUN_ROLLOUT: Wave %v finished, failed hosts: %v of %v (%.1f%%)",
+			waveIdx+1, failed, finished, failRate))
+
+		if failRate > rollout.MaxFailRate && waveIdx < len(waves)-1 {
+			logger.ERROR(fmt.Sprintf("RUN_ROLLOUT: Failure rate %.1f%% exceeds threshold %.1f%%, "+
+				"rollout halted", failRate, rollout.MaxFailRate))
+			haltError = ports.ERROR_ROLLOUT_HALTED
+		}
+	}
+
+	inventory.WriteReport(filepath.Join(outputDirectory, "summary.json"), results)
+
+	return results
+}
+
+/*
+ * FailedCount
+ *
+ * Количество неуспешных и количество обработанных хостов
+ * (хосты, пропущенные из-за остановки выполнения, не учитываются)
+ */
+func FailedCount(results []domains.HostResult) (int, int) {
+	var failed, finished int
+	for _, result := range results {
+		if result.Status == ports.PIPE_STATUS_SKIPPED {
+			continue
+		}
+		finished++
+		if result.Status != ports.PIPE_STATUS_SUCCESS || result.Autotests == ports.PIPE_STATUS_FAIL {
+			failed++
+		}
+	}
+	return failed, finished
+}
+
+/*
+ * parseWaveSize
+ *
+ * Преобразовать размер волны ("10" или "25%") в количество хостов
+ */
+func parseWaveSize(value string, total int) (int, error) {
+
+	value = strings.TrimSpace(value)
+	if len(value) <= 0 {
+		return 0, nil
+	}
+
+	percent := strings.HasSuffix(value, "%")
+	size, sizeError := strconv.Atoi(strings.TrimSuffix(value, "%"))
+	if sizeError != nil || size <= 0 || (percent && size > 100) {
+		return 0, errors.New("Wave size '" + value + "' must be a positive number or percent")
+	}
+
+	if percent {
+		size = (total*size + 99) / 100
+		if size <= 0 {
+			size = 1
+		}
+	}
+
+	return size, nil
+}
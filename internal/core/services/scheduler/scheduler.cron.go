@@ -0,0 +1,195 @@
+package scheduler
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+ * Cron
+ *
+ * Расписание в формате cron: "минуты часы дни_месяца месяцы дни_недели"
+ * Поддерживаются *, списки (1,15), диапазоны (1-5), шаги (0-30/10),
+ * имена месяцев и дней недели (jan, mon) и макросы @hourly, @daily,
+ * @weekly, @monthly, @yearly
+ */
+type Cron struct {
+	minutes  map[int]bool
+	hours    map[int]bool
+	days     map[int]bool
+	months   map[int]bool
+	weekdays map[int]bool
+
+	// Дни месяца и дни недели ограничены (в этом случае, как и в cron,
+	// достаточно совпадения одного из полей)
+	daysRestricted     bool
+	weekdaysRestricted bool
+}
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var cronMonths = []string{"", "jan", "feb", "mar", "apr", "may", "jun",
+	"jul", "aug", "sep", "oct", "nov", "dec"}
+
+var cronWeekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+/*
+ * ParseCron
+ *
+ * Разобрать расписание в формате cron
+ */
+func ParseCron(expression string) (*Cron, error) {
+
+	expression = strings.TrimSpace(expression)
+	if macro, exist := cronMacros[strings.ToLower(expression)]; exist {
+		expression = macro
+	}
+
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, errors.New("Cron expression '" + expression + "' must contain 5 fields")
+	}
+
+	var cron Cron
+	var parseError error
+
+	if cron.minutes, parseError = parseCronField(fields[0], 0, 59, nil); parseError != nil {
+		return nil, parseError
+	}
+	if cron.hours, parseError = parseCronField(fields[1], 0, 23, nil); parseError != nil {
+		return nil, parseError
+	}
+	if cron.days, parseError = parseCronField(fields[2], 1, 31, nil); parseError != nil {
+		return nil, parseError
+	}
+	if cron.months, parseError = parseCronField(fields[3], 1, 12, cronMonths); parseError != nil {
+		return nil, parseError
+	}
+	if cron.weekdays, parseError = parseCronField(fields[4], 0, 7, cronWeekdays); parseError != nil {
+		return nil, parseError
+	}
+
+	// Воскресенье может быть указано как 0 или 7
+	if cron.weekdays[7] {
+		cron.weekdays[0] = true
+	}
+
+	cron.daysRestricted = fields[2] != "*" && fields[2] != "?"
+	cron.weekdaysRestricted = fields[4] != "*" && fields[4] != "?"
+
+	return &cron, nil
+}
+
+/*
+ * Cron.Next
+ *
+ * Ближайшее время запуска строго после указанного времени
+ * Если время не найдено в пределах 5 лет (например, "0 0 31 2 *"), то
+ * возвращается нулевое время
+ */
+func (c *Cron) Next(after time.Time) time.Time {
+
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if !c.months[int(t.Month())] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.dayMatch(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.hours[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.minutes[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
+
+func (c *Cron) dayMatch(t time.Time) bool {
+	day, weekday := c.days[t.Day()], c.weekdays[int(t.Weekday())]
+
+	if c.daysRestricted && c.weekdaysRestricted {
+		return day || weekday
+	}
+	return day && weekday
+}
+
+/*
+ * parseCronField
+ *
+ * Разобрать поле расписания в множество допустимых значений
+ */
+func parseCronField(field string, min, max int, names []string) (map[int]bool, error) {
+
+	var values = map[int]bool{}
+
+	for _, item := range strings.Split(strings.ToLower(field), ",") {
+
+		step := 1
+		if index := strings.Index(item, "/"); index >= 0 {
+			parsed, stepError := strconv.Atoi(item[index+1:])
+			if stepError != nil || parsed <= 0 {
+				return nil, errors.New("Cron field '" + field + "' has incorrect step")
+			}
+			step, item = parsed, item[:index]
+		}
+
+		start, end := min, max
+		if item != "*" && item != "?" {
+			bounds := strings.SplitN(item, "-", 2)
+
+			var boundError error
+			if start, boundError = cronValue(bounds[0], names); boundError != nil {
+				return nil, errors.New("Cron field '" + field + "' has incorrect value")
+			}
+			end = start
+			if len(bounds) == 2 {
+				if end, boundError = cronValue(bounds[1], names); boundError != nil {
+					return nil, errors.New("Cron field '" + field + "' has incorrect value")
+				}
+			} else if step > 1 {
+				// "5/15" - с 5 до конца диапазона с шагом 15
+				end = max
+			}
+		}
+
+		if start < min || end > max || start > end {
+			return nil, errors.New("Cron field '" + field + "' is out of range")
+		}
+
+		for value := start; value <= end; value += step {
+			values[value] = true
+		}
+	}
+
+	return values, nil
+}
+
+func cronValue(value string, names []string) (int, error) {
+	for index, name := range names {
+		if len(name) > 0 && value == name {
+			return index, nil
+		}
+	}
+	return strconv.Atoi(value)
+}
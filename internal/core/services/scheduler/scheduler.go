@@ -0,0 +1,330 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/andomize/network-automation-executor/internal/adapters/filestorage"
+	"github.com/andomize/network-automation-executor/internal/adapters/inventory"
+	"github.com/andomize/network-automation-executor/internal/adapters/jsontask"
+	"github.com/andomize/network-automation-executor/internal/adapters/logger"
+	"github.com/andomize/network-automation-executor/internal/core/domains"
+	"github.com/andomize/network-automation-executor/internal/core/ports"
+	"github.com/andomize/network-automation-executor/internal/core/services/runner"
+)
+
+// Имя файла истории запусков в директории выводов расписания
+const HistoryFilename = "history.jsonl"
+
+type entry struct {
+	schedule    domains.Schedule
+	cron        *Cron
+	groupLimits map[string]int
+	next        time.Time
+
+	// Предыдущий запуск ещё выполняется
+	running bool
+}
+
+/*
+ * Scheduler
+ *
+ * Периодический запуск заданий по расписанию в формате cron
+ * Каждый запуск выполняется в отдельной директории <output>/<дата-время>/,
+ * история запусков сохраняется в <output>/history.jsonl
+ * Новый запуск расписания не начинается, пока выполняется предыдущий
+ */
+type Scheduler struct {
+	entries []*entry
+	options runner.Options
+
+	mutex        sync.Mutex
+	historyMutex sync.Mutex
+	wait         sync.WaitGroup
+}
+
+/*
+ * NewScheduler
+ *
+ * Прочитать файл расписаний. Относительные пути в расписаниях
+ * определяются относительно директории файла расписаний
+ */
+func NewScheduler(configPath string, options runner.Options) (*Scheduler, error) {
+
+	logger.DEBUG("SCHEDULER_NEW: Starting read file: '" + configPath + "'")
+
+	configContent, readError := ioutil.ReadFile(configPath)
+	if readError != nil {
+		return nil, readError
+	}
+
+	var config domains.ScheduleConfig
+	if unmarshalError := json.Unmarshal(configContent, &config); unmarshalError != nil {
+		return nil, unmarshalError
+	}
+
+	if len(config.Schedules) <= 0 {
+		return nil, errors.New("Schedules file '" + configPath + "' does not contain schedules")
+	}
+
+	scheduler := &Scheduler{options: options}
+	names := map[string]bool{}
+	base := filepath.Dir(configPath)
+
+	for index, schedule := range config.Schedules {
+
+		if len(schedule.Name) <= 0 {
+			schedule.Name = fmt.Sprintf("schedule-%v", index+1)
+		}
+		if names[schedule.Name] {
+			return nil, errors.New("Schedule name '" + schedule.Name + "' is duplicated")
+		}
+		names[schedule.Name] = true
+
+		if len(schedule.Task) <= 0 || len(schedule.Output) <= 0 {
+			return nil, errors.New("Schedule '" + schedule.Name + "' must contain task and output")
+		}
+
+		cron, cronError := ParseCron(schedule.Cron)
+		if cronError != nil {
+			return nil, fmt.Errorf("Schedule '%s': %v", schedule.Name, cronError)
+		}
+
+		groupLimits, groupLimitsError := runner.ParseGroupLimits(schedule.GroupLimits)
+		if groupLimitsError != nil {
+			return nil, fmt.Errorf("Schedule '%s': %v", schedule.Name, groupLimitsError)
+		}
+
+		schedule.Task = absolutePath(base, schedule.Task)
+		schedule.Output = absolutePath(base, schedule.Output)
+		if len(schedule.Inventory) > 0 {
+			schedule.Inventory = absolutePath(base, schedule.Inventory)
+		}
+
+		scheduler.entries = append(scheduler.entries, &entry{
+			schedule:    schedule,
+			cron:        cron,
+			groupLimits: groupLimits,
+		})
+	}
+
+	return scheduler, nil
+}
+
+/*
+ * Scheduler.Run
+ *
+ * Запускать задания по расписанию до отмены контекста
+ * При отмене выполняемые запуски прерываются, метод ожидает их завершения
+ */
+func (s *Scheduler) Run(ctx context.Context) {
+
+	now := time.Now()
+	for _, e := range s.entries {
+		e.next = e.cron.Next(now)
+		logger.INFO("SCHEDULER: Schedule '" + e.schedule.Name + "' next run: " + formatNext(e.next))
+	}
+
+	for {
+		// Ближайший запуск среди всех расписаний
+		var nearest time.Time
+		for _, e := range s.entries {
+			if !e.next.IsZero() && (nearest.IsZero() || e.next.Before(nearest)) {
+				nearest = e.next
+			}
+		}
+		if nearest.IsZero() {
+			logger.WARNING("SCHEDULER: No schedules with future runs")
+			break
+		}
+
+		timer := time.NewTimer(time.Until(nearest))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			s.wait.Wait()
+			return
+		case <-timer.C:
+		}
+
+		now := time.Now()
+		for _, e := range s.entries {
+			if !e.next.IsZero() && !e.next.After(now) {
+				s.trigger(ctx, e)
+				e.next = e.cron.Next(now)
+				logger.DEBUG("SCHEDULER: Schedule '" + e.schedule.Name + "' next run: " + formatNext(e.next))
+			}
+		}
+	}
+
+	s.wait.Wait()
+}
+
+/*
+ * Scheduler.trigger
+ *
+ * Начать запуск расписания, если предыдущий запуск завершён
+ */
+func (s *Scheduler) trigger(ctx context.Context, e *entry) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if e.running {
+		logger.WARNING("SCHEDULER: Schedule '" + e.schedule.Name + "' previous run is still running, skipping...")
+		s.history(e.schedule, domains.ScheduleRun{
+			Schedule: e.schedule.Name,
+			Status:   ports.PIPE_STATUS_SKIPPED,
+			Error:    ports.ERROR_SCHEDULE_OVERLAP,
+			Started:  time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	e.running = true
+	s.wait.Add(1)
+
+	go func() {
+		defer s.wait.Done()
+		defer func() {
+			s.mutex.Lock()
+			e.running = false
+			s.mutex.Unlock()
+		}()
+
+		s.history(e.schedule, s.execute(ctx, e))
+	}()
+}
+
+/*
+ * Scheduler.execute
+ *
+ * Выполнить задание расписания на хосте задания или на хостах инвентаря
+ */
+func (s *Scheduler) execute(ctx context.Context, e *entry) domains.ScheduleRun {
+
+	startTime := time.Now()
+	directory := filepath.Join(e.schedule.Output, startTime.Format("2006-01-02_15-04-05"))
+
+	run := domains.ScheduleRun{
+		Schedule:  e.schedule.Name,
+		Started:   startTime.Format(time.RFC3339),
+		Directory: directory,
+	}
+
+	logger.INFO("SCHEDULER: Starting schedule '" + e.schedule.Name + "' run in '" + directory + "'")
+
+	targets, targetsError := s.targets(e.schedule)
+	if targetsError != nil {
+		logger.ERROR("SCHEDULER: Schedule '" + e.schedule.Name + "' failed by reason: " + targetsError.Error())
+		run.Status, run.Error = ports.PIPE_STATUS_FAIL, targetsError.Error()
+	} else {
+		options := s.options
+		options.Workers = e.schedule.Workers
+		options.GroupLimits = e.groupLimits
+		options.HostTimeout = e.schedule.HostTimeout
+
+		results := runner.ExecuteInventory(ctx, e.schedule.Task, directory, targets, options)
+
+		run.Failed, run.Hosts = runner.FailedCount(results)
+		run.Status = ports.PIPE_STATUS_SUCCESS
+		if run.Failed > 0 {
+			run.Status = ports.PIPE_STATUS_FAIL
+			run.Error = ports.ERROR_JOB_HOSTS_FAILED
+			if len(results) == 1 {
+				run.Error = results[0].Error
+			}
+		}
+	}
+
+	run.Finished = time.Now().Format(time.RFC3339)
+	run.Duration = time.Since(startTime).Round(time.Millisecond).String()
+
+	logger.INFO("SCHEDULER: Schedule '" + e.schedule.Name + "' run finished with status '" + run.Status + "'")
+
+	return run
+}
+
+/*
+ * Scheduler.targets
+ *
+ * Хосты для запуска: выбранные хосты инвентаря или хост из файла задания
+ */
+func (s *Scheduler) targets(schedule domains.Schedule) ([]domains.Target, error) {
+
+	if len(schedule.Inventory) > 0 {
+		inventoryData, inventoryError := inventory.Read(schedule.Inventory)
+		if inventoryError != nil {
+			return nil, inventoryError
+		}
+		return inventory.Select(inventoryData, schedule.Limit)
+	}
+
+	task, taskReadError := jsontask.Read(schedule.Task)
+	if taskReadError != nil {
+		return nil, taskReadError
+	}
+	if len(task.Host) <= 0 {
+		return nil, errors.New(ports.ERROR_SYNTAX_NO_HOST)
+	}
+
+	return []domains.Target{{
+		Name:      filestorage.NewFileStorage("").NameNormalization(task.Host),
+		Host:      task.Host,
+		Variables: map[string]string{},
+	}}, nil
+}
+
+/*
+ * Scheduler.history
+ *
+ * Добавить запись в историю запусков расписания
+ */
+func (s *Scheduler) history(schedule domains.Schedule, run domains.ScheduleRun) {
+
+	s.historyMutex.Lock()
+	defer s.historyMutex.Unlock()
+
+	historyPath := filepath.Join(schedule.Output, HistoryFilename)
+
+	runJSON, marshalError := json.Marshal(run)
+	if marshalError != nil {
+		logger.ERROR("SCHEDULER_HISTORY: Cannot marshal run of schedule '" + schedule.Name + "'")
+		return
+	}
+
+	if mkdirError := os.MkdirAll(schedule.Output, os.ModePerm); mkdirError != nil {
+		logger.ERROR("SCHEDULER_HISTORY: Cannot create directory: '" + schedule.Output + "'")
+		return
+	}
+
+	file, openError := os.OpenFile(historyPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if openError != nil {
+		logger.ERROR("SCHEDULER_HISTORY: Cannot open file: '" + historyPath + "'")
+		return
+	}
+	defer file.Close()
+
+	file.Write(append(runJSON, '\n'))
+}
+
+func absolutePath(base, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(base, path)
+}
+
+func formatNext(next time.Time) string {
+	if next.IsZero() {
+		return "never"
+	}
+	return next.Format(time.RFC3339)
+}
@@ -1,13 +1,18 @@
 package spawner
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/andomize/network-automation-executor/internal/adapters/logger"
+	"github.com/andomize/network-automation-executor/internal/adapters/metrics"
 	"github.com/andomize/network-automation-executor/internal/core/ports"
+	expect "github.com/google/goexpect"
 )
 
 type Connection struct {
@@ -20,6 +25,17 @@ type Connection struct {
 
 	// Содержит вывод с устройства при первоначальном подключении
 	connectOutput string
+
+	// Журнал соединения
+	Log *logger.Logger
+
+	// Получатель копии вывода устройства (может отсутствовать)
+	output io.Writer
+
+	// Данные для повторного подключения (например, после перезагрузки)
+	host     string
+	username string
+	password string
 }
 
 /*
@@ -28,22 +44,13 @@ type Connection struct {
  * Базовый метод для подключения к удалённому устройству
  * Выполняется попытка установить удалённое соединение посредством следующих
  * возможных утилит: ssh1, ssh, telnet
+ * Отмена контекста прерывает подключение с ошибкой ContextErrorCode
  */
-func NewConnection(host, username, password string) (*Connection, error) {
+func NewConnection(ctx context.Context, host, username, password string, log *logger.Logger) (*Connection, error) {
 
 	// Предопределим команду вызова утилиты SSH
 	// ssh -o connecttimeout=20 -o StrictHostKeyChecking=no ... user@host
-	// Предопределим все аргументы для вызова утилиты SSH
-	var ssh_KexAlgorithms = "-o KexAlgorithms=+diffie-hellman-group1-sha1," +
-		"diffie-hellman-group14-sha1,diffie-hellman-group14-sha256," +
-		"diffie-hellman-group16-sha512,diffie-hellman-group-exchange-sha1," +
-		"diffie-hellman-group-exchange-sha256,ecdh-sha2-nistp256," +
-		"ecdh-sha2-nistp384,ecdh-sha2-nistp521,curve25519-sha256"
-	var ssh_Ciphers = "-o Ciphers=+aes128-cbc,3des-cbc,aes192-cbc,aes256-cbc"
-	var ssh_HostKeyAlgorithms = "-o HostKeyAlgorithms=+ssh-dss,ssh-rsa"
-	var ssh_command = "ssh -o connecttimeout=20 -o StrictHostKeyChecking=no " +
-		ssh_KexAlgorithms + " " + ssh_HostKeyAlgorithms + " " + ssh_Ciphers +
-		" " + username + "@" + host
+	var ssh_command = "ssh " + SSHOptions() + " " + username + "@" + host
 
 	// Предопределим команду вызова утилиты SSH1
 	// ssh1 -o connecttimeout=20 -o StrictHostKeyChecking=no user@host
@@ -55,42 +62,66 @@ func NewConnection(host, username, password string) (*Connection, error) {
 	var telnet_command = "telnet -l " + username + " " + host
 
 	// ПОПЫТКА 1. Подключение с использованием SSH1
-	spawnSSH1, outputSSH1, errorSSH1 := NewSpawn(username, password, ssh1_command)
+	spawnSSH1, outputSSH1, errorSSH1 := NewSpawn(ctx, username, password, ssh1_command, log)
+	connectionMetric("ssh1", errorSSH1)
 	if errorSSH1 == nil {
 		// Успешное подключение с испольованием протокола SSH1
-		logger.DEBUG("CONN_NEW: Connection using SSH1 successful")
+		log.DEBUG("CONN_NEW: Connection using SSH1 successful")
 
 		connection := Connection{
 			spawn:         spawnSSH1,
 			connectOutput: outputSSH1,
+			host:          host,
+			username:      username,
+			password:      password,
+			Log:           log,
 		}
-		return &connection, connection.PromptDefine()
+		return &connection, connection.define(ctx)
+	}
+	if ctx.Err() != nil {
+		return nil, errors.New(ContextErrorCode(ctx))
 	}
 
 	// ПОПЫТКА 2. Подключение с использованием SSH
-	spawnSSH, outputSSH, errorSSH := NewSpawn(username, password, ssh_command)
+	spawnSSH, outputSSH, errorSSH := NewSpawn(ctx, username, password, ssh_command, log)
+	connectionMetric("ssh", errorSSH)
 	if errorSSH == nil {
 		// Успешное подключение с испольованием протокола SSH
-		logger.DEBUG("CONN_NEW: Connection using SSH successful")
+		log.DEBUG("CONN_NEW: Connection using SSH successful")
 
 		connection := Connection{
 			spawn:         spawnSSH,
 			connectOutput: outputSSH,
+			host:          host,
+			username:      username,
+			password:      password,
+			Log:           log,
 		}
-		return &connection, connection.PromptDefine()
+		return &connection, connection.define(ctx)
+	}
+	if ctx.Err() != nil {
+		return nil, errors.New(ContextErrorCode(ctx))
 	}
 
 	// ПОПЫТКА 3. Подключение с использованием Telnet
-	spawnTelnet, outputTelnet, errorTelnet := NewSpawn(username, password, telnet_command)
+	spawnTelnet, outputTelnet, errorTelnet := NewSpawn(ctx, username, password, telnet_command, log)
+	connectionMetric("telnet", errorTelnet)
 	if errorTelnet == nil {
 		// Успешное подключение с испольованием протокола Telnet
-		logger.DEBUG("CONN_NEW: Connect using Telnet successful")
+		log.DEBUG("CONN_NEW: Connect using Telnet successful")
 
 		connection := Connection{
 			spawn:         spawnTelnet,
 			connectOutput: outputTelnet,
+			host:          host,
+			username:      username,
+			password:      password,
+			Log:           log,
 		}
-		return &connection, connection.PromptDefine()
+		return &connection, connection.define(ctx)
+	}
+	if ctx.Err() != nil {
+		return nil, errors.New(ContextErrorCode(ctx))
 	}
 
 	// Если ни одна из попыток подключиться не была успешной,
@@ -109,6 +140,83 @@ func NewConnection(host, username, password string) (*Connection, error) {
 	return nil, errors.New(ports.ERROR_CONN_NO_AVAILABLE_METHOD)
 }
 
+/*
+ * Connection.define
+ *
+ * Определить Prompt нового соединения с учётом отмены контекста
+ */
+func (c *Connection) define(ctx context.Context) error {
+	stop := closeOnDone(ctx, c.spawn.Session)
+	defineError := c.PromptDefine()
+	stop()
+
+	if ctx.Err() != nil {
+		return errors.New(ContextErrorCode(ctx))
+	}
+	return defineError
+}
+
+/*
+ * closeOnDone
+ *
+ * Закрыть сессию при отмене контекста до вызова возвращаемой функции
+ */
+func closeOnDone(ctx context.Context, session *expect.GExpect) func() {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			session.Close()
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
+
+/*
+ * ContextErrorCode
+ *
+ * Код ошибки, соответствующий причине отмены контекста
+ */
+func ContextErrorCode(ctx context.Context) string {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return ports.ERROR_HOST_TIMEOUT
+	}
+	return ports.ERROR_CANCELLED
+}
+
+/*
+ * connectionMetric
+ *
+ * Учесть попытку подключения в метриках
+ * Попытки, для которых утилита подключения недоступна, не учитываются
+ */
+func connectionMetric(protocol string, connectionError error) {
+	if connectionError == nil {
+		metrics.ConnectionAttempt(protocol, ports.PIPE_STATUS_SUCCESS)
+	} else if connectionError.Error() != ports.ERROR_INTERNAL_EXEC {
+		metrics.ConnectionAttempt(protocol, connectionError.Error())
+	}
+}
+
+/*
+ * SSHOptions
+ *
+ * Предопределим все аргументы для вызова утилит SSH, SCP и SFTP
+ */
+func SSHOptions() string {
+	var ssh_KexAlgorithms = "-o KexAlgorithms=+diffie-hellman-group1-sha1," +
+		"diffie-hellman-group14-sha1,diffie-hellman-group14-sha256," +
+		"diffie-hellman-group16-sha512,diffie-hellman-group-exchange-sha1," +
+		"diffie-hellman-group-exchange-sha256,ecdh-sha2-nistp256," +
+		"ecdh-sha2-nistp384,ecdh-sha2-nistp521,curve25519-sha256"
+	var ssh_Ciphers = "-o Ciphers=+aes128-cbc,3des-cbc,aes192-cbc,aes256-cbc"
+	var ssh_HostKeyAlgorithms = "-o HostKeyAlgorithms=+ssh-dss,ssh-rsa"
+
+	return "-o connecttimeout=20 -o StrictHostKeyChecking=no " +
+		ssh_KexAlgorithms + " " + ssh_HostKeyAlgorithms + " " + ssh_Ciphers
+}
+
 /*
  * Connection.Send
  *
@@ -133,7 +241,9 @@ func (c *Connection) Send(command string, timeout int, promptChangeAllowed bool)
 
 	// Выполняем отправку команды на удалённое устройство
 	// Передаём Prompt, который ожидаем увидеть после выполнения команды
+	sendTime := time.Now()
 	output, sendError := c.spawn.SendString(command, timeout, nextPrompt)
+	metrics.CommandDuration(c.Prompt.Vendor, time.Since(sendTime))
 
 	// Удаляем служебные символы и лишние пробелы по краям вывода
 	// p.s. это только для отдачи запросчику (не участвует в логике)
@@ -147,8 +257,11 @@ func (c *Connection) Send(command string, timeout int, promptChangeAllowed bool)
 	// Если команда была отправлена с ошибками, то выходим из метода без
 	// дальнейшего определения Prompt
 	if sendError != nil {
-		logger.DEBUG("CONN_SEND: Command: '" + command +
+		c.Log.DEBUG("CONN_SEND: Command: '" + command +
 			"' sending failed by reason: " + sendError.Error())
+		if sendError.Error() == ports.ERROR_PROMPT_TIMEOUT {
+			metrics.PromptFailure(c.Prompt.Vendor, sendError.Error())
+		}
 		return output, sendError
 	}
 
@@ -157,7 +270,7 @@ func (c *Connection) Send(command string, timeout int, promptChangeAllowed bool)
 
 	// Проверяем что Prompt устройства был корректно определён
 	if promptDefineError != nil {
-		logger.DEBUG("CONN_SEND: After send command: '" + command +
+		c.Log.DEBUG("CONN_SEND: After send command: '" + command +
 			"' prompt is undefined by reason: " + promptDefineError.Error())
 		return output, promptDefineError
 	}
@@ -166,8 +279,9 @@ func (c *Connection) Send(command string, timeout int, promptChangeAllowed bool)
 	// Если текущий захваченный Prompt отличается от прежнего
 	// и не установлен разрешающий флаг смены Prompt, то вызываем ошибку
 	if c.Prompt.Name != currentPrompt.Name && !promptChangeAllowed {
-		logger.DEBUG("CONN_SEND: After send command: '" + command +
+		c.Log.DEBUG("CONN_SEND: After send command: '" + command +
 			"' prompt has been changed, but its not allowed!")
+		metrics.PromptFailure(currentPrompt.Vendor, ports.ERROR_PROMPT_CHANGED)
 		return output, errors.New(ports.ERROR_PROMPT_CHANGED)
 	}
 
@@ -216,15 +330,20 @@ func (c *Connection) PromptDefine() error {
 		output = "\r\n" + output
 	}
 
-	logger.DEBUG(fmt.Sprintf("SPAWNER_PROMPT_DEF: Prompt verify using string: '%s'", output))
+	c.Log.DEBUG(fmt.Sprintf("SPAWNER_PROMPT_DEF: Prompt verify using string: '%s'", output))
 
 	// Определяем текущий prompt на основе возвращённого вывода
-	prompt, promptError := NewPrompt(output)
+	prompt, promptError := NewPrompt(output, c.Log)
 	if promptError != nil {
+		vendor := "unknown"
+		if c.Prompt != nil {
+			vendor = c.Prompt.Vendor
+		}
+		metrics.PromptFailure(vendor, promptError.Error())
 		return promptError
 	}
 
-	logger.DEBUG(fmt.Sprintf("SPAWNER_PROMPT_DEF: Prompt changed to: '%s'", prompt.Name))
+	c.Log.DEBUG(fmt.Sprintf("SPAWNER_PROMPT_DEF: Prompt changed to: '%s'", prompt.Name))
 
 	// Устанавливаем захваченный prompt как текущий
 	c.Prompt = prompt
@@ -269,7 +388,7 @@ func (c *Connection) PromptDefine() error {
  * Закрываем сессию к удалённому устройству
  */
 func (c *Connection) Close() {
-	if c.spawn.Session != nil {
+	if c.spawn != nil && c.spawn.Session != nil {
 		c.spawn.Session.Close()
 	}
 }
@@ -0,0 +1,55 @@
+package spawner
+
+import (
+	"io"
+	"sync"
+
+	"github.com/andomize/network-automation-executor/internal/adapters/metrics"
+)
+
+/*
+ * outputTee
+ *
+ * Получатель копии вывода Spawn-сессии в момент его поступления
+ * Получатель может быть установлен и изменён после открытия сессии
+ */
+type outputTee struct {
+	mutex  sync.Mutex
+	writer io.Writer
+}
+
+func (t *outputTee) set(writer io.Writer) {
+	t.mutex.Lock()
+	t.writer = writer
+	t.mutex.Unlock()
+}
+
+func (t *outputTee) Write(data []byte) (int, error) {
+	metrics.BytesReceived(len(data))
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.writer != nil {
+		t.writer.Write(data)
+	}
+	return len(data), nil
+}
+
+// Закрытие Spawn-сессии не закрывает получателя
+func (t *outputTee) Close() error {
+	return nil
+}
+
+/*
+ * Connection.SetOutput
+ *
+ * Передавать весь вывод устройства получателю writer по мере поступления
+ * (nil - отключить). Получатель сохраняется при повторном подключении
+ */
+func (c *Connection) SetOutput(writer io.Writer) {
+	c.output = writer
+	if c.spawn != nil {
+		c.spawn.output.set(writer)
+	}
+}
@@ -170,7 +170,7 @@ func (p *Prompt) GetErrors() *regexp.Regexp {
 	}
 }
 
-func NewPrompt(output string) (*Prompt, error) {
+func NewPrompt(output string, log *logger.Logger) (*Prompt, error) {
 
 	// Using console output to choose correct device prompt
 	switch {
@@ -194,9 +194,9 @@ func NewPrompt(output string) (*Prompt, error) {
 		return &PromptRadwareAlteon, nil
 	}
 
-	logger.DEBUG("PROMPT_NEW: Cannot define prompt: '" + output + "'")
-	logger.DEBUG("PROMPT_NEW: Cannot define prompt: '" + fmt.Sprint([]byte(output)) + "'")
-	logger.DEBUG("PROMPT_NEW: Cannot define prompt: '" + ByteDebugInterpreter(output) + "'")
+	log.DEBUG("PROMPT_NEW: Cannot define prompt: '" + output + "'")
+	log.DEBUG("PROMPT_NEW: Cannot define prompt: '" + fmt.Sprint([]byte(output)) + "'")
+	log.DEBUG("PROMPT_NEW: Cannot define prompt: '" + ByteDebugInterpreter(output) + "'")
 
 	return nil, errors.New(ports.ERROR_PROMPT_DEFINE)
 }
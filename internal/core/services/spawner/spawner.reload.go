@@ -0,0 +1,174 @@
+package spawner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"time"
+
+	"github.com/andomize/network-automation-executor/internal/core/ports"
+)
+
+type Responder struct {
+	// Regular expression that define confirmation request
+	RegExp *regexp.Regexp
+
+	// Answer that will be sent to device (without "\n")
+	Answer string
+}
+
+var (
+	// Сообщения о закрытии сессии удалённой стороной
+	PromptDisconnect = regexp.MustCompile(`([Cc]onnection\s(to\s\S+\s)?closed|` +
+		`[Cc]onnection\sclosed\sby\sforeign\shost|[Cc]onnection\sreset\sby\speer)`)
+
+	// Запрос сохранения конфигурации перед перезагрузкой
+	// System configuration has been modified. Save? [yes/no]:
+	// Ответ задаётся только в responders задания, иначе задание завершается ошибкой
+	PromptReloadSave = regexp.MustCompile(`[Ss]ave\?\s*\[yes/no\]`)
+
+	// Типовые запросы подтверждения при перезагрузке устройств
+	ReloadResponders = []Responder{
+		// Proceed with reload? [confirm]
+		{RegExp: regexp.MustCompile(`\[confirm\]`), Answer: ""},
+		// Info: The system is now comparing the configuration, please wait.
+		// Warning: The configuration has been modified, and it will be saved to the next startup saved-configuration file. Continue? [Y/N]:
+		{RegExp: regexp.MustCompile(`\[[Yy]/[Nn]\]`), Answer: "Y"},
+		// Do you want to reboot the system? (y/n)
+		{RegExp: regexp.MustCompile(`\([Yy]/[Nn]\)`), Answer: "y"},
+	}
+)
+
+/*
+ * Connection.SendDetached
+ *
+ * Отправить команду, после которой устройство закрывает сессию (reload, reboot)
+ * На все запросы подтверждения отвечаем согласно responders, затем ожидаем
+ * разрыв соединения. Возврат Prompt считается ошибкой
+ */
+func (c *Connection) SendDetached(command string, timeout int, responders []Responder) (string, error) {
+
+	// Ответы из задания имеют приоритет над типовыми ответами
+	responders = append(responders, ReloadResponders...)
+
+	output, sendError := c.spawn.SendDetached(command, timeout, responders, c.Prompt)
+	if sendError != nil {
+		c.Log.DEBUG("CONN_SEND_DETACHED: Command: '" + command +
+			"' sending failed by reason: " + sendError.Error())
+		return output, sendError
+	}
+
+	// Сессия закрыта удалённой стороной - освобождаем ресурсы
+	c.Close()
+
+	return output, nil
+}
+
+/*
+ * Connection.Reconnect
+ *
+ * Ожидаем возвращения хоста после перезагрузки и повторно подключаемся к нему
+ * 1) Ожидаем пропадания хоста (не дольше RELOAD_DOWN_WINDOW)
+ * 2) Ожидаем доступности SSH/Telnet порта хоста
+ * 3) Подключаемся и определяем Prompt
+ * Все этапы должны уложиться в waitReturn секунд
+ * Отмена контекста прерывает ожидание с ошибкой ContextErrorCode
+ */
+func (c *Connection) Reconnect(ctx context.Context, waitReturn int) error {
+
+	deadline := time.Now().Add(time.Duration(waitReturn) * time.Second)
+	downDeadline := time.Now().Add(time.Duration(ports.RELOAD_DOWN_WINDOW) * time.Second)
+	interval := time.Duration(ports.RELOAD_POLL_INTERVAL) * time.Second
+
+	c.Log.DEBUG(fmt.Sprintf("CONN_RECONNECT: Waiting for host '%s' return, deadline: '%v'",
+		c.host, deadline.Format(time.RFC3339)))
+
+	// Этап 1. Устройство может ещё некоторое время отвечать после разрыва сессии
+	for HostReachable(ctx, c.host) && time.Now().Before(downDeadline) && time.Now().Before(deadline) {
+		c.Log.DEBUG("CONN_RECONNECT: Host '" + c.host + "' is still reachable, waiting...")
+		if !sleep(ctx, interval) {
+			return errors.New(ContextErrorCode(ctx))
+		}
+	}
+
+	// Этапы 2 и 3. Ожидаем доступности хоста и подключаемся к нему
+	for time.Now().Before(deadline) {
+
+		if ctx.Err() != nil {
+			return errors.New(ContextErrorCode(ctx))
+		}
+
+		if !HostReachable(ctx, c.host) {
+			c.Log.DEBUG("CONN_RECONNECT: Host '" + c.host + "' is unreachable, waiting...")
+			sleep(ctx, interval)
+			continue
+		}
+
+		connection, connectionError := NewConnection(ctx, c.host, c.username, c.password, c.Log)
+		if connectionError != nil && ctx.Err() == nil {
+			c.Log.DEBUG("CONN_RECONNECT: Connection to host '" + c.host +
+				"' failed by reason: " + connectionError.Error() + ", waiting...")
+			if connection != nil {
+				connection.Close()
+			}
+			sleep(ctx, interval)
+			continue
+		}
+
+		// Ожидание прервано во время подключения
+		if ctx.Err() != nil {
+			if connection != nil {
+				connection.Close()
+			}
+			return errors.New(ContextErrorCode(ctx))
+		}
+
+		// Подменяем сессию текущего соединения на новую
+		c.spawn = connection.spawn
+		c.Prompt = connection.Prompt
+		c.connectOutput = connection.connectOutput
+		c.spawn.output.set(c.output)
+
+		c.Log.DEBUG("CONN_RECONNECT: Host '" + c.host + "' returned, prompt: '" + c.Prompt.Name + "'")
+		return nil
+	}
+
+	return errors.New(ports.ERROR_RELOAD_RETURN_TIMEOUT)
+}
+
+/*
+ * HostReachable
+ *
+ * Проверяем доступность SSH или Telnet порта на удалённом хосте
+ */
+func HostReachable(ctx context.Context, host string) bool {
+	dialer := net.Dialer{Timeout: time.Duration(ports.RELOAD_POLL_INTERVAL) * time.Second}
+	for _, port := range []string{"22", "23"} {
+		conn, dialError := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+		if dialError == nil {
+			conn.Close()
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ * sleep
+ *
+ * Пауза длительностью duration, прерываемая отменой контекста
+ * Возвращает false, если пауза была прервана
+ */
+func sleep(ctx context.Context, duration time.Duration) bool {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -1,6 +1,7 @@
 package spawner
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"regexp"
@@ -25,18 +26,26 @@ type Spawn struct {
 
 	Username string
 	Password string
+
+	// Журнал сессии
+	Log *logger.Logger
+
+	// Получатель копии вывода сессии
+	output *outputTee
 }
 
-func NewSpawn(username, password, bashCommand string) (*Spawn, string, error) {
+func NewSpawn(ctx context.Context, username, password, bashCommand string, log *logger.Logger) (*Spawn, string, error) {
 
 	// Создаём экземпляр Spawn сессии
 	spawn := Spawn{
 		Username: username,
 		Password: password,
+		Log:      log,
+		output:   &outputTee{},
 	}
 
 	// Открываем Spawn сессию
-	output, openError := spawn.Open(bashCommand)
+	output, openError := spawn.Open(ctx, bashCommand)
 	if openError != nil {
 		return nil, output, openError
 	}
@@ -48,22 +57,24 @@ func NewSpawn(username, password, bashCommand string) (*Spawn, string, error) {
  * Spawn.Open
  *
  * Authentication on remote device using specific command
+ * Context cancellation closes the spawned process and interrupts authentication
  */
-func (s *Spawn) Open(bashCommand string) (string, error) {
+func (s *Spawn) Open(ctx context.Context, bashCommand string) (string, error) {
 
 	// Spawn starts a new process and collects the output. The error channel
 	// returns the result of the command Spawned when it finishes.
-	server, _, spawnError := expect.Spawn(bashCommand, -1)
+	server, _, spawnError := expect.Spawn(bashCommand, -1, expect.Tee(s.output))
 	if spawnError != nil {
-		logger.DEBUG("SPAWN_OPEN: Cannot create spawn session by error: " + spawnError.Error())
+		s.Log.DEBUG("SPAWN_OPEN: Cannot create spawn session by error: " + spawnError.Error())
 		return "", errors.New(ports.ERROR_INTERNAL_EXEC)
 	}
 
-	logger.DEBUG("SPAWN_OPEN: Spawn command: '" + bashCommand + "'")
+	s.Log.DEBUG("SPAWN_OPEN: Spawn command: '" + bashCommand + "'")
+	stop := closeOnDone(ctx, server)
 	// ExpectBatch takes an array of BatchEntry and executes them in order
 	// filling in the BatchRes array for any Expect command executed.
 	resources, connectionError := server.ExpectBatch([]expect.Batcher{
-		&expect.BCas{C: []expect.Caser{
+		&expect.BCas{C: append([]expect.Caser{
 
 			// # "Are you sure you want to continue connecting (yes/no)?", send: "yes"
 			&expect.Case{R: regexp.MustCompile(`yes.no`), S: "yes\n", T: expect.Continue(
@@ -76,33 +87,24 @@ func (s *Spawn) Open(bashCommand string) (string, error) {
 			// # Password required message: "Password:", send password
 			&expect.Case{R: regexp.MustCompile(`[Pp]assword:`), S: s.Password + "\n",
 				T: expect.Continue(expect.NewStatus(codes.Canceled, ports.ERROR_CONN_AUTH_FAIL)), Rt: 1},
-
-			// # Check connection errors: "Connection closed", "Connection refused", etc...
-			&expect.Case{R: regexp.MustCompile(PromptUniversal.Errors[0]), T: expect.Fail(
-				expect.NewStatus(codes.Canceled, ports.ERROR_CONN_CLOSED))},
-			&expect.Case{R: regexp.MustCompile(PromptUniversal.Errors[1]), T: expect.Fail(
-				expect.NewStatus(codes.Canceled, ports.ERROR_CONN_AUTH_FAIL))},
-			&expect.Case{R: regexp.MustCompile(PromptUniversal.Errors[2]), T: expect.Fail(
-				expect.NewStatus(codes.Canceled, ports.ERROR_CONN_REFUSED))},
-			&expect.Case{R: regexp.MustCompile(PromptUniversal.Errors[3]), T: expect.Fail(
-				expect.NewStatus(codes.Canceled, ports.ERROR_CONN_TIMEOUT))},
-			&expect.Case{R: regexp.MustCompile(PromptUniversal.Errors[4]), T: expect.Fail(
-				expect.NewStatus(codes.Canceled, ports.ERROR_CONN_DENIED))},
-			&expect.Case{R: regexp.MustCompile(PromptUniversal.Errors[5]), T: expect.Fail(
-				expect.NewStatus(codes.Canceled, ports.ERROR_CONN_REFUSED))},
-			&expect.Case{R: regexp.MustCompile(PromptUniversal.Errors[6]), T: expect.Fail(
-				expect.NewStatus(codes.Canceled, ports.ERROR_CONN_UNABLE_TO_NEGOTIATE))},
+		}, append(ConnectionErrorCases(),
 
 			// # Check connection using universal prompt output
 			&expect.Case{R: PromptUniversal.RegExp, T: expect.OK()},
-		}},
+		)...)},
 	}, time.Duration(ports.SPAWN_TIMEOUT_SYSTEM)*time.Second)
+	stop()
+
+	if ctx.Err() != nil {
+		server.Close()
+		return "", errors.New(ContextErrorCode(ctx))
+	}
 
 	if resources == nil || len(resources) <= 0 {
 		return "", errors.New(ports.ERROR_INTERNAL_BUFFER)
 	}
 
-	logger.DEBUG("SPAWN_OPEN: RAW:" + fmt.Sprint(resources))
+	s.Log.DEBUG("SPAWN_OPEN: RAW:" + fmt.Sprint(resources))
 
 	if connectionError != nil {
 		if strings.Contains(connectionError.Error(), "expect: Process not running") {
@@ -123,6 +125,30 @@ func (s *Spawn) Open(bashCommand string) (string, error) {
 	return resources[0].Output, nil
 }
 
+/*
+ * ConnectionErrorCases
+ *
+ * Check connection errors: "Connection closed", "Connection refused", etc...
+ */
+func ConnectionErrorCases() []expect.Caser {
+	return []expect.Caser{
+		&expect.Case{R: regexp.MustCompile(PromptUniversal.Errors[0]), T: expect.Fail(
+			expect.NewStatus(codes.Canceled, ports.ERROR_CONN_CLOSED))},
+		&expect.Case{R: regexp.MustCompile(PromptUniversal.Errors[1]), T: expect.Fail(
+			expect.NewStatus(codes.Canceled, ports.ERROR_CONN_AUTH_FAIL))},
+		&expect.Case{R: regexp.MustCompile(PromptUniversal.Errors[2]), T: expect.Fail(
+			expect.NewStatus(codes.Canceled, ports.ERROR_CONN_REFUSED))},
+		&expect.Case{R: regexp.MustCompile(PromptUniversal.Errors[3]), T: expect.Fail(
+			expect.NewStatus(codes.Canceled, ports.ERROR_CONN_TIMEOUT))},
+		&expect.Case{R: regexp.MustCompile(PromptUniversal.Errors[4]), T: expect.Fail(
+			expect.NewStatus(codes.Canceled, ports.ERROR_CONN_DENIED))},
+		&expect.Case{R: regexp.MustCompile(PromptUniversal.Errors[5]), T: expect.Fail(
+			expect.NewStatus(codes.Canceled, ports.ERROR_CONN_REFUSED))},
+		&expect.Case{R: regexp.MustCompile(PromptUniversal.Errors[6]), T: expect.Fail(
+			expect.NewStatus(codes.Canceled, ports.ERROR_CONN_UNABLE_TO_NEGOTIATE))},
+	}
+}
+
 /*
  * Spawn.SendString
  *
@@ -131,9 +157,9 @@ func (s *Spawn) Open(bashCommand string) (string, error) {
  */
 func (s *Spawn) SendString(command string, timeout int, prompt *Prompt) (string, error) {
 
-	logger.DEBUG("SPAWNER_SEND_STR: Command: '" + command + "'")
-	logger.DEBUG("SPAWNER_SEND_STR: Prompt Name: '" + prompt.Name + "'")
-	logger.DEBUG("SPAWNER_SEND_STR: PromptRegExp: '" + prompt.GetRegExp().String() + "'")
+	s.Log.DEBUG("SPAWNER_SEND_STR: Command: '" + command + "'")
+	s.Log.DEBUG("SPAWNER_SEND_STR: Prompt Name: '" + prompt.Name + "'")
+	s.Log.DEBUG("SPAWNER_SEND_STR: PromptRegExp: '" + prompt.GetRegExp().String() + "'")
 
 	// Send command to remote device and read output
 	resources, connectionError := s.Session.ExpectBatch([]expect.Batcher{
@@ -151,7 +177,7 @@ func (s *Spawn) SendString(command string, timeout int, prompt *Prompt) (string,
 		return "", errors.New(ports.ERROR_INTERNAL_BUFFER)
 	}
 
-	logger.DEBUG("SPAWNER_SEND_STR: RAW:" + fmt.Sprint(resources))
+	s.Log.DEBUG("SPAWNER_SEND_STR: RAW:" + fmt.Sprint(resources))
 
 	if connectionError != nil {
 		if strings.Contains(connectionError.Error(), "expect: timer expired") {
@@ -171,7 +197,7 @@ func (s *Spawn) SendString(command string, timeout int, prompt *Prompt) (string,
  */
 func (s *Spawn) SendEnableCisco(prompt *Prompt) error {
 
-	logger.DEBUG("SPAWNER_SEND_ENABLE: Command: 'enable'")
+	s.Log.DEBUG("SPAWNER_SEND_ENABLE: Command: 'enable'")
 
 	// Send "enable" command and password to remote device
 	res, err := s.Session.ExpectBatch([]expect.Batcher{
@@ -189,7 +215,7 @@ func (s *Spawn) SendEnableCisco(prompt *Prompt) error {
 		return errors.New(ports.ERROR_INTERNAL_BUFFER)
 	}
 
-	logger.DEBUG("SPAWNER_SEND_ENABLE: RAW:" + fmt.Sprint(res))
+	s.Log.DEBUG("SPAWNER_SEND_ENABLE: RAW:" + fmt.Sprint(res))
 
 	return err
 }
@@ -201,7 +227,7 @@ func (s *Spawn) SendEnableCisco(prompt *Prompt) error {
  */
 func (s *Spawn) SendExitFromMenuCisco(command string, prompt *Prompt) error {
 
-	logger.DEBUG("SPAWNER_SEND_MENU_EXIT: Command: '" + command + "'")
+	s.Log.DEBUG("SPAWNER_SEND_MENU_EXIT: Command: '" + command + "'")
 
 	// Send "e" command to exit from cisco console menu
 	res, err := s.Session.ExpectBatch([]expect.Batcher{
@@ -216,7 +242,66 @@ func (s *Spawn) SendExitFromMenuCisco(command string, prompt *Prompt) error {
 		return errors.New(ports.ERROR_INTERNAL_BUFFER)
 	}
 
-	logger.DEBUG("SPAWNER_SEND_MENU_EXIT: RAW:" + fmt.Sprint(res))
+	s.Log.DEBUG("SPAWNER_SEND_MENU_EXIT: RAW:" + fmt.Sprint(res))
 
 	return err
 }
+
+/*
+ * Spawn.SendDetached
+ *
+ * Send command to remote device, answer all confirmation requests and wait
+ * until remote device closes the session (reload, reboot, etc...)
+ */
+func (s *Spawn) SendDetached(command string, timeout int, responders []Responder, prompt *Prompt) (string, error) {
+
+	s.Log.DEBUG("SPAWNER_SEND_DETACHED: Command: '" + command + "'")
+
+	var cases = []expect.Caser{}
+
+	// Answers to confirmation requests: "[confirm]", "Save? [yes/no]", etc...
+	for _, responder := range responders {
+		cases = append(cases, &expect.Case{R: responder.RegExp, S: responder.Answer + "\n",
+			T:  expect.Continue(expect.NewStatus(codes.Canceled, ports.ERROR_RELOAD_NO_DISCONNECT)),
+			Rt: ports.RESPONDER_RETRIES})
+	}
+
+	cases = append(cases,
+		// Configuration save request without answer in responders - configuration
+		// must not be saved or discarded implicitly
+		&expect.Case{R: PromptReloadSave, T: expect.Fail(
+			expect.NewStatus(codes.Canceled, ports.ERROR_RELOAD_SAVE_UNANSWERED))},
+		// Remote device closed the session - OK
+		&expect.Case{R: PromptDisconnect, T: expect.OK()},
+		// Errors verify
+		&expect.Case{R: prompt.GetErrors(), T: expect.Fail(
+			expect.NewStatus(codes.Canceled, ports.ERROR_SEND_COMMAND))},
+		// Prompt returned, but session must be closed
+		&expect.Case{R: prompt.GetRegExp(), T: expect.Fail(
+			expect.NewStatus(codes.Canceled, ports.ERROR_RELOAD_NO_DISCONNECT))},
+	)
+
+	resources, connectionError := s.Session.ExpectBatch([]expect.Batcher{
+		&expect.BSnd{S: command + "\n"},
+		&expect.BCas{C: cases},
+	}, time.Duration(timeout)*time.Second)
+
+	s.Log.DEBUG("SPAWNER_SEND_DETACHED: RAW:" + fmt.Sprint(resources))
+
+	var output string
+	if len(resources) > 0 {
+		output = resources[0].Output
+	}
+
+	if connectionError != nil {
+		if strings.Contains(connectionError.Error(), "expect: Process not running") {
+			// Процесс ssh/telnet завершился - сессия закрыта удалённой стороной
+			return output, nil
+		}
+		if strings.Contains(connectionError.Error(), "expect: timer expired") {
+			return output, errors.New(ports.ERROR_RELOAD_NO_DISCONNECT)
+		}
+	}
+
+	return output, connectionError
+}
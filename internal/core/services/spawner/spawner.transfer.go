@@ -0,0 +1,189 @@
+package spawner
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/andomize/network-automation-executor/internal/core/ports"
+	expect "github.com/google/goexpect"
+	"google.golang.org/grpc/codes"
+)
+
+// Методы передачи файлов
+const (
+	TransferSCPGet  = "scp-get"
+	TransferSCPPut  = "scp-put"
+	TransferSFTPGet = "sftp-get"
+	TransferSFTPPut = "sftp-put"
+)
+
+var (
+	// Prompt утилиты SFTP
+	PromptSFTP = regexp.MustCompile(`sftp>\s?$`)
+
+	// Ошибки передачи файлов утилитами SCP и SFTP
+	TransferErrors = regexp.MustCompile(`([Nn]o\ssuch\sfile|[Nn]ot\sfound|[Cc]ouldn't\s|` +
+		`[Nn]ot\sa\sregular\sfile|[Ll]ost\sconnection|[Ii]nvalid\s|[Ee]rror\s|` +
+		`[Uu]nknown\soption|[Ii]llegal\soption|subsystem\srequest\sfailed)`)
+)
+
+/*
+ * Connection.Transfer
+ *
+ * Передача файла между локальной файловой системой и удалённым хостом
+ * Используются те же хост, учётные данные и параметры SSH, что и для сессии
+ * Имена файлов не должны содержать пробелов
+ */
+func (c *Connection) Transfer(method, remoteFile, localFile string, timeout int) (string, error) {
+
+	remote := c.username + "@" + c.host + ":" + remoteFile
+
+	switch method {
+
+	case TransferSCPGet, TransferSCPPut:
+		var source, destination = remote, localFile
+		if method == TransferSCPPut {
+			source, destination = localFile, remote
+		}
+
+		// Современные версии OpenSSH используют протокол SFTP для SCP, который не
+		// поддерживается большинством сетевых устройств. Сначала пробуем legacy-протокол
+		// (флаг -O), при его отсутствии в утилите - протокол по умолчанию
+		output, transferError := c.transferSCP(
+			"scp -O "+SSHOptions()+" "+source+" "+destination, timeout)
+		if transferError != nil && regexp.MustCompile(`(unknown|illegal)\soption`).MatchString(output) {
+			c.Log.DEBUG("CONN_TRANSFER: SCP does not support legacy protocol, retrying...")
+			output, transferError = c.transferSCP(
+				"scp "+SSHOptions()+" "+source+" "+destination, timeout)
+		}
+		return output, transferError
+
+	case TransferSFTPGet:
+		return c.transferSFTP("get "+remoteFile+" "+localFile, timeout)
+
+	case TransferSFTPPut:
+		return c.transferSFTP("put "+localFile+" "+remoteFile, timeout)
+	}
+
+	return "", errors.New(ports.ERROR_TRANSFER_METHOD)
+}
+
+/*
+ * Connection.transferSCP
+ *
+ * Запуск утилиты SCP и ожидание её завершения
+ */
+func (c *Connection) transferSCP(bashCommand string, timeout int) (string, error) {
+
+	server, result, spawnError := expect.Spawn(bashCommand, -1)
+	if spawnError != nil {
+		c.Log.DEBUG("CONN_TRANSFER: Cannot create spawn session by error: " + spawnError.Error())
+		return "", errors.New(ports.ERROR_INTERNAL_EXEC)
+	}
+	defer server.Close()
+
+	c.Log.DEBUG("CONN_TRANSFER: Spawn command: '" + bashCommand + "'")
+
+	// Ни один из вариантов не завершает ожидание успешно: SCP завершает работу
+	// самостоятельно после передачи файла, результат берём из кода завершения
+	resources, transferError := server.ExpectBatch([]expect.Batcher{
+		&expect.BCas{C: append(c.transferAuthCases(), append(ConnectionErrorCases(),
+			&expect.Case{R: TransferErrors, T: expect.Fail(
+				expect.NewStatus(codes.Canceled, ports.ERROR_TRANSFER))},
+		)...)},
+	}, time.Duration(timeout)*time.Second)
+
+	var output string
+	if len(resources) > 0 {
+		output = resources[0].Output
+	}
+
+	c.Log.DEBUG("CONN_TRANSFER: RAW:" + fmt.Sprint(resources))
+
+	if transferError == nil || !strings.Contains(transferError.Error(), "expect: Process not running") {
+		if transferError != nil && strings.Contains(transferError.Error(), "expect: timer expired") {
+			return output, errors.New(ports.ERROR_CONN_TIMEOUT)
+		}
+		return output, transferError
+	}
+
+	// Процесс завершился - проверяем код завершения
+	if exitError := <-result; exitError != nil {
+		c.Log.DEBUG("CONN_TRANSFER: SCP exited with error: " + exitError.Error())
+		return output, errors.New(ports.ERROR_TRANSFER)
+	}
+
+	return output, nil
+}
+
+/*
+ * Connection.transferSFTP
+ *
+ * Запуск утилиты SFTP, выполнение одной команды (get/put) и выход
+ */
+func (c *Connection) transferSFTP(command string, timeout int) (string, error) {
+
+	bashCommand := "sftp " + SSHOptions() + " " + c.username + "@" + c.host
+
+	server, _, spawnError := expect.Spawn(bashCommand, -1)
+	if spawnError != nil {
+		c.Log.DEBUG("CONN_TRANSFER: Cannot create spawn session by error: " + spawnError.Error())
+		return "", errors.New(ports.ERROR_INTERNAL_EXEC)
+	}
+	defer server.Close()
+
+	c.Log.DEBUG("CONN_TRANSFER: Spawn command: '" + bashCommand + "'")
+
+	resources, transferError := server.ExpectBatch([]expect.Batcher{
+		// Аутентификация и ожидание Prompt утилиты SFTP
+		&expect.BCas{C: append(c.transferAuthCases(), append(ConnectionErrorCases(),
+			&expect.Case{R: PromptSFTP, T: expect.OK()},
+		)...)},
+		// Передача файла
+		&expect.BSnd{S: command + "\n"},
+		&expect.BCas{C: []expect.Caser{
+			&expect.Case{R: TransferErrors, T: expect.Fail(
+				expect.NewStatus(codes.Canceled, ports.ERROR_TRANSFER))},
+			&expect.Case{R: PromptSFTP, T: expect.OK()},
+		}},
+		&expect.BSnd{S: "bye\n"},
+	}, time.Duration(timeout)*time.Second)
+
+	c.Log.DEBUG("CONN_TRANSFER: RAW:" + fmt.Sprint(resources))
+
+	var output string
+	for _, resource := range resources {
+		output += resource.Output
+	}
+
+	if transferError != nil {
+		if strings.Contains(transferError.Error(), "expect: timer expired") {
+			return output, errors.New(ports.ERROR_CONN_TIMEOUT)
+		}
+		if strings.Contains(transferError.Error(), "expect: Process not running") {
+			return output, errors.New(ports.ERROR_TRANSFER)
+		}
+	}
+
+	return output, transferError
+}
+
+/*
+ * Connection.transferAuthCases
+ *
+ * Ответы на запросы утилит SCP и SFTP при подключении
+ */
+func (c *Connection) transferAuthCases() []expect.Caser {
+	return []expect.Caser{
+		// # "Are you sure you want to continue connecting (yes/no)?", send: "yes"
+		&expect.Case{R: regexp.MustCompile(`yes.no`), S: "yes\n", T: expect.Continue(
+			expect.NewStatus(codes.Canceled, ports.ERROR_INTERNAL_SSHHELLO)), Rt: 1},
+
+		// # Password required message: "Password:", send password
+		&expect.Case{R: regexp.MustCompile(`[Pp]assword:`), S: c.password + "\n",
+			T: expect.Continue(expect.NewStatus(codes.Canceled, ports.ERROR_CONN_AUTH_FAIL)), Rt: 1},
+	}
+}
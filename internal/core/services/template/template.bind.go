@@ -0,0 +1,95 @@
+package template
+
+import (
+	"sort"
+	"strings"
+)
+
+/*
+ * Bind
+ *
+ * Заранее подставить значения части переменных, остальные переменные
+ * шаблона остаются для подстановки при выполнении
+ *  - {{name}} заменяется значением (значение может содержать шаблон)
+ *  - в выражениях и условиях переменная заменяется строкой "значение",
+ *    а значение вида {{other}} - переменной other
+ * Шаблон с синтаксической ошибкой не изменяется
+ */
+func Bind(text string, variables map[string]string) string {
+
+	if len(variables) <= 0 || !IsTemplate(text) {
+		return text
+	}
+
+	type replacement struct {
+		start, end int
+		text       string
+	}
+	var replacements []replacement
+
+	for pos := nextTag(text, 0); pos >= 0; pos = nextTag(text, pos) {
+
+		closing := "}}"
+		if text[pos+1] == '%' {
+			closing = "%}"
+		}
+
+		tokens, end, scanError := scanTag(text, pos+2, closing)
+		if scanError != nil {
+			return text
+		}
+
+		// {{name}} - подстановка значения как есть
+		if closing == "}}" && len(tokens) == 1 && tokens[0].kind == tokenName {
+			if bound, exist := variables[tokens[0].text]; exist {
+				replacements = append(replacements, replacement{pos, end, bound})
+			}
+			pos = end
+			continue
+		}
+
+		var inArguments bool
+		for index, t := range tokens {
+			switch {
+			case t.kind == tokenOpen && index > 1 && tokens[index-2].kind == tokenPipe:
+				inArguments = true
+			case t.kind == tokenClose:
+				inArguments = false
+			}
+
+			if t.kind != tokenName || inArguments {
+				continue
+			}
+			// Имя фильтра, ключевые слова условий
+			if index > 0 && tokens[index-1].kind == tokenPipe {
+				continue
+			}
+			if closing == "%}" && (index == 0 || t.text == "and" || t.text == "or" || t.text == "not") {
+				continue
+			}
+
+			bound, exist := variables[t.text]
+			if !exist {
+				continue
+			}
+			if match := simpleVariable.FindStringSubmatch(bound); match != nil {
+				replacements = append(replacements, replacement{t.start, t.end, match[1]})
+			} else {
+				replacements = append(replacements, replacement{t.start, t.end, quote(bound)})
+			}
+		}
+
+		pos = end
+	}
+
+	sort.Slice(replacements, func(i, j int) bool { return replacements[i].start > replacements[j].start })
+	for _, r := range replacements {
+		text = text[:r.start] + r.text + text[r.end:]
+	}
+
+	return text
+}
+
+func quote(text string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(text) + `"`
+}
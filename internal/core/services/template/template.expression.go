@@ -0,0 +1,405 @@
+package template
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	tokenName = iota
+	tokenString
+	tokenPipe
+	tokenOpen
+	tokenClose
+	tokenComma
+	tokenEqual
+	tokenNotEqual
+)
+
+type token struct {
+	kind       int
+	text       string
+	start, end int
+}
+
+/*
+ * scanTag
+ *
+ * Разбить содержимое тега на токены, начиная с позиции pos и до closing
+ * Возвращает токены и позицию после закрывающей последовательности
+ */
+func scanTag(text string, pos int, closing string) ([]token, int, error) {
+
+	var tokens []token
+
+	for {
+		for pos < len(text) && strings.ContainsRune(" \t\r\n", rune(text[pos])) {
+			pos++
+		}
+		if pos >= len(text) {
+			return nil, pos, errors.New("template: unclosed tag, '" + closing + "' expected")
+		}
+		if strings.HasPrefix(text[pos:], closing) {
+			return tokens, pos + len(closing), nil
+		}
+
+		start := pos
+		switch text[pos] {
+		case '|':
+			tokens = append(tokens, token{kind: tokenPipe, text: "|", start: start, end: pos + 1})
+			pos++
+		case '(':
+			tokens = append(tokens, token{kind: tokenOpen, text: "(", start: start, end: pos + 1})
+			pos++
+		case ')':
+			tokens = append(tokens, token{kind: tokenClose, text: ")", start: start, end: pos + 1})
+			pos++
+		case ',':
+			tokens = append(tokens, token{kind: tokenComma, text: ",", start: start, end: pos + 1})
+			pos++
+		case '=', '!':
+			if pos+1 >= len(text) || text[pos+1] != '=' {
+				return nil, pos, fmt.Errorf("template: unexpected '%c', '==' or '!=' expected", text[pos])
+			}
+			kind := tokenEqual
+			if text[pos] == '!' {
+				kind = tokenNotEqual
+			}
+			tokens = append(tokens, token{kind: kind, text: text[pos : pos+2], start: start, end: pos + 2})
+			pos += 2
+		case '"', '\'':
+			quote := text[pos]
+			var value strings.Builder
+			pos++
+			for pos < len(text) && text[pos] != quote {
+				// Экранируются только кавычки и обратная косая черта,
+				// остальные последовательности (\d, \1) сохраняются для регулярных выражений
+				if text[pos] == '\\' && pos+1 < len(text) && strings.ContainsRune(`\"'`, rune(text[pos+1])) {
+					pos++
+				}
+				value.WriteByte(text[pos])
+				pos++
+			}
+			if pos >= len(text) {
+				return nil, pos, errors.New("template: unterminated string")
+			}
+			pos++
+			tokens = append(tokens, token{kind: tokenString, text: value.String(), start: start, end: pos})
+		default:
+			for pos < len(text) && !strings.ContainsRune(" \t\r\n|(),\"'=!", rune(text[pos])) &&
+				!strings.HasPrefix(text[pos:], closing) {
+				pos++
+			}
+			tokens = append(tokens, token{kind: tokenName, text: text[start:pos], start: start, end: pos})
+		}
+	}
+}
+
+type tokenParser struct {
+	tokens []token
+	index  int
+	source string
+}
+
+func (p *tokenParser) peek() *token {
+	if p.index >= len(p.tokens) {
+		return nil
+	}
+	return &p.tokens[p.index]
+}
+
+func (p *tokenParser) next() *token {
+	t := p.peek()
+	if t != nil {
+		p.index++
+	}
+	return t
+}
+
+func (p *tokenParser) end() error {
+	if t := p.peek(); t != nil {
+		return p.error("unexpected '" + t.text + "'")
+	}
+	return nil
+}
+
+func (p *tokenParser) error(message string) error {
+	return errors.New("template: " + message + " in '" + p.source + "'")
+}
+
+/*
+ * expression
+ *
+ * Переменная или строка с фильтрами: name | filter(arg, ...) | ...
+ */
+type expression struct {
+	operand operand
+	filters []filterCall
+}
+
+type operand struct {
+	value   string
+	literal bool
+}
+
+type filterCall struct {
+	name      string
+	arguments []string
+}
+
+func (p *tokenParser) parseExpression() (*expression, error) {
+
+	t := p.next()
+	if t == nil || (t.kind != tokenName && t.kind != tokenString) {
+		return nil, p.error("variable or string expected")
+	}
+
+	expr := &expression{operand: operand{value: t.text, literal: t.kind == tokenString}}
+
+	for p.peek() != nil && p.peek().kind == tokenPipe {
+		p.next()
+
+		name := p.next()
+		if name == nil || name.kind != tokenName {
+			return nil, p.error("filter name expected after '|'")
+		}
+		call := filterCall{name: name.text}
+
+		if p.peek() != nil && p.peek().kind == tokenOpen {
+			p.next()
+			for {
+				argument := p.next()
+				if argument == nil {
+					return nil, p.error("')' expected")
+				}
+				if argument.kind == tokenClose && len(call.arguments) == 0 {
+					break
+				}
+				if argument.kind != tokenName && argument.kind != tokenString {
+					return nil, p.error("filter argument expected, got '" + argument.text + "'")
+				}
+				call.arguments = append(call.arguments, argument.text)
+
+				separator := p.next()
+				if separator != nil && separator.kind == tokenClose {
+					break
+				}
+				if separator == nil || separator.kind != tokenComma {
+					return nil, p.error("',' or ')' expected")
+				}
+			}
+		}
+
+		f, exist := filters[call.name]
+		if !exist {
+			return nil, p.error("unknown filter '" + call.name + "'")
+		}
+		if len(call.arguments) < f.minArguments || len(call.arguments) > f.maxArguments {
+			return nil, p.error(fmt.Sprintf("filter '%s' takes %d..%d arguments, got %d",
+				call.name, f.minArguments, f.maxArguments, len(call.arguments)))
+		}
+
+		expr.filters = append(expr.filters, call)
+	}
+
+	return expr, nil
+}
+
+func (e *expression) hasDefault() bool {
+	for _, call := range e.filters {
+		if call.name == "default" {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *expression) eval(variables map[string]string) (value, error) {
+
+	var v value
+	if e.operand.literal {
+		v = value{text: e.operand.value, defined: true}
+	} else {
+		text, exist := variables[e.operand.value]
+		v = value{text: text, defined: exist}
+	}
+
+	for _, call := range e.filters {
+		// Фильтры, кроме default, не применяются к отсутствующей переменной
+		if !v.defined && call.name != "default" {
+			continue
+		}
+		var filterError error
+		if v, filterError = filters[call.name].apply(v, call.arguments); filterError != nil {
+			return v, fmt.Errorf("filter '%s': %v", call.name, filterError)
+		}
+	}
+
+	return v, nil
+}
+
+/*
+ * condition
+ *
+ * Условие тега {% if %}: сравнение выражений (==, !=) или проверка
+ * непустого значения, объединяемые and, or, not и скобками
+ */
+type condition interface {
+	eval(variables map[string]string) (bool, error)
+}
+
+type orCondition []condition
+type andCondition []condition
+type notCondition struct{ condition condition }
+type compareCondition struct {
+	left, right *expression
+	operator    int
+}
+
+func parseCondition(tokens []token, source string) (condition, error) {
+
+	p := &tokenParser{tokens: tokens, source: source}
+	if p.peek() == nil {
+		return nil, p.error("condition expected")
+	}
+
+	cond, condError := p.parseOr()
+	if condError != nil {
+		return nil, condError
+	}
+
+	return cond, p.end()
+}
+
+func (p *tokenParser) keyword(word string) bool {
+	if t := p.peek(); t != nil && t.kind == tokenName && t.text == word {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *tokenParser) parseOr() (condition, error) {
+	first, firstError := p.parseAnd()
+	if firstError != nil {
+		return nil, firstError
+	}
+	result := orCondition{first}
+	for p.keyword("or") {
+		next, nextError := p.parseAnd()
+		if nextError != nil {
+			return nil, nextError
+		}
+		result = append(result, next)
+	}
+	if len(result) == 1 {
+		return first, nil
+	}
+	return result, nil
+}
+
+func (p *tokenParser) parseAnd() (condition, error) {
+	first, firstError := p.parseNot()
+	if firstError != nil {
+		return nil, firstError
+	}
+	result := andCondition{first}
+	for p.keyword("and") {
+		next, nextError := p.parseNot()
+		if nextError != nil {
+			return nil, nextError
+		}
+		result = append(result, next)
+	}
+	if len(result) == 1 {
+		return first, nil
+	}
+	return result, nil
+}
+
+func (p *tokenParser) parseNot() (condition, error) {
+
+	if p.keyword("not") {
+		inner, innerError := p.parseNot()
+		if innerError != nil {
+			return nil, innerError
+		}
+		return notCondition{condition: inner}, nil
+	}
+
+	if t := p.peek(); t != nil && t.kind == tokenOpen {
+		p.next()
+		inner, innerError := p.parseOr()
+		if innerError != nil {
+			return nil, innerError
+		}
+		if t := p.next(); t == nil || t.kind != tokenClose {
+			return nil, p.error("')' expected")
+		}
+		return inner, nil
+	}
+
+	left, leftError := p.parseExpression()
+	if leftError != nil {
+		return nil, leftError
+	}
+
+	cond := compareCondition{left: left}
+	if t := p.peek(); t != nil && (t.kind == tokenEqual || t.kind == tokenNotEqual) {
+		p.next()
+		cond.operator = t.kind
+		if cond.right, leftError = p.parseExpression(); leftError != nil {
+			return nil, leftError
+		}
+	}
+
+	return cond, nil
+}
+
+func (c orCondition) eval(variables map[string]string) (bool, error) {
+	for _, item := range c {
+		result, evalError := item.eval(variables)
+		if evalError != nil || result {
+			return result, evalError
+		}
+	}
+	return false, nil
+}
+
+func (c andCondition) eval(variables map[string]string) (bool, error) {
+	for _, item := range c {
+		result, evalError := item.eval(variables)
+		if evalError != nil || !result {
+			return false, evalError
+		}
+	}
+	return true, nil
+}
+
+func (c notCondition) eval(variables map[string]string) (bool, error) {
+	result, evalError := c.condition.eval(variables)
+	return !result, evalError
+}
+
+func (c compareCondition) eval(variables map[string]string) (bool, error) {
+
+	left, leftError := c.left.eval(variables)
+	if leftError != nil {
+		return false, leftError
+	}
+
+	if c.right == nil {
+		return len(left.String()) > 0, nil
+	}
+
+	right, rightError := c.right.eval(variables)
+	if rightError != nil {
+		return false, rightError
+	}
+
+	if c.operator == tokenNotEqual {
+		return left.String() != right.String(), nil
+	}
+	return left.String() == right.String(), nil
+}
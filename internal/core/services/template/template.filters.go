@@ -0,0 +1,158 @@
+package template
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+/*
+ * value
+ *
+ * Значение выражения: строка или список (после фильтра split)
+ */
+type value struct {
+	text    string
+	list    []string
+	isList  bool
+	defined bool
+}
+
+func (v value) String() string {
+	if v.isList {
+		return strings.Join(v.list, ",")
+	}
+	return v.text
+}
+
+type filter struct {
+	minArguments int
+	maxArguments int
+	apply        func(v value, arguments []string) (value, error)
+}
+
+// Символы, допустимые в именах файлов (остальные заменяются фильтром sanitize)
+var unsafeFilename = regexp.MustCompile(`[^a-zA-Z0-9\-_.]`)
+
+// Ссылки на группы в формате \1 (Ansible) для regex_replace
+var backReference = regexp.MustCompile(`\\(\d+)`)
+
+/*
+ * Фильтры
+ *
+ *  default(x)          - x, если переменная не существует
+ *  default(x, true)    - x, если переменная не существует или пустая
+ *  upper, lower        - регистр
+ *  trim, trim(chars)   - удаление пробелов (символов chars) по краям
+ *  replace(old, new)   - замена подстроки
+ *  regex_replace(re, new)
+ *                      - замена по регулярному выражению ($1 или \1 - группа)
+ *  split, split(sep)   - список по пробелам (разделителю sep)
+ *  index(n)            - элемент списка (отрицательный - с конца)
+ *  join, join(sep)     - объединение списка (по умолчанию через ",")
+ *  sanitize            - замена символов, недопустимых в имени файла, на "_"
+ *
+ * Строковые фильтры, применённые к списку, изменяют каждый элемент
+ */
+var filters = map[string]filter{
+
+	"default": {1, 2, func(v value, arguments []string) (value, error) {
+		if !v.defined || (len(arguments) > 1 && arguments[1] == "true" && len(v.String()) <= 0) {
+			return value{text: arguments[0], defined: true}, nil
+		}
+		return v, nil
+	}},
+
+	"upper": {0, 0, eachString(func(text string, arguments []string) (string, error) {
+		return strings.ToUpper(text), nil
+	})},
+
+	"lower": {0, 0, eachString(func(text string, arguments []string) (string, error) {
+		return strings.ToLower(text), nil
+	})},
+
+	"trim": {0, 1, eachString(func(text string, arguments []string) (string, error) {
+		if len(arguments) > 0 {
+			return strings.Trim(text, arguments[0]), nil
+		}
+		return strings.TrimSpace(text), nil
+	})},
+
+	"replace": {2, 2, eachString(func(text string, arguments []string) (string, error) {
+		return strings.ReplaceAll(text, arguments[0], arguments[1]), nil
+	})},
+
+	"regex_replace": {2, 2, eachString(func(text string, arguments []string) (string, error) {
+		expression, compileError := regexp.Compile(arguments[0])
+		if compileError != nil {
+			return text, compileError
+		}
+		return expression.ReplaceAllString(text, backReference.ReplaceAllString(arguments[1], "$${$1}")), nil
+	})},
+
+	"sanitize": {0, 0, eachString(func(text string, arguments []string) (string, error) {
+		return unsafeFilename.ReplaceAllString(text, "_"), nil
+	})},
+
+	"split": {0, 1, func(v value, arguments []string) (value, error) {
+		if v.isList {
+			return v, errors.New("value is already a list")
+		}
+		result := value{isList: true, defined: true}
+		if len(arguments) > 0 && len(arguments[0]) > 0 {
+			result.list = strings.Split(v.text, arguments[0])
+		} else {
+			result.list = strings.Fields(v.text)
+		}
+		return result, nil
+	}},
+
+	"index": {1, 1, func(v value, arguments []string) (value, error) {
+		position, parseError := strconv.Atoi(arguments[0])
+		if parseError != nil {
+			return v, errors.New("index must be an integer, got '" + arguments[0] + "'")
+		}
+		list := v.list
+		if !v.isList {
+			list = []string{v.text}
+		}
+		if position < 0 {
+			position += len(list)
+		}
+		if position < 0 || position >= len(list) {
+			return v, errors.New("index " + arguments[0] + " is out of range, list contains " +
+				strconv.Itoa(len(list)) + " element(s)")
+		}
+		return value{text: list[position], defined: true}, nil
+	}},
+
+	"join": {0, 1, func(v value, arguments []string) (value, error) {
+		separator := ","
+		if len(arguments) > 0 {
+			separator = arguments[0]
+		}
+		if !v.isList {
+			return v, nil
+		}
+		return value{text: strings.Join(v.list, separator), defined: true}, nil
+	}},
+}
+
+func eachString(apply func(text string, arguments []string) (string, error)) func(value, []string) (value, error) {
+	return func(v value, arguments []string) (value, error) {
+		if !v.isList {
+			text, applyError := apply(v.text, arguments)
+			return value{text: text, defined: v.defined}, applyError
+		}
+		result := value{isList: true, defined: v.defined}
+		for _, item := range v.list {
+			text, applyError := apply(item, arguments)
+			if applyError != nil {
+				return v, applyError
+			}
+			result.list = append(result.list, text)
+		}
+		return result, nil
+	}
+}
@@ -0,0 +1,301 @@
+package template
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+/*
+ * Шаблоны строковых полей задания
+ *
+ *  {{ name }}                       - значение переменной (пустое значение допустимо,
+ *                                     отсутствие переменной - ошибка)
+ *  {{ name | default("x") }}        - значение по умолчанию для отсутствующей переменной
+ *  {{ name | lower | replace("/", "_") }}
+ *                                   - фильтры (см. template.filters.go)
+ *  {% if name == "x" %}..{% elif not other %}..{% else %}..{% endif %}
+ *                                   - условия (and, or, not, ==, !=, скобки);
+ *                                     отсутствующая переменная в условии - пустая строка
+ */
+
+type Template struct {
+	nodes []node
+}
+
+type node interface{}
+
+type textNode string
+
+type outputNode struct {
+	expression *expression
+	source     string
+}
+
+type ifNode struct {
+	branches  []ifBranch
+	otherwise []node
+}
+
+type ifBranch struct {
+	condition condition
+	body      []node
+}
+
+// Переменная шаблона в формате {{name}} без фильтров
+var simpleVariable = regexp.MustCompile(`^{{\s*([^\s|(),"'=!]+?)\s*}}$`)
+
+/*
+ * Parse
+ *
+ * Разобрать шаблон
+ */
+func Parse(text string) (*Template, error) {
+
+	p := &parser{text: text}
+	nodes, _, _, parseError := p.parseBlock(false)
+	if parseError != nil {
+		return nil, parseError
+	}
+
+	return &Template{nodes: nodes}, nil
+}
+
+/*
+ * Render
+ *
+ * Подставить значения переменных в шаблон
+ * Строки без {{ и {% возвращаются без изменений
+ */
+func Render(text string, variables map[string]string) (string, error) {
+
+	if !IsTemplate(text) {
+		return text, nil
+	}
+
+	t, parseError := Parse(text)
+	if parseError != nil {
+		return text, parseError
+	}
+
+	return t.Execute(variables)
+}
+
+// Содержит ли строка элементы шаблона
+func IsTemplate(text string) bool {
+	return strings.Contains(text, "{{") || strings.Contains(text, "{%")
+}
+
+/*
+ * Template.Execute
+ *
+ * Подставить значения переменных в разобранный шаблон
+ */
+func (t *Template) Execute(variables map[string]string) (string, error) {
+	var result strings.Builder
+	if executeError := execute(t.nodes, variables, &result); executeError != nil {
+		return "", executeError
+	}
+	return result.String(), nil
+}
+
+/*
+ * Required
+ *
+ * Переменные, без которых шаблон не может быть выполнен: переменные
+ * {{ }} без фильтра default вне условий {% if %}
+ */
+func Required(text string) ([]string, error) {
+
+	if !IsTemplate(text) {
+		return nil, nil
+	}
+
+	t, parseError := Parse(text)
+	if parseError != nil {
+		return nil, parseError
+	}
+
+	var names []string
+	for _, n := range t.nodes {
+		output, isOutput := n.(*outputNode)
+		if !isOutput || output.expression.operand.literal || output.expression.hasDefault() {
+			continue
+		}
+		names = append(names, output.expression.operand.value)
+	}
+
+	return names, nil
+}
+
+func execute(nodes []node, variables map[string]string, result *strings.Builder) error {
+
+	for _, n := range nodes {
+		switch n := n.(type) {
+
+		case textNode:
+			result.WriteString(string(n))
+
+		case *outputNode:
+			value, evalError := n.expression.eval(variables)
+			if evalError != nil {
+				return fmt.Errorf("%s: %v", n.source, evalError)
+			}
+			if !value.defined {
+				return fmt.Errorf("Text contains variable '%s', but suited variable do not exist", n.source)
+			}
+			if value.isList {
+				return fmt.Errorf("%s: result is a list, use index or join filter", n.source)
+			}
+			result.WriteString(value.text)
+
+		case *ifNode:
+			body := n.otherwise
+			for _, branch := range n.branches {
+				matched, evalError := branch.condition.eval(variables)
+				if evalError != nil {
+					return evalError
+				}
+				if matched {
+					body = branch.body
+					break
+				}
+			}
+			if executeError := execute(body, variables, result); executeError != nil {
+				return executeError
+			}
+		}
+	}
+
+	return nil
+}
+
+type parser struct {
+	text string
+	pos  int
+}
+
+/*
+ * parser.parseBlock
+ *
+ * Разобрать текст до конца или до тега elif/else/endif (inIf),
+ * возвращает узлы, ключевое слово завершившего тега и его остальные токены
+ */
+func (p *parser) parseBlock(inIf bool) ([]node, string, []token, error) {
+
+	var nodes []node
+
+	for {
+		start := nextTag(p.text, p.pos)
+		if start < 0 {
+			if p.pos < len(p.text) {
+				nodes = append(nodes, textNode(p.text[p.pos:]))
+			}
+			p.pos = len(p.text)
+			if inIf {
+				return nil, "", nil, errors.New("template: missing {% endif %}")
+			}
+			return nodes, "", nil, nil
+		}
+
+		if start > p.pos {
+			nodes = append(nodes, textNode(p.text[p.pos:start]))
+		}
+
+		closing := "}}"
+		if p.text[start+1] == '%' {
+			closing = "%}"
+		}
+
+		tokens, end, scanError := scanTag(p.text, start+2, closing)
+		if scanError != nil {
+			return nil, "", nil, scanError
+		}
+		source := p.text[start:end]
+		p.pos = end
+
+		if closing == "}}" {
+			tp := &tokenParser{tokens: tokens, source: source}
+			expr, exprError := tp.parseExpression()
+			if exprError == nil {
+				exprError = tp.end()
+			}
+			if exprError != nil {
+				return nil, "", nil, exprError
+			}
+			nodes = append(nodes, &outputNode{expression: expr, source: source})
+			continue
+		}
+
+		if len(tokens) <= 0 || tokens[0].kind != tokenName {
+			return nil, "", nil, errors.New("template: statement expected in '" + source + "'")
+		}
+
+		switch keyword := tokens[0].text; keyword {
+		case "if":
+			n, ifError := p.parseIf(tokens[1:], source)
+			if ifError != nil {
+				return nil, "", nil, ifError
+			}
+			nodes = append(nodes, n)
+		case "elif", "else", "endif":
+			if !inIf {
+				return nil, "", nil, errors.New("template: unexpected '" + source + "'")
+			}
+			return nodes, keyword, tokens[1:], nil
+		default:
+			return nil, "", nil, errors.New("template: unknown statement '" + source + "'")
+		}
+	}
+}
+
+func (p *parser) parseIf(tokens []token, source string) (*ifNode, error) {
+
+	n := &ifNode{}
+
+	cond, condError := parseCondition(tokens, source)
+	if condError != nil {
+		return nil, condError
+	}
+
+	for {
+		body, keyword, rest, blockError := p.parseBlock(true)
+		if blockError != nil {
+			return nil, blockError
+		}
+		n.branches = append(n.branches, ifBranch{condition: cond, body: body})
+
+		switch keyword {
+		case "elif":
+			if cond, condError = parseCondition(rest, source); condError != nil {
+				return nil, condError
+			}
+		case "else":
+			otherwise, endKeyword, _, elseError := p.parseBlock(true)
+			if elseError != nil {
+				return nil, elseError
+			}
+			if endKeyword != "endif" {
+				return nil, errors.New("template: {% endif %} expected after {% else %}")
+			}
+			n.otherwise = otherwise
+			return n, nil
+		case "endif":
+			return n, nil
+		}
+	}
+}
+
+// Позиция следующего тега {{ или {%
+func nextTag(text string, from int) int {
+	output := strings.Index(text[from:], "{{")
+	statement := strings.Index(text[from:], "{%")
+	switch {
+	case output < 0 && statement < 0:
+		return -1
+	case output < 0 || (statement >= 0 && statement < output):
+		return from + statement
+	}
+	return from + output
+}
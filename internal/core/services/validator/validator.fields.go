@@ -0,0 +1,156 @@
+package validator
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+ * checkFields
+ *
+ * Сравнить JSON значение с описанием типа задания (теги json)
+ * Имена полей сравниваются с учётом регистра: encoding/json принимает
+ * "Tasks" вместо "tasks", но такое поле считается ошибкой
+ * Поля с тегом ",string" должны быть строками с числом или true/false
+ */
+func (v *validation) checkFields(value interface{}, t reflect.Type, path string) {
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if value == nil {
+		return
+	}
+
+	switch t.Kind() {
+
+	case reflect.Struct:
+		object, isObject := value.(map[string]interface{})
+		if !isObject {
+			v.error(path, "must be an object")
+			return
+		}
+
+		fields := map[string]reflect.StructField{}
+		for index := 0; index < t.NumField(); index++ {
+			if name := jsonName(t.Field(index)); len(name) > 0 {
+				fields[name] = t.Field(index)
+			}
+		}
+
+		for _, key := range sortedKeys(object) {
+			field, exist := fields[key]
+			if !exist {
+				message := fmt.Sprintf("unknown field '%s'", key)
+				for name := range fields {
+					if strings.EqualFold(name, key) {
+						message += fmt.Sprintf(", did you mean '%s'?", name)
+					}
+				}
+				v.error(join(path, key), message)
+				continue
+			}
+
+			if strings.Contains(field.Tag.Get("json"), ",string") {
+				v.checkStringScalar(object[key], field.Type, join(path, key))
+				continue
+			}
+
+			v.checkFields(object[key], field.Type, join(path, key))
+		}
+
+	case reflect.Slice:
+		array, isArray := value.([]interface{})
+		if !isArray {
+			v.error(path, "must be an array")
+			return
+		}
+		for index, item := range array {
+			v.checkFields(item, t.Elem(), fmt.Sprintf("%s[%d]", path, index))
+		}
+
+	case reflect.Map:
+		object, isObject := value.(map[string]interface{})
+		if !isObject {
+			v.error(path, "must be an object")
+			return
+		}
+		for _, key := range sortedKeys(object) {
+			v.checkFields(object[key], t.Elem(), join(path, key))
+		}
+
+	case reflect.String:
+		if _, isString := value.(string); !isString {
+			v.error(path, "must be a string")
+		}
+	}
+}
+
+/*
+ * checkStringScalar
+ *
+ * Проверить значение поля с тегом ",string" (например, "timeout": "30")
+ */
+func (v *validation) checkStringScalar(value interface{}, t reflect.Type, path string) {
+
+	text, isString := value.(string)
+	if !isString {
+		v.error(path, fmt.Sprintf("must be a string with %s value (e.g. \"%s\")", t.Kind(), example(t)))
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Int:
+		if _, parseError := strconv.Atoi(text); parseError != nil {
+			v.error(path, "must be an integer, got '"+text+"'")
+		}
+	case reflect.Bool:
+		if text != "true" && text != "false" {
+			v.error(path, "must be 'true' or 'false', got '"+text+"'")
+		}
+	}
+}
+
+func jsonName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+func example(t reflect.Type) string {
+	if t.Kind() == reflect.Bool {
+		return "true"
+	}
+	return "10"
+}
+
+func join(path, key string) string {
+	if len(path) <= 0 {
+		return key
+	}
+	return path + "." + key
+}
+
+func sortedFields(fields map[string]string) []string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
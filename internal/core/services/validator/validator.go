@@ -0,0 +1,600 @@
+package validator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"strconv"
+
+	"github.com/andomize/network-automation-executor/internal/adapters/jsontask"
+	"github.com/andomize/network-automation-executor/internal/core/domains"
+	"github.com/andomize/network-automation-executor/internal/core/ports"
+	"github.com/andomize/network-automation-executor/internal/core/services/controller"
+	"github.com/andomize/network-automation-executor/internal/core/services/spawner"
+	"github.com/andomize/network-automation-executor/internal/core/services/template"
+)
+
+// Уровни замечаний проверки
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Переменные, которые добавляются при выполнении задания
+var SystemVariables = []string{"host", "date", "time", "vendor", "prompt",
+	"transferServer", "transferServerHttp"}
+
+type Issue struct {
+	Severity string `json:"severity"`
+	Path     string `json:"path"`
+	Message  string `json:"message"`
+}
+
+func (i Issue) String() string {
+	if len(i.Path) <= 0 {
+		return i.Severity + ": " + i.Message
+	}
+	return i.Severity + ": " + i.Path + ": " + i.Message
+}
+
+type validation struct {
+	issues []Issue
+
+	// Имена заданий (поле name)
+	names map[string]int
+
+	// Переменные, сохраняемые параметром register (доступны после задания)
+	registered map[string]bool
+
+	// Путь к файлу задания (CSV файлы циклов ищутся относительно него)
+	path string
+
+	// Директория, за пределы которой не могут выходить локальные файлы
+	// задания (пустая - без ограничения)
+	localRoot string
+}
+
+func (v *validation) error(path, message string) {
+	v.issues = append(v.issues, Issue{Severity: SeverityError, Path: path, Message: message})
+}
+
+func (v *validation) warning(path, message string) {
+	v.issues = append(v.issues, Issue{Severity: SeverityWarning, Path: path, Message: message})
+}
+
+/*
+ * ValidateFile
+ *
+ * Проверить файл задания без подключения к хосту
+ * YAML задания (.yaml, .yml) проверяются после преобразования в JSON
+ * Локальные файлы задания (CSV файлы циклов) должны находиться внутри
+ * localRoot (пустая - без ограничения), как при выполнении задания
+ * Ошибка возвращается, только если файл не удалось прочитать
+ */
+func ValidateFile(path, localRoot string, variables []string) ([]Issue, error) {
+
+	content, readError := ioutil.ReadFile(path)
+	if readError != nil {
+		return nil, readError
+	}
+
+	if jsontask.IsYAML(path) {
+		converted, convertError := jsontask.YAMLToJSON(content)
+		if convertError != nil {
+			return []Issue{{Severity: SeverityError, Message: "invalid YAML: " + convertError.Error()}}, nil
+		}
+		content = converted
+	}
+
+	return validate(content, path, localRoot, variables), nil
+}
+
+/*
+ * Validate
+ *
+ * Проверить задание:
+ *  - неизвестные поля (с учётом регистра) и типы значений
+ *  - компиляция всех регулярных выражений
+ *  - существование заданий, на которые ссылаются onMove и when.name
+ *  - использование переменных {{name}}: переменная должна быть объявлена
+ *    в задании, быть системной, внешней (variables, например, из инвентаря)
+ *    или группой регулярного выражения filter родительского задания
+ * Подключения include ищутся относительно текущей директории
+ */
+func Validate(content []byte, variables []string) []Issue {
+	return validate(content, "task.json", "", variables)
+}
+
+func validate(content []byte, path, localRoot string, variables []string) []Issue {
+
+	v := &validation{names: map[string]int{}, registered: map[string]bool{}, path: path, localRoot: localRoot}
+
+	var raw interface{}
+	if syntaxError := json.Unmarshal(content, &raw); syntaxError != nil {
+		v.error("", "invalid JSON: "+syntaxError.Error())
+		return v.issues
+	}
+
+	v.checkFields(raw, reflect.TypeOf(domains.TaskPattern{}), "")
+
+	// Ошибки типов уже описаны проверкой полей
+	var task domains.TaskPattern
+	if unmarshalError := json.Unmarshal(content, &task); unmarshalError != nil {
+		if !HasErrors(v.issues) {
+			v.error("", "cannot decode task: "+unmarshalError.Error())
+		}
+		return v.issues
+	}
+
+	// Проверяется задание с раскрытыми подключениями
+	if includeError := jsontask.ResolveIncludes(&task, path); includeError != nil {
+		v.error("", includeError.Error())
+		return v.issues
+	}
+
+	if len(task.Host) <= 0 {
+		v.warning("host", "host is not set, it must be provided by inventory")
+	}
+	if task.Tasks == nil || len(*task.Tasks) <= 0 {
+		v.error("tasks", ports.ERROR_SYNTAX_NO_TASKS)
+		return v.issues
+	}
+
+	// Переменные, доступные всем заданиям
+	available := map[string]bool{}
+	for _, name := range SystemVariables {
+		available[name] = true
+	}
+	for _, name := range variables {
+		available[name] = true
+	}
+	for name := range task.Variables {
+		available[name] = true
+	}
+
+	v.collectNames(task.Tasks, "tasks")
+	v.checkTasks(task.Tasks, "tasks", available)
+
+	if task.Autotests != nil {
+		for index, test := range *task.Autotests {
+			v.checkWhen(test, fmt.Sprintf("autotests[%d]", index), available)
+		}
+	}
+
+	return v.issues
+}
+
+/*
+ * HasErrors
+ *
+ * Содержат ли замечания ошибки (предупреждения не учитываются)
+ */
+func HasErrors(issues []Issue) bool {
+	for _, issue := range issues {
+		if issue.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *validation) collectNames(tasks *[]domains.Task, path string) {
+	if tasks == nil {
+		return
+	}
+	for index, task := range *tasks {
+		taskPath := fmt.Sprintf("%s[%d]", path, index)
+		if len(task.Name) > 0 {
+			if v.names[task.Name] > 0 {
+				v.warning(taskPath+".name", "task name '"+task.Name+"' is duplicated")
+			}
+			v.names[task.Name]++
+		}
+		v.collectNames(task.Tasks, taskPath+".tasks")
+		v.collectNames(task.Block, taskPath+".block")
+		v.collectNames(task.Rescue, taskPath+".rescue")
+		v.collectNames(task.Always, taskPath+".always")
+	}
+}
+
+func (v *validation) checkTasks(tasks *[]domains.Task, path string, available map[string]bool) {
+
+	for index, task := range *tasks {
+		taskPath := fmt.Sprintf("%s[%d]", path, index)
+
+		// Переменные элемента цикла доступны заданию и подзаданиям
+		taskAvailable := available
+		if task.Loop != nil {
+			taskAvailable = v.checkLoop(*task.Loop, taskPath+".loop", available)
+		}
+
+		if task.Block != nil || task.Rescue != nil || task.Always != nil {
+			v.checkBlock(task, taskPath, taskAvailable)
+			continue
+		}
+
+		v.checkVariables(task.Command, taskPath+".command", taskAvailable)
+		v.checkVariables(task.Params.OutputFile, taskPath+".params.outputFile", taskAvailable)
+		v.checkVariables(task.Params.RemoteFile, taskPath+".params.remoteFile", taskAvailable)
+		v.checkVariables(task.Params.LocalFile, taskPath+".params.localFile", taskAvailable)
+		v.checkVariables(task.Params.Transfer, taskPath+".params.transfer", taskAvailable)
+		v.checkVariables(task.Params.Filter, taskPath+".params.filter", taskAvailable)
+		v.checkVariables(task.Params.FilterExclude, taskPath+".params.filterExclude", taskAvailable)
+		v.checkVariables(task.Params.Until, taskPath+".params.until", taskAvailable)
+		v.checkVariables(task.Params.Register, taskPath+".params.register", taskAvailable)
+		v.checkVariables(task.Params.RegisterSeparator, taskPath+".params.registerSeparator", taskAvailable)
+
+		// Передача файлов
+		if len(task.Params.Transfer) > 0 {
+			switch task.Params.Transfer {
+			case spawner.TransferSCPGet, spawner.TransferSFTPGet:
+			case spawner.TransferSCPPut, spawner.TransferSFTPPut:
+				if len(task.Params.LocalFile) <= 0 {
+					v.error(taskPath+".params.localFile", ports.ERROR_TRANSFER_NO_FILE)
+				}
+			default:
+				v.error(taskPath+".params.transfer", "unknown transfer method '"+task.Params.Transfer+"'")
+			}
+			if len(task.Params.RemoteFile) <= 0 {
+				v.error(taskPath+".params.remoteFile", ports.ERROR_TRANSFER_NO_FILE)
+			}
+		}
+
+		if task.Params.Responders != nil {
+			for responderIndex, responder := range *task.Params.Responders {
+				responderPath := fmt.Sprintf("%s.params.responders[%d]", taskPath, responderIndex)
+				v.checkVariables(responder.Expect, responderPath+".expect", taskAvailable)
+				v.checkVariables(responder.Send, responderPath+".send", taskAvailable)
+				v.compile(responder.Expect, responderPath+".expect")
+			}
+		}
+
+		if task.When != nil {
+			for whenIndex, when := range *task.When {
+				v.checkWhen(when, fmt.Sprintf("%s.when[%d]", taskPath, whenIndex), taskAvailable)
+			}
+		}
+
+		v.checkRegister(task.Params, taskPath)
+
+		v.compile(task.Params.Until, taskPath+".params.until")
+		if len(task.Params.Until) <= 0 && (task.Params.Retries != 0 || task.Params.Delay != 0) {
+			v.warning(taskPath+".params", "retries and delay are ignored without until")
+		}
+		if task.Params.Retries < 0 || task.Params.Delay < 0 {
+			v.error(taskPath+".params", "retries and delay must not be negative")
+		}
+
+		v.compile(task.Params.FilterExclude, taskPath+".params.filterExclude")
+		filter := v.compile(task.Params.Filter, taskPath+".params.filter")
+
+		if len(task.Params.Filter) > 0 && task.Tasks == nil {
+			v.warning(taskPath+".params.filter", "task contains filter, but does not contain subtasks")
+		}
+		if task.Tasks == nil {
+			continue
+		}
+		if len(task.Params.Filter) <= 0 {
+			v.warning(taskPath+".tasks", "subtasks are executed only for values of filter, but filter is not set")
+		}
+
+		// Группы регулярного выражения доступны подзаданиям как переменные
+		subtaskAvailable := map[string]bool{}
+		for name := range taskAvailable {
+			subtaskAvailable[name] = true
+		}
+		if filter != nil {
+			for groupIndex, name := range filter.SubexpNames() {
+				if groupIndex <= 0 {
+					continue
+				}
+				if len(name) <= 0 {
+					name = strconv.Itoa(groupIndex)
+				}
+				subtaskAvailable[name] = true
+			}
+		}
+
+		v.checkTasks(task.Tasks, taskPath+".tasks", subtaskAvailable)
+	}
+}
+
+/*
+ * validation.checkBlock
+ *
+ * Проверить группу заданий block, rescue и always
+ */
+func (v *validation) checkBlock(task domains.Task, path string, available map[string]bool) {
+
+	if task.Block == nil {
+		v.error(path, ports.ERROR_SYNTAX_RESCUE_NO_BLOCK)
+	}
+	if len(task.Command) > 0 || task.Tasks != nil || task.Params != (domains.Param{}) {
+		v.error(path, ports.ERROR_SYNTAX_BLOCK_MIXED)
+	}
+
+	if task.When != nil {
+		for whenIndex, when := range *task.When {
+			v.checkWhen(when, fmt.Sprintf("%s.when[%d]", path, whenIndex), available)
+		}
+	}
+
+	if task.Block != nil {
+		if len(*task.Block) <= 0 {
+			v.warning(path+".block", "block does not contain tasks")
+		}
+		v.checkTasks(task.Block, path+".block", available)
+	}
+
+	// Ошибка block доступна только заданиям rescue и always
+	handlersAvailable := map[string]bool{controller.BlockErrorVariable: true}
+	for name := range available {
+		handlersAvailable[name] = true
+	}
+
+	if task.Rescue != nil {
+		v.checkTasks(task.Rescue, path+".rescue", handlersAvailable)
+	}
+	if task.Always != nil {
+		v.checkTasks(task.Always, path+".always", handlersAvailable)
+	}
+}
+
+/*
+ * validation.checkLoop
+ *
+ * Проверить параметр loop, возвращает переменные, доступные заданию с циклом
+ */
+func (v *validation) checkLoop(loop domains.Loop, path string, available map[string]bool) map[string]bool {
+
+	loopAvailable := map[string]bool{}
+	for name := range available {
+		loopAvailable[name] = true
+	}
+
+	sources := 0
+	for _, defined := range []bool{loop.Items != nil, len(loop.Range) > 0,
+		len(loop.Variable) > 0, len(loop.CSV) > 0} {
+		if defined {
+			sources++
+		}
+	}
+	if sources != 1 {
+		v.error(path, ports.ERROR_LOOP_SOURCE)
+		return loopAvailable
+	}
+
+	name := loop.As
+	if len(name) <= 0 {
+		name = controller.LoopDefaultVariable
+	}
+
+	switch {
+	case loop.Items != nil:
+		for index, item := range loop.Items {
+			v.checkVariables(item, fmt.Sprintf("%s.items[%d]", path, index), available)
+		}
+		if len(loop.Items) <= 0 {
+			v.warning(path+".items", "loop list is empty, task is skipped")
+		}
+
+	case len(loop.Range) > 0:
+		v.checkVariables(loop.Range, path+".range", available)
+		if !template.IsTemplate(loop.Range) {
+			if _, _, rangeError := controller.ParseLoopRange(loop.Range); rangeError != nil {
+				v.error(path+".range", rangeError.Error())
+			}
+		}
+
+	case len(loop.Variable) > 0:
+		if !available[loop.Variable] && !v.registered[loop.Variable] {
+			v.error(path+".variable", "variable '"+loop.Variable+"' is not defined")
+		}
+
+	case len(loop.CSV) > 0:
+		v.checkVariables(loop.CSV, path+".csv", available)
+		if template.IsTemplate(loop.CSV) {
+			v.warning(path+".csv", "CSV file name is known only at runtime, its columns are not checked")
+			break
+		}
+		csvPath, pathError := controller.ResolveLocalPath(loop.CSV, v.path, v.localRoot)
+		if pathError != nil {
+			v.error(path+".csv", pathError.Error()+": '"+loop.CSV+"'")
+			break
+		}
+		header, _, csvError := controller.ReadLoopCSV(csvPath)
+		if csvError != nil {
+			v.error(path+".csv", csvError.Error())
+			break
+		}
+		for _, field := range header {
+			loopAvailable[field] = true
+		}
+		return loopAvailable
+	}
+
+	loopAvailable[name] = true
+	return loopAvailable
+}
+
+/*
+ * validation.checkRegister
+ *
+ * Проверить параметр register, группы выражения становятся переменными
+ * для следующих заданий и автотестов
+ */
+func (v *validation) checkRegister(params domains.Param, path string) {
+
+	switch params.RegisterMode {
+	case "", controller.RegisterFirst, controller.RegisterLast, controller.RegisterJoin, controller.RegisterCount:
+	default:
+		v.error(path+".params.registerMode", "unknown register mode '"+params.RegisterMode+"'")
+	}
+
+	if len(params.Register) <= 0 {
+		if len(params.RegisterMode) > 0 || len(params.RegisterSeparator) > 0 {
+			v.warning(path+".params", "registerMode and registerSeparator are ignored without register")
+		}
+		return
+	}
+
+	register := v.compile(params.Register, path+".params.register")
+	if register == nil {
+		return
+	}
+	if register.NumSubexp() <= 0 {
+		v.error(path+".params.register", ports.ERROR_REGISTER_NO_GROUPS)
+		return
+	}
+
+	for groupIndex, name := range register.SubexpNames() {
+		if groupIndex <= 0 {
+			continue
+		}
+		if len(name) <= 0 {
+			name = strconv.Itoa(groupIndex)
+		}
+		v.registered[name] = true
+	}
+}
+
+func (v *validation) checkWhen(when domains.When, path string, available map[string]bool) {
+	v.checkCondition(when, path, available, false)
+}
+
+/*
+ * validation.checkCondition
+ *
+ * Проверить элемент условия и вложенные группы all, any и not
+ * Действия вложенных условий не выполняются
+ */
+func (v *validation) checkCondition(when domains.When, path string, available map[string]bool, nested bool) {
+
+	for _, group := range []struct {
+		name string
+		when *[]domains.When
+	}{{"all", when.All}, {"any", when.Any}} {
+		if group.when == nil {
+			continue
+		}
+		if len(*group.when) <= 0 {
+			v.warning(path+"."+group.name, "group is empty")
+		}
+		for index, condition := range *group.when {
+			v.checkCondition(condition, fmt.Sprintf("%s.%s[%d]", path, group.name, index), available, true)
+		}
+	}
+	if when.Not != nil {
+		v.checkCondition(*when.Not, path+".not", available, true)
+	}
+
+	hasActions := len(when.OnMove) > 0 || when.OnExit
+	if nested && hasActions {
+		v.warning(path, "actions onMove and onExit of nested conditions are ignored")
+	}
+
+	fields := map[string]string{"name": when.Name, "ifStatus": when.IfStatus,
+		"ifOutputContains": when.IfOutputContains, "ifOutputNotContains": when.IfOutputNotContains,
+		"ifOutputContainsRe": when.IfOutputContainsRe, "ifOutputNotContainsRe": when.IfOutputNotContainsRe,
+		"variable": when.Variable, "ifValue": when.IfValue, "ifValueNot": when.IfValueNot, "onMove": when.OnMove,
+		"extract": when.Extract, "ifVersionGt": when.IfVersionGt, "ifVersionGte": when.IfVersionGte,
+		"ifVersionLt": when.IfVersionLt, "ifVersionLte": when.IfVersionLte,
+		"ifNumberEq": when.IfNumberEq, "ifNumberGt": when.IfNumberGt, "ifNumberGte": when.IfNumberGte,
+		"ifNumberLt": when.IfNumberLt, "ifNumberLte": when.IfNumberLte, "ifNumberBetween": when.IfNumberBetween}
+	for _, field := range sortedFields(fields) {
+		v.checkVariables(fields[field], path+"."+field, available)
+	}
+
+	nameBased := len(when.IfStatus) > 0 || len(when.IfOutputContains) > 0 ||
+		len(when.IfOutputNotContains) > 0 || len(when.IfOutputContainsRe) > 0 ||
+		len(when.IfOutputNotContainsRe) > 0 || len(when.Extract) > 0
+	variableBased := len(when.IfValue) > 0 || len(when.IfValueNot) > 0
+	versionBased := len(when.IfVersionGt) > 0 || len(when.IfVersionGte) > 0 ||
+		len(when.IfVersionLt) > 0 || len(when.IfVersionLte) > 0
+	numberBased := len(when.IfNumberEq) > 0 || len(when.IfNumberGt) > 0 || len(when.IfNumberGte) > 0 ||
+		len(when.IfNumberLt) > 0 || len(when.IfNumberLte) > 0 || len(when.IfNumberBetween) > 0
+
+	switch {
+	case len(when.Name) > 0 && len(when.Variable) > 0:
+		v.error(path, ports.ERROR_WHEN_CONDITION_DOUBLE_BASED)
+	case len(when.Name) <= 0 && len(when.Variable) <= 0 && (versionBased || numberBased):
+		v.error(path, "ifVersion* and ifNumber* require name or variable")
+	case len(when.Name) > 0 && numberBased && len(when.Extract) <= 0:
+		v.error(path, "ifNumber* with name require extract")
+	case len(when.Name) <= 0 && len(when.Variable) <= 0:
+		if when.All == nil && when.Any == nil && when.Not == nil && !hasActions {
+			v.warning(path, "condition contains no checks")
+		}
+	case len(when.Name) > 0 && variableBased:
+		v.error(path, "ifValue and ifValueNot require variable, not name")
+	case len(when.Variable) > 0 && nameBased:
+		v.error(path, "ifStatus, ifOutput* and extract require name, not variable")
+	}
+
+	if len(when.Name) > 0 && v.names[when.Name] <= 0 && !template.IsTemplate(when.Name) {
+		v.error(path+".name", "task with name '"+when.Name+"' does not exist")
+	}
+
+	if len(when.Variable) > 0 && !available[when.Variable] && !v.registered[when.Variable] {
+		v.warning(path+".variable", "variable '"+when.Variable+"' is not defined statically,"+
+			" condition is false unless it is set at runtime")
+	}
+
+	switch when.IfStatus {
+	case "", ports.PIPE_STATUS_SUCCESS, ports.PIPE_STATUS_FAIL, ports.PIPE_STATUS_SKIPPED:
+	default:
+		v.error(path+".ifStatus", "unknown status '"+when.IfStatus+"'")
+	}
+
+	v.compile(when.IfOutputContainsRe, path+".ifOutputContainsRe")
+	v.compile(when.IfOutputNotContainsRe, path+".ifOutputNotContainsRe")
+	v.compile(when.Extract, path+".extract")
+
+	numbers := map[string]string{"ifNumberEq": when.IfNumberEq, "ifNumberGt": when.IfNumberGt,
+		"ifNumberGte": when.IfNumberGte, "ifNumberLt": when.IfNumberLt, "ifNumberLte": when.IfNumberLte}
+	for _, field := range sortedFields(numbers) {
+		if len(numbers[field]) <= 0 || template.IsTemplate(numbers[field]) {
+			continue
+		}
+		if _, numberError := controller.ParseNumber(numbers[field]); numberError != nil {
+			v.error(path+"."+field, numberError.Error())
+		}
+	}
+	if len(when.IfNumberBetween) > 0 && !template.IsTemplate(when.IfNumberBetween) {
+		if _, _, rangeError := controller.ParseRange(when.IfNumberBetween); rangeError != nil {
+			v.error(path+".ifNumberBetween", rangeError.Error())
+		}
+	}
+
+	if len(when.OnMove) > 0 && v.names[when.OnMove] <= 0 && !template.IsTemplate(when.OnMove) {
+		v.error(path+".onMove", "GOTO target '"+when.OnMove+"' does not exist")
+	}
+}
+
+func (v *validation) checkVariables(text, path string, available map[string]bool) {
+	names, templateError := template.Required(text)
+	if templateError != nil {
+		v.error(path, templateError.Error())
+		return
+	}
+	for _, name := range names {
+		if !available[name] && !v.registered[name] {
+			v.error(path, "variable '{{"+name+"}}' is not defined")
+		}
+	}
+}
+
+func (v *validation) compile(expression, path string) *regexp.Regexp {
+	// Регулярное выражение с шаблоном известно только при выполнении
+	if len(expression) <= 0 || template.IsTemplate(expression) {
+		return nil
+	}
+	compiled, compileError := regexp.Compile(expression)
+	if compileError != nil {
+		v.error(path, "invalid regular expression: "+compileError.Error())
+		return nil
+	}
+	return compiled
+}
@@ -0,0 +1,8 @@
+package validator
+
+import _ "embed"
+
+// JSON Schema файла задания (draft-07)
+//
+//go:embed task.schema.json
+var Schema []byte
@@ -0,0 +1,209 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+	"time"
+
+	"github.com/andomize/network-automation-executor/internal/adapters/eventstream"
+	"github.com/andomize/network-automation-executor/internal/adapters/jsontask"
+	"github.com/andomize/network-automation-executor/internal/adapters/logger"
+	"github.com/andomize/network-automation-executor/internal/adapters/transferserver"
+	"github.com/andomize/network-automation-executor/internal/adapters/webhook"
+	"github.com/andomize/network-automation-executor/internal/core/domains"
+	"github.com/andomize/network-automation-executor/internal/core/ports"
+	"github.com/andomize/network-automation-executor/internal/core/services/runner"
+)
+
+// Типы задания, доступные для использования вне модуля
+type (
+	TaskPattern = domains.TaskPattern
+	Task        = domains.Task
+	Param       = domains.Param
+	When        = domains.When
+	Setting     = domains.Setting
+	Responder   = domains.Responder
+)
+
+// Службы, которые могут использоваться несколькими заданиями одновременно
+type (
+	TransferServer = transferserver.TransferServer
+	Notifier       = webhook.Notifier
+	EventStream    = eventstream.Stream
+)
+
+// Возможные статусы выполнения
+const (
+	StatusSuccess = ports.PIPE_STATUS_SUCCESS
+	StatusFail    = ports.PIPE_STATUS_FAIL
+	StatusSkipped = ports.PIPE_STATUS_SKIPPED
+)
+
+type Options struct {
+	// Учётные данные для подключения к хосту
+	Username string
+	Password string
+
+	// Директория для сохранения выводов команд
+	OutputDirectory string
+
+	// Путь для сохранения результата выполнения (пустой - не сохранять)
+	ResultPath string
+
+	// Путь к файлу задания, относительно которого определяются локальные
+	// файлы передачи (пустой - текущая директория)
+	TaskPath string
+
+	// Дополнительные переменные, имеют приоритет над переменными задания
+	Variables map[string]string
+
+	// Максимальное время выполнения задания, включая подключение к хосту
+	// (0 - без ограничения, кроме срока контекста)
+	Timeout time.Duration
+
+	// Получатель журнала выполнения (nil - только общий журнал программы)
+	Log       io.Writer
+	LogPrefix string
+
+	// Получатель потока событий выполнения в формате JSONL (nil - не записывать)
+	Events io.Writer
+
+	// Общий поток событий выполнения, используется вместо Events
+	EventStream *EventStream
+
+	// Встроенный сервер передачи файлов и его адрес для хоста (nil - не используется)
+	TransferServer  *TransferServer
+	TransferAddress string
+
+	// Уведомления о результате выполнения (nil - не отправляются)
+	// Уведомления отправляются в фоне, завершения отправки ожидает Notifier.Close
+	Notifier *Notifier
+}
+
+type Result struct {
+	// Задание со статусами выполнения всех подзаданий
+	Task TaskPattern
+
+	// Итоговый статус и код ошибки задания
+	Status string
+	Error  string
+
+	// Результат автотестов (пустой - автотесты не выполнялись)
+	Autotests string
+
+	// Длительность выполнения
+	Duration time.Duration
+}
+
+/*
+ * Execute
+ *
+ * Выполнить задание на хосте, указанном в задании
+ * Ошибка возвращается, если задание завершилось неуспешно. Код ошибки
+ * (например, "connection-refused") также доступен в Result.Error
+ * Отмена контекста прерывает выполнение: соединение закрывается, задание
+ * завершается с ошибкой "execution-cancelled" или, если истёк срок
+ * контекста, "spawner-host-execution-timeout"
+ * Аварийное завершение (panic) выполнения возвращается как ошибка
+ * "internal-error-host-execution-panic"
+ */
+func Execute(ctx context.Context, task TaskPattern, options Options) (result Result, err error) {
+
+	startTime := time.Now()
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			logger.ERROR(fmt.Sprintf("EXECUTOR: Host '%s' execution panic: %v\n%s",
+				task.Host, recovered, debug.Stack()))
+			task.Status, task.Error = StatusFail, ports.ERROR_INTERNAL_PANIC
+			result = Result{Task: task, Status: StatusFail, Error: ports.ERROR_INTERNAL_PANIC,
+				Duration: time.Since(startTime)}
+			err = errors.New(result.Error)
+		}
+	}()
+
+	if options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.Timeout)
+		defer cancel()
+	}
+
+	var log *logger.Logger
+	if options.Log != nil || len(options.LogPrefix) > 0 {
+		log = logger.New(options.LogPrefix, options.Log)
+	}
+
+	events := options.EventStream
+	if events == nil && options.Events != nil {
+		events = eventstream.New(options.Events)
+	}
+
+	resultTask, hostResult := runner.ExecuteTask(ctx, task, options.TaskPath, options.ResultPath,
+		options.OutputDirectory, options.Variables, log, runner.Options{
+			Username:        options.Username,
+			Password:        options.Password,
+			Events:          events,
+			TransferServer:  options.TransferServer,
+			TransferAddress: options.TransferAddress,
+		})
+	options.Notifier.Notify(resultTask, hostResult)
+
+	result = Result{
+		Task:      resultTask,
+		Status:    hostResult.Status,
+		Error:     hostResult.Error,
+		Autotests: hostResult.Autotests,
+		Duration:  time.Since(startTime),
+	}
+
+	if result.Status != StatusSuccess {
+		return result, errors.New(result.Error)
+	}
+
+	return result, nil
+}
+
+/*
+ * SetTaskLibraries
+ *
+ * Установить директории библиотек заданий для подключений
+ * "include": "@<name>" в файлах заданий
+ */
+func SetTaskLibraries(directories ...string) {
+	jsontask.SetLibraries(directories)
+}
+
+/*
+ * ExecuteFile
+ *
+ * Выполнить задание из файла. Если options.ResultPath не указан, то
+ * результат записывается обратно в JSON файл задания, а для YAML файла и
+ * файла с include - в <имя>.result.<ext> рядом с ним. Если такой результат
+ * уже существует, то он используется вместо исходного файла (защита от
+ * повторного выполнения)
+ */
+func ExecuteFile(ctx context.Context, taskPath string, options Options) (Result, error) {
+
+	task, taskReadError := jsontask.Read(taskPath)
+
+	if taskReadError == nil && len(options.ResultPath) <= 0 {
+		options.ResultPath = jsontask.ResultPath(taskPath, *task)
+		if _, statError := os.Stat(options.ResultPath); statError == nil && options.ResultPath != taskPath {
+			logger.INFO("EXECUTOR: Task '" + taskPath + "' has result of previous run, using it")
+			task, taskReadError = jsontask.Read(options.ResultPath)
+		}
+	}
+
+	if taskReadError != nil {
+		options.Notifier.Notify(TaskPattern{}, domains.HostResult{Status: StatusFail, Error: taskReadError.Error()})
+		return Result{Status: StatusFail, Error: taskReadError.Error()}, taskReadError
+	}
+
+	options.TaskPath = taskPath
+
+	return Execute(ctx, *task, options)
+}